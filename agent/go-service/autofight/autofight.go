@@ -3,12 +3,11 @@ package autofight
 import (
 	"fmt"
 	"image"
-	"image/png"
-	"os"
 	"path/filepath"
 	"sort"
 	"time"
 
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/minicv"
 	"github.com/MaaXYZ/maa-framework-go/v4"
 	"github.com/rs/zerolog/log"
 )
@@ -220,21 +219,9 @@ func saveExitImage(img image.Image, reason string) {
 	if img == nil {
 		return
 	}
-	dir := filepath.Join("debug", "autofight_exit")
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		log.Debug().Err(err).Str("dir", dir).Msg("Failed to create debug dir for exit image")
-		return
-	}
-	name := fmt.Sprintf("%s_%s.png", reason, time.Now().Format("20060102_150405"))
-	path := filepath.Join(dir, name)
-	f, err := os.Create(path)
+	path, err := minicv.ImageDumpPNG(img, filepath.Join("debug", "autofight_exit"), reason)
 	if err != nil {
-		log.Debug().Err(err).Str("path", path).Msg("Failed to create file for exit image")
-		return
-	}
-	defer f.Close()
-	if err := png.Encode(f, img); err != nil {
-		log.Debug().Err(err).Str("path", path).Msg("Failed to encode exit image")
+		log.Debug().Err(err).Str("path", path).Msg("Failed to save exit image")
 		return
 	}
 	log.Info().Str("path", path).Str("reason", reason).Msg("Saved exit frame to disk")
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	maptracker "github.com/MaaXYZ/MaaEnd/agent/go-service/map-tracker"
+)
+
+// runBenchMapTracker runs the headless MapTrackerInfer benchmark and returns the process exit code
+func runBenchMapTracker(args []string) int {
+	fs := flag.NewFlagSet("bench-maptracker", flag.ContinueOnError)
+	framesDir := fs.String("frames", "", "directory containing labelled screenshots (required)")
+	labelsFile := fs.String("labels", "labels.json", "labels file name inside the frames directory")
+	resourceDir := fs.String("resource", "", "resource directory containing MapTracker images (default: auto-detect)")
+	mapNameRegex := fs.String("map-regex", "", "map_name_regex passed to MapTrackerInfer (default: inference default)")
+	precisions := fs.String("precisions", "0.3,0.5,0.7,1.0", "comma-separated precision values to benchmark")
+	threshold := fs.Float64("threshold", 0, "threshold passed to MapTrackerInfer (default: inference default)")
+	locTolerance := fs.Float64("loc-tolerance", 5.0, "maximum location error in pixels for a correct frame")
+	rotTolerance := fs.Int("rot-tolerance", 10, "maximum rotation error in degrees for a correct frame")
+	frameInterval := fs.Int64("frame-interval", 100, "frame clock step in ms for labels without time_ms")
+	jsonOut := fs.String("json", "", "write the full report as JSON to this path")
+	minAccuracy := fs.Float64("min-accuracy", 0, "exit with code 1 if accuracy at any precision is below this value")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *frameInterval < 0 {
+		fmt.Fprintln(os.Stderr, "bench-maptracker: -frame-interval must not be negative")
+		return 2
+	}
+	if *framesDir == "" {
+		fmt.Fprintln(os.Stderr, "bench-maptracker: -frames is required")
+		fs.Usage()
+		return 2
+	}
+
+	precisionList, err := maptracker.ParseBenchPrecisions(*precisions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bench-maptracker: %v\n", err)
+		return 2
+	}
+
+	report, err := maptracker.RunInferBenchmark(maptracker.BenchOptions{
+		FramesDir:       *framesDir,
+		LabelsFile:      *labelsFile,
+		ResourceDir:     *resourceDir,
+		MapNameRegex:    *mapNameRegex,
+		Precisions:      precisionList,
+		Threshold:       *threshold,
+		LocTolerance:    *locTolerance,
+		RotTolerance:    *rotTolerance,
+		FrameIntervalMs: *frameInterval,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bench-maptracker: %v\n", err)
+		return 1
+	}
+
+	report.WriteText(os.Stdout)
+
+	if *jsonOut != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bench-maptracker: failed to marshal report: %v\n", err)
+			return 1
+		}
+		if err := os.WriteFile(*jsonOut, data, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "bench-maptracker: failed to write report: %v\n", err)
+			return 1
+		}
+	}
+
+	if acc := report.MinAccuracy(); acc < *minAccuracy {
+		fmt.Fprintf(os.Stderr, "bench-maptracker: accuracy %.3f is below required %.3f\n", acc, *minAccuracy)
+		return 1
+	}
+	return 0
+}
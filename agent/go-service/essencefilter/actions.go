@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/minicv"
 	maa "github.com/MaaXYZ/maa-framework-go/v4"
 	"github.com/rs/zerolog/log"
 )
@@ -37,13 +39,28 @@ func (a *EssenceFilterInitAction) Run(ctx *maa.Context, arg *maa.CustomActionArg
 	}
 	log.Info().Str("component", "EssenceFilter").Str("step", "LoadMatcherConfig").Msg("matcher config loaded")
 
+	if err := LoadEssenceTypes(filepath.Join(gameDataDir, "essence_types.json")); err != nil {
+		log.Error().Err(err).Str("component", "EssenceFilter").Str("step", "LoadEssenceTypes").Msg("load essence types failed")
+		return false
+	}
+	log.Info().Str("component", "EssenceFilter").Str("step", "LoadEssenceTypes").Int("types", len(essenceTypeDefs)).Msg("essence types loaded")
+
 	// 3. load DB
-	if err := LoadWeaponDatabase(weaponDataPath); err != nil {
+	hadDB := len(weaponDB.Weapons) > 0
+	report, err := LoadWeaponDatabase(weaponDataPath, defaultWeaponOverlayDir)
+	if report != nil {
+		logWeaponDBReport(ctx, report)
+	}
+	if err != nil {
 		log.Error().Err(err).Str("component", "EssenceFilter").Str("step", "LoadDatabase").Msg("load DB failed")
-		return false
+		if !hadDB {
+			return false
+		}
+		LogMXUSimpleHTMLWithColor(ctx, "武器数据有误，沿用上次加载的数据", "#ff8c00")
+	} else if report.Reloaded {
+		LogMXUSimpleHTML(ctx, fmt.Sprintf("武器数据加载完成（版本 %d，%d 个文件）", report.Version, len(report.Sources)))
+		logSkillPools()
 	}
-	LogMXUSimpleHTML(ctx, "武器数据加载完成")
-	logSkillPools()
 
 	// 4. load presets
 	opts, err := getOptionsFromAttach(ctx, arg.CurrentTaskName)
@@ -52,6 +69,13 @@ func (a *EssenceFilterInitAction) Run(ctx *maa.Context, arg *maa.CustomActionArg
 		return false
 	}
 
+	if err := SetSkillLanguage(opts.ClientLanguage); err != nil {
+		log.Error().Err(err).Str("component", "EssenceFilter").Str("step", "SetSkillLanguage").Str("language", opts.ClientLanguage).Msg("set skill language failed")
+		LogMXUSimpleHTMLWithColor(ctx, fmt.Sprintf("不支持的客户端语言：%s", escapeHTML(err.Error())), "#ff0000")
+		return false
+	}
+	log.Info().Str("component", "EssenceFilter").Str("step", "SetSkillLanguage").Str("language", activeLanguage.Code).Msg("skill language set")
+
 	// 5. select preset
 
 	var WeaponRarity []int
@@ -65,28 +89,57 @@ func (a *EssenceFilterInitAction) Run(ctx *maa.Context, arg *maa.CustomActionArg
 		WeaponRarity = append(WeaponRarity, 4)
 	}
 
-	if len(WeaponRarity) == 0 {
-		log.Error().Str("component", "EssenceFilter").Str("step", "ValidatePresets").Msg("no preset selected")
-		LogMXUSimpleHTMLWithColor(ctx, "未选择任何武器稀有度，请至少选择一个武器稀有度作为筛选条件", "#ff0000")
+	// 自定义目标规则
+	rules, err := LoadTargetRules(opts.TargetRules, opts.TargetRulesPath)
+	if err != nil {
+		log.Error().Err(err).Str("component", "EssenceFilter").Str("step", "LoadTargetRules").Str("path", opts.TargetRulesPath).Msg("load target rules failed")
+		LogMXUSimpleHTMLWithColor(ctx, fmt.Sprintf("自定义规则加载失败：%s", escapeHTML(err.Error())), "#ff0000")
 		return false
 	}
+	targetRules = rules
+	logTargetRules()
 
-	EssenceTypes = EssenceTypes[:0] // reset global EssenceTypes slice
-	if opts.FlawlessEssence {
-		EssenceTypes = append(EssenceTypes, FlawlessEssenceMeta)
+	// 库存记录
+	inventoryDB = nil
+	if opts.RecordInventory && opts.InventoryPath != "" {
+		db, err := OpenInventoryDatabase(opts.InventoryPath)
+		if err != nil {
+			log.Error().Err(err).Str("component", "EssenceFilter").Str("step", "OpenInventory").Str("path", opts.InventoryPath).Msg("open inventory failed")
+			LogMXUSimpleHTMLWithColor(ctx, fmt.Sprintf("库存数据库打开失败：%s", escapeHTML(err.Error())), "#ff0000")
+			return false
+		}
+		inventoryDB = db
+		log.Info().Str("component", "EssenceFilter").Str("step", "OpenInventory").Str("path", opts.InventoryPath).Int("records", len(db.Records)).Msg("inventory opened")
 	}
-	if opts.PureEssence {
-		EssenceTypes = append(EssenceTypes, PureEssenceMeta)
+
+	if len(WeaponRarity) == 0 && len(targetRules) == 0 {
+		log.Error().Str("component", "EssenceFilter").Str("step", "ValidatePresets").Msg("no preset selected")
+		LogMXUSimpleHTMLWithColor(ctx, "未选择任何武器稀有度，也未配置自定义规则，请至少选择一项作为筛选条件", "#ff0000")
+		return false
 	}
 
+	EssenceTypes, err = selectEssenceTypes(opts)
+	if err != nil {
+		log.Error().Err(err).Str("component", "EssenceFilter").Str("step", "ValidatePresets").Msg("select essence types failed")
+		LogMXUSimpleHTMLWithColor(ctx, fmt.Sprintf("基质类型设置有误：%s", escapeHTML(err.Error())), "#ff0000")
+		return false
+	}
 	if len(EssenceTypes) == 0 {
 		log.Error().Str("component", "EssenceFilter").Str("step", "ValidatePresets").Msg("no essence type selected")
 		LogMXUSimpleHTMLWithColor(ctx, "未选择任何基质类型，请至少选择一个基质类型作为筛选条件", "#ff0000")
 		return false
 	}
 
-	LogMXUSimpleHTML(ctx, fmt.Sprintf("已选择稀有度：%s", rarityListToString(WeaponRarity)))
+	if len(WeaponRarity) > 0 {
+		LogMXUSimpleHTML(ctx, fmt.Sprintf("已选择稀有度：%s", rarityListToString(WeaponRarity)))
+	}
+	if len(targetRules) > 0 {
+		LogMXUHTML(ctx, targetRulesHTML())
+	}
 	LogMXUSimpleHTML(ctx, fmt.Sprintf("已选择基质类型：%s", essenceListToString(EssenceTypes)))
+	if opts.DryRun {
+		LogMXUSimpleHTMLWithColor(ctx, "试运行模式：只识别并汇总结果，不会锁定或废弃任何物品", "#ff7000")
+	}
 	// 6. filter weapons
 	filteredWeapons := FilterWeaponsByConfig(WeaponRarity)
 	names := make([]string, 0, len(filteredWeapons))
@@ -121,12 +174,39 @@ func (a *EssenceFilterInitAction) Run(ctx *maa.Context, arg *maa.CustomActionArg
 	visitedCount = 0
 	matchedCount = 0
 	matchedCombinationSummary = make(map[string]*SkillCombinationSummary)
-	currentCol = 1
 	currentRow = 1
-	maxItemsPerRow = 9
+	grid = newGridTracker()
 	firstRowSwipeDone = false
 	finalLargeScanUsed = false
 	statsLogged = false
+	auditEntries = nil
+	reviewEntries = nil
+
+	// 扫描断点：续扫时恢复进度与计数，否则开始新的断点
+	checkpointPath := opts.CheckpointPath
+	if checkpointPath == "" {
+		checkpointPath = defaultCheckpointPath
+	}
+	signature := scanCheckpointSignature(opts)
+	scanCheckpoint, pendingCheckpointKey, resumeRow = nil, "", 0
+	if opts.ResumeScan {
+		cp, err := LoadScanCheckpoint(checkpointPath, signature)
+		switch {
+		case err != nil:
+			log.Warn().Err(err).Str("component", "EssenceFilter").Str("step", "LoadCheckpoint").Str("path", checkpointPath).Msg("checkpoint unusable, start over")
+			LogMXUSimpleHTMLWithColor(ctx, fmt.Sprintf("扫描断点不可用，从头开始：%s", escapeHTML(err.Error())), "#ff7000")
+		case cp == nil:
+			LogMXUSimpleHTML(ctx, "未找到扫描断点，从头开始")
+		default:
+			scanCheckpoint = cp
+			restoreFromCheckpoint(cp)
+			log.Info().Str("component", "EssenceFilter").Str("step", "LoadCheckpoint").Int("row", cp.Row).Int("items", len(cp.Items)).Msg("resume from checkpoint")
+			LogMXUSimpleHTMLWithColor(ctx, fmt.Sprintf("从第 %d 行继续扫描（已处理 %d 个基质）", cp.Row, len(cp.Items)), "#064d7c")
+		}
+	}
+	if scanCheckpoint == nil {
+		scanCheckpoint = newScanCheckpoint(checkpointPath, signature)
+	}
 	log.Info().Str("component", "EssenceFilter").Str("step", "BuildSkillCombinations").Int("combinations", len(targetSkillCombinations)).Msg("skill combinations built")
 	log.Info().Str("component", "EssenceFilter").Msg("init done")
 
@@ -228,6 +308,7 @@ func (a *OCREssenceInventoryNumberAction) Run(ctx *maa.Context, arg *maa.CustomA
 		fmt.Sprintf("库存中共 <span style=\"color: #ff7000; font-weight: 900;\">%d</span> 个基质", n),
 	)
 
+	grid.total = n
 	if n <= maxSinglePage {
 		ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{
 			{Name: "EssenceDetectFinal"},
@@ -277,8 +358,8 @@ func (a *EssenceFilterCheckItemAction) Run(ctx *maa.Context, arg *maa.CustomActi
 			}
 		}
 	}
-	text := cleanChinese(rawText)
-	if text == "" {
+	text := activeLanguage.display(rawText)
+	if activeLanguage.clean(text) == "" {
 		log.Error().Str("component", "EssenceFilter").Int("slot", params.Slot).Str("raw", rawText).Msg("OCR empty")
 		return false
 	}
@@ -345,7 +426,7 @@ func (a *EssenceFilterCheckItemLevelAction) Run(ctx *maa.Context, arg *maa.Custo
 	return false
 }
 
-// EssenceFilterRowCollectAction - collect boxes in a row (TemplateMatch detail) + ColorMatch filter, click first
+// EssenceFilterRowCollectAction - collect boxes in a row (TemplateMatch detail) + essence type classification, click first
 type EssenceFilterRowCollectAction struct{}
 
 func (a *EssenceFilterRowCollectAction) Run(ctx *maa.Context, arg *maa.CustomActionArg) bool {
@@ -371,8 +452,10 @@ func (a *EssenceFilterRowCollectAction) Run(ctx *maa.Context, arg *maa.CustomAct
 		log.Error().Err(err).Str("component", "EssenceFilter").Str("action", "RowCollect").Msg("get screenshot failed")
 		return false
 	}
+	rgba := minicv.ImageConvertRGBA(img)
 
 	rowBoxes = rowBoxes[:0]
+	rowBoxTypes = make(map[[4]int]string)
 	for _, res := range results {
 		tm, ok := res.AsTemplateMatch()
 		if !ok {
@@ -381,48 +464,23 @@ func (a *EssenceFilterRowCollectAction) Run(ctx *maa.Context, arg *maa.CustomAct
 		b := tm.Box
 		boxArr := [4]int{b.X(), b.Y(), b.Width(), b.Height()}
 
-		colorMatchROIX := boxArr[0]
-		colorMatchROIY := boxArr[1] + 90
-		colorMatchROIW := boxArr[2]
-		colorMatchROIH := boxArr[3] - 90
-		if colorMatchROIW <= 0 || colorMatchROIH <= 0 {
+		roi, ok := essenceColorROI(boxArr)
+		if !ok {
 			log.Error().Str("component", "EssenceFilter").Str("action", "RowCollect").Ints("box", boxArr[:]).Msg("invalid ROI size, skip")
 			continue // skip invalid ROIs
 		}
 
-		roi := maa.Rect{colorMatchROIX, colorMatchROIY, colorMatchROIW, colorMatchROIH}
-
-		for _, et := range EssenceTypes {
-			ColorMatchOverrideParam := map[string]any{
-				"EssenceColorMatch": map[string]any{
-					"roi":   roi,
-					"lower": et.Range.Lower,
-					"upper": et.Range.Upper,
-				},
-			}
-			cDetail, err := ctx.RunRecognition("EssenceColorMatch", img, ColorMatchOverrideParam)
-
-			if err != nil {
-				log.Error().Err(err).Str("component", "EssenceFilter").Str("action", "RowCollect").Ints("box", boxArr[:]).Msg("ColorMatch failed")
-				continue
-			}
-
-			if cDetail != nil && cDetail.Hit {
-				rowBoxes = append(rowBoxes, boxArr)
-				break
-			}
+		// 按全部已定义类型分类，再只保留本次选择的类型
+		et, area, ok := classifyEssence(rgba, roi, essenceTypeDefs)
+		if !ok || !slices.ContainsFunc(EssenceTypes, func(t EssenceMeta) bool { return t.ID == et.ID }) {
+			continue
 		}
+		log.Debug().Str("component", "EssenceFilter").Str("action", "RowCollect").Ints("box", boxArr[:]).Str("type", et.ID).Int("area", area).Msg("essence classified")
+		rowBoxes = append(rowBoxes, boxArr)
+		rowBoxTypes[boxArr] = et.Name
 	}
-	// sort rowboxes by Y coordinate then X coordinate
-	sort.Slice(rowBoxes, func(i, j int) bool {
-		if rowBoxes[i][1] == rowBoxes[j][1] {
-			return rowBoxes[i][0] < rowBoxes[j][0]
-		}
-		return rowBoxes[i][1] < rowBoxes[j][1]
-	})
-
 	// LogMXUSimpleHTML(ctx, "len(results): "+strconv.Itoa(len(results))+", valid boxes after color match: "+strconv.Itoa(len(rowBoxes)))
-	log.Info().Str("component", "EssenceFilter").Str("action", "RowCollect").Int("len_results", len(results)).Int("valid_boxes", len(rowBoxes)).Msg("color match done")
+	log.Info().Str("component", "EssenceFilter").Str("action", "RowCollect").Int("len_results", len(results)).Int("valid_boxes", len(rowBoxes)).Msg("essence classify done")
 	// 如果本行没有任何符合条件的box，且还没有使用过最终大范围扫描，则触发最终大范围扫描；否则直接结束当前行的处理
 	isFallbackScan := arg.CurrentTaskName == "EssenceDetectFinal"
 
@@ -440,14 +498,21 @@ func (a *EssenceFilterRowCollectAction) Run(ctx *maa.Context, arg *maa.CustomAct
 		return true
 	}
 
-	// 在非尾扫的情况下，如果符合条件的box数量超过单行最大可处理数量，直接结束当前行的处理，避免误操作；如果是尾扫，则不论数量多少都继续处理
-	if (len(rowBoxes) > maxItemsPerRow) && !isFallbackScan {
-		log.Error().Str("component", "EssenceFilter").Str("action", "RowCollect").Int("count", len(rowBoxes)).Msg("boxes exceed max per row, abort")
-		ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{
-			{Name: "EssenceFilterFinish"},
-		})
-		return true
+	// 由整屏格子学习网格，只保留当前逻辑行（尾扫时保留当前行及之后的所有行），每列最多一个格子
+	rows := grid.observe(rowBoxes)
+	rowY := grid.resolve(rows, currentRow, rgba)
+	rowBoxes = rowBoxes[:0]
+	if isFallbackScan {
+		for _, r := range rows {
+			if float64(r.Y) >= rowY-grid.pitch()/2 {
+				rowBoxes = append(rowBoxes, grid.cells(r)...)
+			}
+		}
+	} else if r, ok := grid.rowAt(rows, rowY); ok {
+		rowBoxes = grid.cells(r)
 	}
+	log.Info().Str("component", "EssenceFilter").Str("action", "RowCollect").Int("row", currentRow).Float64("row_y", rowY).Int("rows_visible", len(rows)).Int("columns", grid.columns).Int("cells", len(rowBoxes)).Msg("grid row located")
+
 	if len(rowBoxes) == 0 {
 		log.Info().Str("component", "EssenceFilter").Str("action", "RowCollect").Msg("no valid boxes, finish")
 		ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{
@@ -457,6 +522,17 @@ func (a *EssenceFilterRowCollectAction) Run(ctx *maa.Context, arg *maa.CustomAct
 	}
 
 	rowIndex = 0
+	// 续扫：快进跳过断点之前已完整处理的行；行不满说明背包已变化，停止快进并逐个核对
+	if resumeRow > currentRow && !isFallbackScan {
+		if grid.full(len(rowBoxes)) {
+			log.Info().Str("component", "EssenceFilter").Str("action", "RowCollect").Int("row", currentRow).Int("resume_row", resumeRow).Msg("skip row before checkpoint")
+			LogMXUSimpleHTML(ctx, fmt.Sprintf("续扫：跳过已处理的第 %d 行", currentRow))
+			rowIndex = len(rowBoxes)
+		} else {
+			log.Warn().Str("component", "EssenceFilter").Str("action", "RowCollect").Int("row", currentRow).Int("count", len(rowBoxes)).Msg("row not full during resume, stop skipping")
+			resumeRow = 0
+		}
+	}
 	ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{
 		{Name: "EssenceFilterRowNextItem"},
 	})
@@ -467,10 +543,11 @@ func (a *EssenceFilterRowCollectAction) Run(ctx *maa.Context, arg *maa.CustomAct
 type EssenceFilterRowNextItemAction struct{}
 
 func (a *EssenceFilterRowNextItemAction) Run(ctx *maa.Context, arg *maa.CustomActionArg) bool {
-	// ensure we exit detail before next
+	// 上一个基质的锁定/废弃已完成，处理结果可以落盘
+	checkpointCommit()
 
 	if rowIndex >= len(rowBoxes) {
-		if (len(rowBoxes) == maxItemsPerRow) && !finalLargeScanUsed {
+		if grid.full(len(rowBoxes)) && grid.hasRowAfter(currentRow) && !finalLargeScanUsed {
 			var nextSwipe string
 			if !firstRowSwipeDone {
 				nextSwipe = "EssenceFilterSwipeFirst"
@@ -483,7 +560,9 @@ func (a *EssenceFilterRowNextItemAction) Run(ctx *maa.Context, arg *maa.CustomAc
 				ctx,
 				fmt.Sprintf("滑动到第 %d 行", currentRow+1),
 			)
+			grid.advance(nextSwipe)
 			currentRow++
+			saveCheckpoint()
 
 			ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{
 				{Name: nextSwipe},
@@ -497,6 +576,7 @@ func (a *EssenceFilterRowNextItemAction) Run(ctx *maa.Context, arg *maa.CustomAc
 	}
 
 	box := rowBoxes[rowIndex]
+	currentEssenceType = rowBoxTypes[box]
 	cx := box[0] + box[2]/2
 	cy := box[1] + box[3]/2
 	log.Info().Str("component", "EssenceFilter").Str("action", "RowNextItem").Ints("box", box[:]).Int("cx", cx).Int("cy", cy).Msg("click next box")
@@ -531,12 +611,48 @@ func (a *EssenceFilterSkillDecisionAction) Run(ctx *maa.Context, arg *maa.Custom
 		opts = &EssenceFilterOptions{}
 	}
 
+	// 续扫：断点中已处理过的基质（位置与技能签名一致）不再操作，只补记库存与审计
+	if item, ok := checkpointDecided(skills); ok {
+		visitedCount-- // 已计入断点中的历遍数
+		recordDecision(opts.DryRun, skills, item.match(), item.Decision, item.Reason)
+		log.Info().Str("component", "EssenceFilter").Strs("skills", skills).Str("decision", item.Decision).Str("reason", item.Reason).Msg("already decided in checkpoint, skip")
+		LogMXUSimpleHTMLWithColor(ctx, fmt.Sprintf("续扫：该基质已处理（%s），跳过", decisionLabel(item.Decision)), "#a0a0a0")
+		currentSkills = [3]string{}
+		currentSkillLevels = [3]int{}
+		ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{
+			{Name: "EssenceFilterRowNextItem"},
+		})
+		return true
+	}
+
+	// 排除规则优先于所有匹配
+	excludeRule := MatchExcludeRules(skills, currentSkillLevels)
+
 	// 优先：原始技能组合匹配
-	matchResult, matched := MatchEssenceSkills(ctx, skills)
+	var matchResult, ambiguousMatch *SkillCombinationMatch
+	matched := false
+	if excludeRule == nil {
+		matchResult, matched = MatchEssenceSkills(ctx, skills)
+		if !matched && matchResult != nil && matchResult.Ambiguous {
+			ambiguousMatch = matchResult
+		}
+	}
 
-	// 次优先：保留未来可期基质、保留实用基质
+	// 次优先：自定义保留规则；无武器条件的规则按扩展规则处理
 	extendedReason := ""
-	if !matched && opts != nil {
+	if !matched && excludeRule == nil {
+		if ruleMatch, rule, ok := MatchTargetRules(skills, currentSkillLevels); ok {
+			matched = true
+			matchResult = ruleMatch
+			extTargetRuleCount++
+			if len(ruleMatch.Weapons) == 0 {
+				extendedReason = fmt.Sprintf("自定义规则「%s」", rule.label)
+			}
+		}
+	}
+
+	// 再次：保留未来可期基质、保留实用基质
+	if !matched && excludeRule == nil && opts != nil {
 		if opts.KeepFuturePromising && opts.FuturePromisingMinTotal > 0 {
 			if MatchFuturePromising(skills, currentSkillLevels, opts.FuturePromisingMinTotal) {
 				matched = true
@@ -608,9 +724,7 @@ func (a *EssenceFilterSkillDecisionAction) Run(ctx *maa.Context, arg *maa.Custom
 			escapeHTML(extendedReason),
 		))
 
-		ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{
-			{Name: "EssenceFilterLockItemLog"},
-		})
+		applyDecision(ctx, arg.CurrentTaskName, opts.DryRun, skills, matchResult, InventoryDecisionLock, extendedReason)
 	} else if matched {
 		// 武器匹配命中
 		matchedCount++
@@ -664,23 +778,40 @@ func (a *EssenceFilterSkillDecisionAction) Run(ctx *maa.Context, arg *maa.Custom
 			}
 		}
 
-		ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{
-			{Name: "EssenceFilterLockItemLog"},
-		})
+		reason := ""
+		if matchResult.Decoded {
+			reason = fmt.Sprintf("组合解码（置信度 %.2f）", matchResult.Confidence)
+			LogMXUSimpleHTML(ctx, fmt.Sprintf("OCR 存在误识，按目标组合解码匹配，置信度 %.2f", matchResult.Confidence))
+		}
+		applyDecision(ctx, arg.CurrentTaskName, opts.DryRun, skills, matchResult, InventoryDecisionLock, reason)
+	} else if ambiguousMatch != nil {
+		// 疑似目标组合但置信度不足：跳过并列入人工确认，绝不废弃
+		reason := fmt.Sprintf("疑似 %s（置信度 %.2f）", formatWeaponNames(ambiguousMatch.Weapons), ambiguousMatch.Confidence)
+		log.Info().
+			Str("component", "EssenceFilter").
+			Strs("skills", skills).
+			Ints("skill_ids", ambiguousMatch.SkillIDs).
+			Float64("confidence", ambiguousMatch.Confidence).
+			Msg("ambiguous match, flag for review")
+		LogMXUSimpleHTMLWithColor(ctx, fmt.Sprintf("⚠️ %s，需人工确认，跳过该物品", escapeHTML(reason)), "#ff7000")
+		applyDecision(ctx, arg.CurrentTaskName, opts.DryRun, skills, ambiguousMatch, InventoryDecisionReview, reason)
 	} else {
 		// 未匹配：根据选项决定是跳过还是废弃
+		reason := "未匹配到目标技能组合"
+		if excludeRule != nil {
+			reason = fmt.Sprintf("排除规则「%s」", excludeRule.label)
+			excludedCount++
+			log.Info().Str("component", "EssenceFilter").Strs("skills", skills).Str("rule", excludeRule.label).Msg("exclude rule hit")
+			LogMXUSimpleHTMLWithColor(ctx, fmt.Sprintf("命中排除规则「%s」", escapeHTML(excludeRule.label)), "#ff6b6b")
+		}
 		if opts.DiscardUnmatched {
 			log.Info().Str("component", "EssenceFilter").Strs("skills", skills).Msg("not matched, discard item")
 			LogMXUHTML(ctx, `<div style="color: #ff6b6b; font-weight: 900;">🗑️ 未匹配到目标技能组合，废弃该物品</div>`)
-			ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{
-				{Name: "EssenceFilterDiscardItemLog"},
-			})
+			applyDecision(ctx, arg.CurrentTaskName, opts.DryRun, skills, nil, InventoryDecisionDiscard, reason)
 		} else {
 			log.Info().Str("component", "EssenceFilter").Strs("skills", skills).Msg("not matched, skip to next item")
 			LogMXUSimpleHTML(ctx, "未匹配到目标技能组合，跳过该物品")
-			ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{
-				{Name: "EssenceFilterRowNextItem"},
-			})
+			applyDecision(ctx, arg.CurrentTaskName, opts.DryRun, skills, nil, InventoryDecisionSkip, reason)
 		}
 	}
 
@@ -696,17 +827,29 @@ func (a *EssenceFilterFinishAction) Run(ctx *maa.Context, arg *maa.CustomActionA
 	log.Info().Str("component", "EssenceFilter").Msg("finish")
 	log.Info().Str("component", "EssenceFilter").Int("matched_total", matchedCount).Msg("locked items")
 
-	LogMXUSimpleHTMLWithColor(
-		ctx,
-		fmt.Sprintf("筛选完成！共历遍物品：%d，确认锁定物品：%d", visitedCount, matchedCount),
-		"#11cf00",
-	)
+	opts, _ := getOptionsFromAttach(ctx, "EssenceFilterInit")
+	dryRun := opts != nil && opts.DryRun
+
+	if dryRun {
+		LogMXUSimpleHTMLWithColor(
+			ctx,
+			fmt.Sprintf("试运行完成！共历遍物品：%d，将锁定物品：%d", visitedCount, matchedCount),
+			"#11cf00",
+		)
+		logAuditSummary(ctx)
+	} else {
+		LogMXUSimpleHTMLWithColor(
+			ctx,
+			fmt.Sprintf("筛选完成！共历遍物品：%d，确认锁定物品：%d", visitedCount, matchedCount),
+			"#11cf00",
+		)
+	}
 
 	// 追加本轮战利品摘要
 	logMatchSummary(ctx)
+	logReviewList(ctx)
 
 	// 扩展规则统计
-	opts, _ := getOptionsFromAttach(ctx, "EssenceFilterInit")
 	if opts != nil {
 		if opts.KeepFuturePromising {
 			LogMXUSimpleHTMLWithColor(ctx,
@@ -720,68 +863,86 @@ func (a *EssenceFilterFinishAction) Run(ctx *maa.Context, arg *maa.CustomActionA
 				"#064d7c",
 			)
 		}
-		// 生成预刻写方案推荐（须在重置全局变量之前调用）
+		if len(targetRules) > 0 {
+			LogMXUSimpleHTMLWithColor(ctx,
+				fmt.Sprintf("自定义规则锁定：%d 个，排除：%d 个", extTargetRuleCount, excludedCount),
+				"#064d7c",
+			)
+		}
+		// 生成预刻写排期（须在重置全局变量、关闭库存之前调用）
 		if opts.ExportCalculatorScript {
 			logCalculatorResult(ctx)
 		}
+		if inventoryDB != nil {
+			LogMXUSimpleHTML(ctx, fmt.Sprintf("库存记录：本轮 %d 个基质，累计 %d 条记录", len(inventoryDB.CurrentRecords()), len(inventoryDB.Records)))
+		}
 	}
 
+	// 选项读取失败时仍保存库存，但不导出
+	closeInventory(opts != nil && opts.ExportInventory)
+	closeCheckpoint() // 扫描正常结束，不再需要断点
 	targetSkillCombinations = nil
 	matchedCount = 0
 	visitedCount = 0
 	extFuturePromisingCount = 0
 	extSlot3PracticalCount = 0
+	extTargetRuleCount = 0
+	excludedCount = 0
+	targetRules = nil
+	auditEntries = nil
+	reviewEntries = nil
 	for i := range filteredSkillStats {
 		filteredSkillStats[i] = nil
 	}
 	matchedCombinationSummary = nil
 	statsLogged = false
-	currentCol = 1
 	currentRow = 1
 	finalLargeScanUsed = false
 	firstRowSwipeDone = false
 	rowBoxes = nil
+	rowBoxTypes = nil
+	grid = newGridTracker()
+	currentEssenceType = ""
 	rowIndex = 0
 	swipeCalibrateRetry = 0
 
 	return true
 }
 
-// 首行基准 Y（720p），用于滑动后校准
-const firstRowTargetY = 86
-
-// 校准容差：first_box_y 在 [firstRowTargetY-tolerance, firstRowTargetY+tolerance] 内视为已对齐
+// 校准容差：当前行 Y 与对齐基准相差不超过该值视为已对齐
 const calibrateTolerance = 4
 
-// 内容偏移 1px 对应的手指滑动距离（scrollRatio >= 1 表示手指需滑动更多才能带动内容）
+// 内容偏移 1px 对应的手指滑动距离初值（scrollRatio >= 1 表示手指需滑动更多才能带动内容），运行中按实际位移学习
 const calibrateScrollRatio = 1.1
 
-// 校准滑动距离限制（px）
+// 校准滑动最小距离（px），最大距离为一行
 const calibrateSwipeMin = 4
-const calibrateSwipeMax = 40
 
-// EssenceFilterSwipeCalibrateAction - 根据首个 box 的 Y 校准到基准 firstRowTargetY，按误差量计算滑动距离
+// 校准最大重试次数，超过后按网格跟踪到的行位置继续
+const calibrateMaxRetry = 5
+
+// EssenceFilterSwipeCalibrateAction - 滑动后由网格跟踪确定当前逻辑行的位置，并微滑将其对齐到首行基准。
+// 过冲或不足时按行格吸附识别出真正的当前行，不会把相邻行当作当前行；列表到底滑不动时直接在当前位置继续。
 type EssenceFilterSwipeCalibrateAction struct{}
 
 func (a *EssenceFilterSwipeCalibrateAction) Run(ctx *maa.Context, arg *maa.CustomActionArg) bool {
-	if swipeCalibrateRetry >= 5 {
+	detect := []maa.NextItem{
+		{Name: "EssenceRowDetect"},
+		{Name: "EssenceDetectFinal"},
+	}
+	if swipeCalibrateRetry >= calibrateMaxRetry {
 		swipeCalibrateRetry = 0
 		log.Info().
 			Str("component", "EssenceFilter").
 			Str("step", "SwipeCalibrate").
-			Msg("max retries, skip calibration")
-		ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{
-			{Name: "EssenceRowDetect"},
-			{Name: "EssenceDetectFinal"},
-		})
+			Float64("row_y", grid.rowY).
+			Msg("max retries, continue with tracked row")
+		ctx.OverrideNext(arg.CurrentTaskName, detect)
 		return true
 	}
 
 	if arg.RecognitionDetail == nil || arg.RecognitionDetail.Results == nil || !arg.RecognitionDetail.Hit {
-		ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{
-			{Name: "EssenceRowDetect"},
-			{Name: "EssenceDetectFinal"},
-		})
+		ctx.OverrideNext(arg.CurrentTaskName, detect)
 		return true
 	}
 
@@ -789,14 +950,6 @@ func (a *EssenceFilterSwipeCalibrateAction) Run(ctx *maa.Context, arg *maa.Custo
 	if len(results) == 0 {
 		results = arg.RecognitionDetail.Results.All
 	}
-	if len(results) == 0 {
-		ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{
-			{Name: "EssenceRowDetect"},
-			{Name: "EssenceDetectFinal"},
-		})
-		return true
-	}
-
 	boxes := make([][4]int, 0, len(results))
 	for _, res := range results {
 		tm, ok := res.AsTemplateMatch()
@@ -806,56 +959,61 @@ func (a *EssenceFilterSwipeCalibrateAction) Run(ctx *maa.Context, arg *maa.Custo
 		b := tm.Box
 		boxes = append(boxes, [4]int{b.X(), b.Y(), b.Width(), b.Height()})
 	}
-	sort.Slice(boxes, func(i, j int) bool {
-		return boxes[i][0] < boxes[j][0]
-	})
-	firstBoxY := boxes[0][1]
-
-	low := firstRowTargetY - calibrateTolerance
-	high := firstRowTargetY + calibrateTolerance
-	if firstBoxY >= low && firstBoxY <= high {
-		swipeCalibrateRetry = 0
-		log.Info().Int("first_box_y", firstBoxY).Msg("<EssenceFilter> SwipeCalibrate: aligned")
-		ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{
-			{Name: "EssenceRowDetect"},
-			{Name: "EssenceDetectFinal"},
-		})
+	if len(boxes) == 0 {
+		ctx.OverrideNext(arg.CurrentTaskName, detect)
 		return true
 	}
 
-	delta := firstBoxY - firstRowTargetY
-	swipeDist := int(float64(abs(delta)) * calibrateScrollRatio)
-	if swipeDist < calibrateSwipeMin {
-		swipeDist = calibrateSwipeMin
+	controller := ctx.GetTasker().GetController()
+	if controller == nil {
+		log.Error().Str("component", "EssenceFilter").Str("step", "SwipeCalibrate").Msg("controller nil")
+		return false
+	}
+	controller.PostScreencap().Wait()
+	img, err := controller.CacheImage()
+	if err != nil {
+		log.Error().Err(err).Str("component", "EssenceFilter").Str("step", "SwipeCalibrate").Msg("get screenshot failed")
+		return false
 	}
-	if swipeDist > calibrateSwipeMax {
-		swipeDist = calibrateSwipeMax
+
+	// 几何只从 RowCollect 颜色过滤后的格子学习，这里仅聚行定位
+	rowY := grid.resolve(groupGridRows(boxes), currentRow, minicv.ImageConvertRGBA(img))
+	if grid.stuck && rowY > float64(grid.alignY) {
+		swipeCalibrateRetry = 0
+		log.Info().Str("component", "EssenceFilter").Str("step", "SwipeCalibrate").Float64("row_y", rowY).Msg("list end reached, continue in place")
+		ctx.OverrideNext(arg.CurrentTaskName, detect)
+		return true
 	}
 
-	centerX := 135
-	beginY := 191
-	var endY int
-	// 如果 delta 为正，则向上滑动；如果 delta 为负，则向下滑动
-	if delta > 0 {
-		endY = beginY - swipeDist
-		log.Info().Int("first_box_y", firstBoxY).Int("delta", delta).Int("swipe_dist", swipeDist).Msg("<EssenceFilter> SwipeCalibrate: too low, swipe up")
-	} else {
-		endY = beginY + swipeDist
-		log.Info().Int("first_box_y", firstBoxY).Int("delta", delta).Int("swipe_dist", swipeDist).Msg("<EssenceFilter> SwipeCalibrate: too high, swipe down")
+	begin, end, ok := grid.calibrateSwipe()
+	if !ok {
+		swipeCalibrateRetry = 0
+		log.Info().Str("component", "EssenceFilter").Str("step", "SwipeCalibrate").Float64("row_y", rowY).Msg("aligned")
+		ctx.OverrideNext(arg.CurrentTaskName, detect)
+		return true
 	}
+	log.Info().
+		Str("component", "EssenceFilter").
+		Str("step", "SwipeCalibrate").
+		Float64("row_y", rowY).
+		Int("align_y", grid.alignY).
+		Ints("begin", begin[:]).
+		Ints("end", end[:]).
+		Float64("scroll_ratio", grid.scrollRatio).
+		Msg("calibrate swipe")
 
 	override := map[string]any{
 		"EssenceFilterSwipeCalibrateCorrect": map[string]any{
 			"action": map[string]any{
 				"param": map[string]any{
-					"begin": []int{centerX, beginY},
-					"end":   []int{centerX, endY},
+					"begin": begin[:],
+					"end":   end[:],
 				},
 			},
 		},
 	}
 	if _, err := ctx.RunTask("EssenceFilterSwipeCalibrateCorrect", override); err != nil {
-		log.Error().Err(err).Msg("<EssenceFilter> SwipeCalibrate: RunTask failed")
+		log.Error().Err(err).Str("component", "EssenceFilter").Str("step", "SwipeCalibrate").Msg("RunTask failed")
 	}
 
 	swipeCalibrateRetry++
@@ -0,0 +1,170 @@
+package essencefilter
+
+import (
+	"fmt"
+	"strings"
+
+	maa "github.com/MaaXYZ/maa-framework-go/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// auditEntry - 试运行模式下一个基质的预期处理结果
+type auditEntry struct {
+	Index    int
+	Type     string
+	Skills   [3]string
+	Levels   [3]int
+	Decision string
+	Reason   string
+	Weapons  []string
+}
+
+// decisionLabel - 处理结果的展示名
+func decisionLabel(decision string) string {
+	switch decision {
+	case InventoryDecisionLock:
+		return "锁定"
+	case InventoryDecisionDiscard:
+		return "废弃"
+	case InventoryDecisionReview:
+		return "待确认"
+	default:
+		return "跳过"
+	}
+}
+
+// newAuditEntry - 用当前基质生成一条审计记录
+func newAuditEntry(index int, skills []string, match *SkillCombinationMatch, decision, reason string) auditEntry {
+	e := auditEntry{
+		Index:    index,
+		Type:     currentEssenceType,
+		Levels:   currentSkillLevels,
+		Decision: decision,
+		Reason:   reason,
+	}
+	copy(e.Skills[:], skills)
+	if match != nil {
+		for _, w := range match.Weapons {
+			e.Weapons = append(e.Weapons, w.ChineseName)
+		}
+	}
+	return e
+}
+
+// applyDecision - 执行处理结果：写入库存与扫描断点，并跳转到锁定/废弃/下一个物品。
+// 待确认的基质额外加入人工确认列表，且永远不会被锁定或废弃。
+// 试运行模式下只记录审计结果，不操作物品，一律跳到下一个物品。
+func applyDecision(ctx *maa.Context, taskName string, dryRun bool, skills []string, match *SkillCombinationMatch, decision, reason string) {
+	checkpointRecord(skills, match, decision, reason)
+	recordDecision(dryRun, skills, match, decision, reason)
+	if dryRun {
+		e := auditEntries[len(auditEntries)-1]
+		log.Info().
+			Str("component", "EssenceFilter").
+			Bool("dry_run", true).
+			Int("index", e.Index).
+			Strs("skills", skills).
+			Str("decision", decision).
+			Str("reason", reason).
+			Strs("weapons", e.Weapons).
+			Msg("audit decision")
+		if decision == InventoryDecisionLock || decision == InventoryDecisionDiscard {
+			LogMXUSimpleHTMLWithColor(ctx, fmt.Sprintf("试运行：本应%s，未操作物品", decisionLabel(decision)), "#a0a0a0")
+		}
+		ctx.OverrideNext(taskName, []maa.NextItem{
+			{Name: "EssenceFilterRowNextItem"},
+		})
+		return
+	}
+
+	next := "EssenceFilterRowNextItem"
+	switch decision {
+	case InventoryDecisionLock:
+		next = "EssenceFilterLockItemLog"
+	case InventoryDecisionDiscard:
+		next = "EssenceFilterDiscardItemLog"
+	}
+	ctx.OverrideNext(taskName, []maa.NextItem{
+		{Name: next},
+	})
+}
+
+// recordDecision - 记录处理结果（不操作物品）：待确认列表、试运行审计与库存。
+// 续扫跳过断点中已处理的基质时也用它补记，这些记录只存在于内存或尚未落盘。
+func recordDecision(dryRun bool, skills []string, match *SkillCombinationMatch, decision, reason string) {
+	if decision == InventoryDecisionReview {
+		reviewEntries = append(reviewEntries, newAuditEntry(visitedCount, skills, match, decision, reason))
+	}
+	if !dryRun {
+		recordInventory(skills, match, decision, reason)
+		return
+	}
+	auditEntries = append(auditEntries, newAuditEntry(len(auditEntries)+1, skills, match, decision, reason))
+	// 物品实际未被操作，库存中按跳过记录，原因保留预期结果
+	recordInventory(skills, match, InventoryDecisionSkip, strings.TrimSpace("试运行（"+decisionLabel(decision)+"） "+reason))
+}
+
+// logAuditSummary - 输出试运行汇总：各处理结果数量，以及将被锁定/废弃的基质明细
+func logAuditSummary(ctx *maa.Context) {
+	counts := make(map[string]int, 4)
+	for _, e := range auditEntries {
+		counts[e.Decision]++
+	}
+
+	var b strings.Builder
+	b.WriteString(`<div style="color: #00bfff; font-weight: 900; margin-top: 4px;">试运行汇总（未操作任何物品）：</div>`)
+	b.WriteString(fmt.Sprintf(
+		`<div style="font-size: 12px;">将锁定 <span style="color: #064d7c; font-weight: 700;">%d</span> 个，将废弃 <span style="color: #ff6b6b; font-weight: 700;">%d</span> 个，待确认 %d 个，跳过 %d 个</div>`,
+		counts[InventoryDecisionLock], counts[InventoryDecisionDiscard], counts[InventoryDecisionReview], counts[InventoryDecisionSkip],
+	))
+	if len(auditEntries) > counts[InventoryDecisionSkip] {
+		b.WriteString(auditTableHTML(auditEntries))
+	}
+	LogMXUHTML(ctx, b.String())
+}
+
+// logReviewList - 输出需要人工确认的基质列表（疑似目标但识别置信度不足）
+func logReviewList(ctx *maa.Context) {
+	if len(reviewEntries) == 0 {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf(`<div style="color: #ff7000; font-weight: 900; margin-top: 4px;">待人工确认：%d 个（已跳过，未锁定也未废弃）</div>`, len(reviewEntries)))
+	b.WriteString(auditTableHTML(reviewEntries))
+	LogMXUHTML(ctx, b.String())
+}
+
+// auditTableHTML - 将非跳过的审计记录格式化为表格
+func auditTableHTML(entries []auditEntry) string {
+	var b strings.Builder
+	b.WriteString(`<table style="width: 100%; border-collapse: collapse; font-size: 12px;">`)
+	b.WriteString(`<tr><th style="text-align:left; padding: 2px 4px;">#</th><th style="text-align:left; padding: 2px 4px;">结果</th><th style="text-align:left; padding: 2px 4px;">技能</th><th style="text-align:left; padding: 2px 4px;">原因</th></tr>`)
+	for _, e := range entries {
+		if e.Decision == InventoryDecisionSkip {
+			continue
+		}
+		color := "#064d7c"
+		switch e.Decision {
+		case InventoryDecisionDiscard:
+			color = "#ff6b6b"
+		case InventoryDecisionReview:
+			color = "#ff7000"
+		}
+		skills := make([]string, 3)
+		for i := range skills {
+			skills[i] = fmt.Sprintf("%s(+%d)", escapeHTML(e.Skills[i]), e.Levels[i])
+		}
+		reason := escapeHTML(e.Reason)
+		if len(e.Weapons) > 0 && e.Decision == InventoryDecisionLock {
+			reason = "武器：" + escapeHTML(strings.Join(e.Weapons, "、"))
+		}
+		b.WriteString("<tr>")
+		b.WriteString(fmt.Sprintf(`<td style="padding: 2px 4px;">%d</td>`, e.Index))
+		b.WriteString(fmt.Sprintf(`<td style="padding: 2px 4px; color: %s;">%s</td>`, color, decisionLabel(e.Decision)))
+		b.WriteString(fmt.Sprintf(`<td style="padding: 2px 4px;">%s</td>`, strings.Join(skills, " | ")))
+		b.WriteString(fmt.Sprintf(`<td style="padding: 2px 4px;">%s</td>`, reason))
+		b.WriteString("</tr>")
+	}
+	b.WriteString(`</table>`)
+	return b.String()
+}
@@ -0,0 +1,217 @@
+package essencefilter
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+)
+
+// 组合感知解码参数
+const (
+	candidateTopK      = 3    // 每个槽位保留的候选数量
+	decodeMinSlotScore = 0.5  // 候选可参与组合的最低相似度
+	decodeMaxSlotDrop  = 0.25 // 候选相似度与该槽位最佳候选的最大差距
+	decodeAcceptScore  = 0.75 // 组合平均相似度达到该值才直接锁定
+	decodeTieMargin    = 0.05 // 非目标候选与组合候选相似度差距小于该值时视为无法区分
+	normPhasePenalty   = 0.97 // 相近字替换后的文本相似度折扣
+	coreMatchPenalty   = 0.98 // 去除停用后缀后的核心文本相似度折扣
+)
+
+// SkillCandidate - 槽位技能候选及其相似度
+type SkillCandidate struct {
+	ID    int
+	Score float64 // [0, 1]，1 表示完全一致
+}
+
+// textSimilarity - 基于编辑距离的相似度：1 - 距离 / 较长文本长度
+func textSimilarity(a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	maxLen := max(utf8.RuneCountInString(a), utf8.RuneCountInString(b))
+	return 1 - float64(editDistance(a, b, maxLen))/float64(maxLen)
+}
+
+// partialSimilarity - OCR 文本为技能名子串（截断、遮挡）时的相似度，按覆盖比例计算
+func partialSimilarity(text, target string) float64 {
+	tl, gl := utf8.RuneCountInString(text), utf8.RuneCountInString(target)
+	if tl < 2 || tl >= gl || !strings.Contains(target, text) {
+		return 0
+	}
+	return 0.5 + 0.5*float64(tl)/float64(gl)
+}
+
+// entrySimilarity - OCR 文本与技能条目的相似度：取完整文本与核心文本、原始与相近字替换后的最大值
+func entrySimilarity(e skillEntry, cleaned, core, cleanedNorm, coreNorm string) float64 {
+	score := max(
+		textSimilarity(cleaned, e.RawFull),
+		coreMatchPenalty*textSimilarity(core, e.RawCore),
+		partialSimilarity(cleaned, e.RawFull),
+	)
+	if cleanedNorm != cleaned {
+		score = max(score,
+			normPhasePenalty*textSimilarity(cleanedNorm, e.NormFull),
+			normPhasePenalty*coreMatchPenalty*textSimilarity(coreNorm, e.NormCore),
+		)
+	}
+	return score
+}
+
+// MatchSkillCandidates - 返回槽位内与 OCR 文本最相近的 k 个技能候选，按相似度降序
+func MatchSkillCandidates(slot int, ocrText string, k int) []SkillCandidate {
+	if slot < 1 || slot > 3 {
+		return nil
+	}
+	buildSlotIndicesOnce.Do(buildSlotIndices)
+	cleaned := activeLanguage.clean(ocrText)
+	if cleaned == "" {
+		return nil
+	}
+	core := trimStopSuffix(cleaned)
+	cleanedNorm := normalizeSimilar(cleaned)
+	coreNorm := trimStopSuffix(cleanedNorm)
+
+	entries := slotIndices[slot-1].entries
+	candidates := make([]SkillCandidate, 0, len(entries))
+	for _, e := range entries {
+		candidates = append(candidates, SkillCandidate{
+			ID:    e.ID,
+			Score: entrySimilarity(e, cleaned, core, cleanedNorm, coreNorm),
+		})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	return candidates
+}
+
+// decodeResult - 组合感知解码结果
+type decodeResult struct {
+	Combination SkillCombination
+	Weapons     []WeaponData
+	Score       float64 // 组合各槽位相似度的平均值
+	Accepted    bool    // 置信度足够且没有难以区分的非目标候选，可直接锁定
+}
+
+// decodeSkillCombination - 在目标组合中寻找与各槽位候选最一致的组合。
+// 组合的每个槽位都必须出现在候选中，且相似度不低于 decodeMinSlotScore、与该槽位最佳候选相差不超过 decodeMaxSlotDrop，
+// 因此读数清晰的槽位不会被"纠正"成其他技能。没有组合满足条件时返回 false。
+// 若某槽位存在与组合候选难以区分、且替换后不构成任何目标组合的候选，则结果不被接受（需人工确认）。
+func decodeSkillCombination(candidates [3][]SkillCandidate) (*decodeResult, bool) {
+	var slotScores [3]map[int]float64
+	for i, cands := range candidates {
+		if len(cands) == 0 {
+			return nil, false
+		}
+		top := cands[0].Score
+		slotScores[i] = make(map[int]float64, len(cands))
+		for _, c := range cands {
+			if c.Score >= decodeMinSlotScore && c.Score >= top-decodeMaxSlotDrop {
+				slotScores[i][c.ID] = c.Score
+			}
+		}
+	}
+
+	// 同一套技能可能对应多把武器，按技能组合聚合
+	results := make(map[string]*decodeResult)
+	var best *decodeResult
+	for _, combo := range targetSkillCombinations {
+		if len(combo.SkillIDs) != 3 {
+			continue
+		}
+		key := skillCombinationKey(combo.SkillIDs)
+		if r, ok := results[key]; ok {
+			r.Weapons = append(r.Weapons, combo.Weapon)
+			continue
+		}
+		score := 0.0
+		ok := true
+		for i, id := range combo.SkillIDs {
+			s, found := slotScores[i][id]
+			if !found {
+				ok = false
+				break
+			}
+			score += s / 3
+		}
+		if !ok {
+			continue
+		}
+		r := &decodeResult{Combination: combo, Weapons: []WeaponData{combo.Weapon}, Score: score}
+		results[key] = r
+		if best == nil || score > best.Score {
+			best = r
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+	best.Accepted = best.Score >= decodeAcceptScore && !hasCompetingCandidate(best.Combination.SkillIDs, candidates)
+	return best, true
+}
+
+// hasCompetingCandidate - 某槽位是否存在与组合候选相似度接近（或更高）、替换后却不构成目标组合的候选
+func hasCompetingCandidate(ids []int, candidates [3][]SkillCandidate) bool {
+	targetKeys := make(map[string]struct{}, len(targetSkillCombinations))
+	for _, combo := range targetSkillCombinations {
+		targetKeys[skillCombinationKey(combo.SkillIDs)] = struct{}{}
+	}
+	for i, cands := range candidates {
+		chosen := 0.0
+		for _, c := range cands {
+			if c.ID == ids[i] {
+				chosen = c.Score
+			}
+		}
+		for _, c := range cands {
+			if c.ID == ids[i] || c.Score < chosen-decodeTieMargin {
+				continue
+			}
+			alt := append([]int(nil), ids...)
+			alt[i] = c.ID
+			if _, ok := targetKeys[skillCombinationKey(alt)]; !ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchEssenceSkillsDecoded - 逐槽精确匹配失败时的兜底：用 top-k 候选做组合感知解码。
+// 置信度足够时返回可锁定的匹配；存在可能的目标组合但置信度不足时返回 Ambiguous 的匹配（需人工确认）。
+func matchEssenceSkillsDecoded(ocrSkills []string) (*SkillCombinationMatch, bool) {
+	var candidates [3][]SkillCandidate
+	for i, skill := range ocrSkills {
+		candidates[i] = MatchSkillCandidates(i+1, skill, candidateTopK)
+	}
+	result, ok := decodeSkillCombination(candidates)
+	if !ok {
+		return nil, false
+	}
+
+	match := &SkillCombinationMatch{
+		SkillIDs:      append([]int(nil), result.Combination.SkillIDs...),
+		SkillsChinese: append([]string(nil), result.Combination.SkillsChinese...),
+		Weapons:       result.Weapons,
+		Confidence:    result.Score,
+		Decoded:       true,
+		Ambiguous:     !result.Accepted,
+	}
+	log.Info().
+		Str("component", "EssenceFilter").
+		Str("step", "DecodeCombination").
+		Strs("ocr_skills", ocrSkills).
+		Ints("skill_ids", match.SkillIDs).
+		Float64("score", result.Score).
+		Bool("accepted", result.Accepted).
+		Msg("combination decoded")
+	return match, result.Accepted
+}
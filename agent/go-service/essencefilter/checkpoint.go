@@ -0,0 +1,235 @@
+package essencefilter
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// 扫描断点文件格式版本
+const scanCheckpointVersion = 1
+
+// 未指定路径时的断点文件
+const defaultCheckpointPath = "config/essence_scan_checkpoint.json"
+
+// checkpointItem - 断点中一个已处理的基质
+type checkpointItem struct {
+	Decision string   `json:"decision"`
+	Reason   string   `json:"reason,omitempty"`
+	Weapons  []string `json:"weapons,omitempty"`
+}
+
+// match - 还原记录库存与审计所需的匹配结果（只含武器名）
+func (item checkpointItem) match() *SkillCombinationMatch {
+	if len(item.Weapons) == 0 {
+		return nil
+	}
+	m := &SkillCombinationMatch{}
+	for _, w := range item.Weapons {
+		m.Weapons = append(m.Weapons, WeaponData{ChineseName: w})
+	}
+	return m
+}
+
+// ScanCheckpoint - 扫描进度断点：正在处理的行、各计数器与已处理的基质。
+// 已处理基质以 "<行>:<行内序号>|<基质指纹>" 为键，位置与技能签名都一致才视为同一基质。
+// 每处理一个基质、每滑动一行都会落盘；扫描正常结束时删除。
+type ScanCheckpoint struct {
+	Version   int                       `json:"version"`
+	Signature string                    `json:"signature"` // 影响处理结果的选项摘要，不一致时不可续扫
+	Row       int                       `json:"row"`
+	Visited   int                       `json:"visited"`
+	Matched   int                       `json:"matched"`
+	Counters  map[string]int            `json:"counters"` // 扩展规则等计数
+	Items     map[string]checkpointItem `json:"items"`
+	UpdatedAt time.Time                 `json:"updated_at"`
+
+	path string
+}
+
+// scanCheckpointSignature - 计算影响处理结果的选项摘要（稀有度、基质类型、规则、扩展规则、试运行、语言等）。
+// 规则文件只以路径出现在选项中，因此另外计入其内容摘要，修改规则文件后不可续扫。
+func scanCheckpointSignature(opts *EssenceFilterOptions) string {
+	key := *opts
+	// 与处理结果无关的选项不参与摘要
+	key.ResumeScan = false
+	key.CheckpointPath = ""
+	key.RecordInventory, key.InventoryPath, key.ExportInventory = false, "", false
+	key.ExportCalculatorScript, key.PlannerPriorityPath, key.PlannerOutputPath = false, "", ""
+	data, _ := json.Marshal(struct {
+		Options   EssenceFilterOptions `json:"options"`
+		RulesFile string               `json:"rules_file,omitempty"`
+	}{key, targetRulesFileDigest(key.TargetRulesPath)})
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// targetRulesFileDigest - 规则文件内容摘要；未指定或文件不存在时为空
+func targetRulesFileDigest(path string) string {
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// newScanCheckpoint - 开始一次新的扫描断点
+func newScanCheckpoint(path, signature string) *ScanCheckpoint {
+	return &ScanCheckpoint{
+		Version:   scanCheckpointVersion,
+		Signature: signature,
+		Row:       1,
+		Counters:  make(map[string]int),
+		Items:     make(map[string]checkpointItem),
+		path:      path,
+	}
+}
+
+// LoadScanCheckpoint - 读取断点；文件不存在时返回 nil, nil，签名不一致时返回错误
+func LoadScanCheckpoint(path, signature string) (*ScanCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp ScanCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if cp.Version != scanCheckpointVersion {
+		return nil, fmt.Errorf("unsupported checkpoint version %d", cp.Version)
+	}
+	if cp.Signature != signature {
+		return nil, fmt.Errorf("checkpoint was created with different options")
+	}
+	if cp.Counters == nil {
+		cp.Counters = make(map[string]int)
+	}
+	if cp.Items == nil {
+		cp.Items = make(map[string]checkpointItem)
+	}
+	cp.path = path
+	return &cp, nil
+}
+
+// Save - 写回断点文件（先写临时文件再替换）
+func (cp *ScanCheckpoint) Save() error {
+	cp.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cp.path), 0755); err != nil {
+		return err
+	}
+	tmp := cp.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cp.path)
+}
+
+// Remove - 删除断点文件
+func (cp *ScanCheckpoint) Remove() error {
+	if err := os.Remove(cp.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// currentItemKey - 当前基质在断点中的键：行、行内序号与基质指纹
+func currentItemKey(skills []string) string {
+	var s [3]string
+	copy(s[:], skills)
+	ids, _ := ocrSkillIDsBySlot(skills)
+	return fmt.Sprintf("%d:%d|%s", currentRow, rowIndex-1, inventoryFingerprint(currentEssenceType, s, ids, currentSkillLevels))
+}
+
+// checkpointDecided - 当前基质是否已在断点中处理过
+func checkpointDecided(skills []string) (checkpointItem, bool) {
+	if scanCheckpoint == nil {
+		return checkpointItem{}, false
+	}
+	item, ok := scanCheckpoint.Items[currentItemKey(skills)]
+	return item, ok
+}
+
+// checkpointRecord - 暂存当前基质的处理结果。
+// 锁定/废弃由后续流水线节点完成，因此要等到流程回到 RowNextItem（即操作已完成）时才由 checkpointCommit 落盘，
+// 避免中断在操作完成前发生时把未处理的基质记为已处理。
+func checkpointRecord(skills []string, match *SkillCombinationMatch, decision, reason string) {
+	if scanCheckpoint == nil {
+		return
+	}
+	item := checkpointItem{Decision: decision, Reason: reason}
+	if match != nil {
+		for _, w := range match.Weapons {
+			item.Weapons = append(item.Weapons, w.ChineseName)
+		}
+	}
+	pendingCheckpointKey = currentItemKey(skills)
+	pendingCheckpointItem = item
+}
+
+// checkpointCommit - 将暂存的处理结果与最新计数落盘
+func checkpointCommit() {
+	if scanCheckpoint == nil || pendingCheckpointKey == "" {
+		return
+	}
+	scanCheckpoint.Items[pendingCheckpointKey] = pendingCheckpointItem
+	pendingCheckpointKey = ""
+	saveCheckpoint()
+}
+
+// saveCheckpoint - 同步全局进度到断点并落盘（未开启断点时不做任何事）
+func saveCheckpoint() {
+	if scanCheckpoint == nil {
+		return
+	}
+	scanCheckpoint.Row = max(currentRow, resumeRow) // 快进途中中断时保留原断点行
+	scanCheckpoint.Visited = visitedCount
+	scanCheckpoint.Matched = matchedCount
+	scanCheckpoint.Counters["future_promising"] = extFuturePromisingCount
+	scanCheckpoint.Counters["slot3_practical"] = extSlot3PracticalCount
+	scanCheckpoint.Counters["target_rule"] = extTargetRuleCount
+	scanCheckpoint.Counters["excluded"] = excludedCount
+	if err := scanCheckpoint.Save(); err != nil {
+		log.Warn().Err(err).Str("component", "EssenceFilter").Str("path", scanCheckpoint.path).Msg("save checkpoint failed")
+	}
+}
+
+// restoreFromCheckpoint - 从断点恢复计数，并设置需要快进跳过的行
+func restoreFromCheckpoint(cp *ScanCheckpoint) {
+	visitedCount = cp.Visited
+	matchedCount = cp.Matched
+	extFuturePromisingCount = cp.Counters["future_promising"]
+	extSlot3PracticalCount = cp.Counters["slot3_practical"]
+	extTargetRuleCount = cp.Counters["target_rule"]
+	excludedCount = cp.Counters["excluded"]
+	resumeRow = cp.Row
+}
+
+// closeCheckpoint - 扫描正常结束：删除断点文件并释放
+func closeCheckpoint() {
+	if scanCheckpoint == nil {
+		return
+	}
+	if err := scanCheckpoint.Remove(); err != nil {
+		log.Warn().Err(err).Str("component", "EssenceFilter").Str("path", scanCheckpoint.path).Msg("remove checkpoint failed")
+	}
+	scanCheckpoint = nil
+	pendingCheckpointKey = ""
+	resumeRow = 0
+}
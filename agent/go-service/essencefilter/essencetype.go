@@ -0,0 +1,152 @@
+package essencefilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/minicv"
+)
+
+// 基质类型定义文件格式版本
+const essenceTypesVersion = 1
+
+// 基质格子中用于颜色判定的区域：跳过上方的图标，只取底部的品质色条
+const essenceColorROIOffsetY = 90
+
+// EssenceMeta - 一种基质类型及其颜色特征（来自 essence_types.json）
+type EssenceMeta struct {
+	ID      string       `json:"id"` // 选项中引用的标识
+	Name    string       `json:"name"`
+	English string       `json:"english"`
+	Ranges  []ColorRange `json:"ranges"`   // 品质色条的 HSV 范围，命中任一即可
+	MinArea int          `json:"min_area"` // 最大连通区域的最小像素数，缺省为 essenceColorMatchMinCount
+}
+
+// essenceTypesFile - essence_types.json
+type essenceTypesFile struct {
+	Version int           `json:"version"`
+	Types   []EssenceMeta `json:"types"`
+}
+
+// LoadEssenceTypes - 加载基质类型定义；新的基质品质只需在数据文件中追加一项
+func LoadEssenceTypes(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var f essenceTypesFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	if f.Version > essenceTypesVersion {
+		return fmt.Errorf("unsupported essence types version %d", f.Version)
+	}
+	seen := make(map[string]bool, len(f.Types))
+	for i := range f.Types {
+		t := &f.Types[i]
+		switch {
+		case t.ID == "" || t.Name == "":
+			return fmt.Errorf("essence type #%d has no id or name", i+1)
+		case seen[t.ID]:
+			return fmt.Errorf("duplicate essence type %q", t.ID)
+		case len(t.Ranges) == 0:
+			return fmt.Errorf("essence type %q has no color ranges", t.ID)
+		}
+		seen[t.ID] = true
+		if t.MinArea <= 0 {
+			t.MinArea = essenceColorMatchMinCount
+		}
+	}
+	essenceTypeDefs = f.Types
+	return nil
+}
+
+// essenceTypeByID - 按 ID 查找已加载的基质类型
+func essenceTypeByID(id string) (EssenceMeta, bool) {
+	for _, t := range essenceTypeDefs {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return EssenceMeta{}, false
+}
+
+// selectEssenceTypes - 由选项得到本次处理的基质类型：essence_types 列出的 ID，
+// 以及兼容旧选项的 flawless_essence / pure_essence
+func selectEssenceTypes(opts *EssenceFilterOptions) ([]EssenceMeta, error) {
+	ids := make([]string, 0, len(opts.EssenceTypes)+2)
+	if opts.FlawlessEssence {
+		ids = append(ids, "flawless")
+	}
+	if opts.PureEssence {
+		ids = append(ids, "pure")
+	}
+	ids = append(ids, opts.EssenceTypes...)
+
+	var selected []EssenceMeta
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		t, ok := essenceTypeByID(id)
+		if !ok {
+			return nil, fmt.Errorf("unknown essence type %q", id)
+		}
+		selected = append(selected, t)
+	}
+	return selected, nil
+}
+
+// essenceColorROI - 格子内用于颜色判定的区域，过小时返回 false
+func essenceColorROI(box [4]int) (image.Rectangle, bool) {
+	if box[2] <= 0 || box[3] <= essenceColorROIOffsetY {
+		return image.Rectangle{}, false
+	}
+	return image.Rect(box[0], box[1]+essenceColorROIOffsetY, box[0]+box[2], box[1]+box[3]), true
+}
+
+// classifyEssence - 一次遍历区域内像素，同时计算各基质类型的颜色掩码，
+// 返回最大连通区域达到阈值且面积最大的类型；均未达到时 ok 为 false。
+// 与原 EssenceColorMatch 节点（ColorMatch，method 40，connected）的判定一致。
+func classifyEssence(img *image.RGBA, roi image.Rectangle, types []EssenceMeta) (EssenceMeta, int, bool) {
+	roi = roi.Intersect(img.Bounds())
+	masks := make([]*minicv.BinaryImage, len(types))
+	counts := make([]int, len(types))
+	for i := range types {
+		masks[i] = minicv.NewBinaryImage(roi.Dx(), roi.Dy())
+	}
+	for y := roi.Min.Y; y < roi.Max.Y; y++ {
+		for x := roi.Min.X; x < roi.Max.X; x++ {
+			h, s, v := minicv.GetPixelHSV(img, x, y)
+			idx := (y-roi.Min.Y)*roi.Dx() + (x - roi.Min.X)
+			for i, t := range types {
+				for _, r := range t.Ranges {
+					if r.Contains(h, s, v) {
+						masks[i].Pix[idx] = true
+						counts[i]++
+						break
+					}
+				}
+			}
+		}
+	}
+
+	best, bestArea := -1, 0
+	for i, t := range types {
+		// 像素总数不足时连通区域也不可能达到阈值，跳过连通域计算
+		if counts[i] < t.MinArea || counts[i] <= bestArea {
+			continue
+		}
+		if area := minicv.LargestComponentArea(masks[i]); area >= t.MinArea && area > bestArea {
+			best, bestArea = i, area
+		}
+	}
+	if best < 0 {
+		return EssenceMeta{}, 0, false
+	}
+	return types[best], bestArea, true
+}
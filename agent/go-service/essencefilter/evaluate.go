@@ -0,0 +1,527 @@
+package essencefilter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// MatcherCorpus - 匹配器离线评估用的标注语料（默认位于 matcher_config.json 同目录的 matcher_corpus.json）。
+//
+// 每条语料是一次 OCR 输出及其正确技能；skill_id 为 0 表示该文本不应匹配任何技能（负样本）。
+// language 为语料对应的客户端语言，缺省为简体中文。
+// source 说明语料来源：ocr-log 为由 SeedMatcherCorpus 从真实运行日志导出并经人工核对的语料，
+// synthetic 为按常见误识手工构造的语料，其评估结果只能说明匹配器能处理这些构造的错误。
+type MatcherCorpus struct {
+	Language string               `json:"language"`
+	Source   string               `json:"source,omitempty"`
+	Entries  []MatcherCorpusEntry `json:"entries"`
+}
+
+// 语料来源
+const (
+	MatcherCorpusSourceOCRLog    = "ocr-log"
+	MatcherCorpusSourceSynthetic = "synthetic"
+)
+
+// MatcherCorpusEntry - 一条标注的 OCR 文本
+type MatcherCorpusEntry struct {
+	Slot    int    `json:"slot"`
+	OCR     string `json:"ocr"`
+	SkillID int    `json:"skill_id"`
+	Note    string `json:"note,omitempty"`
+}
+
+// MatcherEvalOptions - 离线评估参数
+type MatcherEvalOptions struct {
+	// DataDir 为 weapons_data.json 与 matcher_config.json 所在目录
+	DataDir string
+	// CorpusPath 为标注语料路径
+	CorpusPath string
+	// Language 覆盖语料中的语言，为空时使用语料设置
+	Language string
+	// MinSuggestCount 为相近字建议所需的最少出现次数
+	MinSuggestCount int
+}
+
+// MatcherSlotStats - 单个槽位（或整体）的匹配统计；
+// 精确率/召回率的分母为 0（没有预测或没有正样本）时为 nil，表示无法评估
+type MatcherSlotStats struct {
+	Entries       int      `json:"entries"`
+	Positives     int      `json:"positives"`      // 有正确技能的语料数
+	Predicted     int      `json:"predicted"`      // 匹配器给出结果的语料数
+	Correct       int      `json:"correct"`        // 结果与标注一致
+	Wrong         int      `json:"wrong"`          // 正样本匹配到错误技能
+	Missed        int      `json:"missed"`         // 正样本未匹配
+	FalsePositive int      `json:"false_positive"` // 负样本被匹配
+	Precision     *float64 `json:"precision"`
+	Recall        *float64 `json:"recall"`
+}
+
+// MatcherStepStats - 单个匹配阶段/步骤的命中统计
+type MatcherStepStats struct {
+	Hits      int     `json:"hits"`
+	Correct   int     `json:"correct"`
+	Precision float64 `json:"precision"`
+}
+
+// MatcherFailure - 一条匹配错误的语料
+type MatcherFailure struct {
+	Slot     int    `json:"slot"`
+	OCR      string `json:"ocr"`
+	Expected string `json:"expected"`
+	Got      string `json:"got"`
+	Step     string `json:"step,omitempty"`
+}
+
+// SimilarWordSuggestion - 从误识中归纳出的相近字建议，Fixed/Broken 为加入该条目后重新评估的变化
+type SimilarWordSuggestion struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Count  int    `json:"count"`
+	Fixed  int    `json:"fixed"`
+	Broken int    `json:"broken"`
+}
+
+// MatcherEvalReport - 离线评估结果
+type MatcherEvalReport struct {
+	Corpus      string                       `json:"corpus"`
+	Source      string                       `json:"source"`
+	Language    string                       `json:"language"`
+	Slots       [3]MatcherSlotStats          `json:"slots"`
+	Overall     MatcherSlotStats             `json:"overall"`
+	Steps       map[string]*MatcherStepStats `json:"steps"`
+	Failures    []MatcherFailure             `json:"failures"`
+	Suggestions []SimilarWordSuggestion      `json:"suggestions"`
+}
+
+// matcherEvalResult - 单条语料的匹配结果
+type matcherEvalResult struct {
+	id   int
+	step string
+	ok   bool
+}
+
+// correct - 匹配结果是否与标注一致（负样本要求不匹配）
+func (r matcherEvalResult) correct(e MatcherCorpusEntry) bool {
+	if e.SkillID == 0 {
+		return !r.ok
+	}
+	return r.ok && r.id == e.SkillID
+}
+
+// RunMatcherEvaluation - 加载武器数据库、匹配器配置与标注语料，逐条运行 matchSkillIDEnhanced 并统计。
+// 评估会切换全局技能语言与匹配配置，只应在离线工具中调用。
+func RunMatcherEvaluation(opts MatcherEvalOptions) (*MatcherEvalReport, error) {
+	if err := loadMatcherData(opts.DataDir); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(opts.CorpusPath)
+	if err != nil {
+		return nil, err
+	}
+	var corpus MatcherCorpus
+	if err := json.Unmarshal(data, &corpus); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", opts.CorpusPath, err)
+	}
+	lang := opts.Language
+	if lang == "" {
+		lang = corpus.Language
+	}
+	if err := SetSkillLanguage(lang); err != nil {
+		return nil, err
+	}
+	for i, e := range corpus.Entries {
+		if e.Slot < 1 || e.Slot > 3 {
+			return nil, fmt.Errorf("entry %d: slot %d out of range", i, e.Slot)
+		}
+		if e.SkillID != 0 && skillNameByID(e.SkillID, getPoolBySlot(e.Slot)) == "" {
+			return nil, fmt.Errorf("entry %d: unknown skill id %d in slot %d", i, e.SkillID, e.Slot)
+		}
+	}
+
+	report := &MatcherEvalReport{
+		Corpus:   opts.CorpusPath,
+		Source:   corpus.Source,
+		Language: activeLanguage.Code,
+		Steps:    make(map[string]*MatcherStepStats),
+	}
+	results := evaluateCorpus(corpus.Entries)
+	for i, e := range corpus.Entries {
+		r := results[i]
+		report.Slots[e.Slot-1].add(e, r)
+		report.Overall.add(e, r)
+		if r.ok {
+			st := report.Steps[r.step]
+			if st == nil {
+				st = &MatcherStepStats{}
+				report.Steps[r.step] = st
+			}
+			st.Hits++
+			if r.correct(e) {
+				st.Correct++
+			}
+		}
+		if !r.correct(e) {
+			report.Failures = append(report.Failures, MatcherFailure{
+				Slot:     e.Slot,
+				OCR:      e.OCR,
+				Expected: skillNameByID(e.SkillID, getPoolBySlot(e.Slot)),
+				Got:      skillNameByID(r.id, getPoolBySlot(e.Slot)),
+				Step:     r.step,
+			})
+		}
+	}
+	for i := range report.Slots {
+		report.Slots[i].finish()
+	}
+	report.Overall.finish()
+	for _, st := range report.Steps {
+		st.Precision = float64(st.Correct) / float64(st.Hits)
+	}
+
+	minCount := max(1, opts.MinSuggestCount)
+	for _, s := range collectConfusions(corpus.Entries, results) {
+		if s.Count < minCount {
+			continue
+		}
+		s.Fixed, s.Broken = trialSimilarWord(corpus.Entries, results, s.From, s.To)
+		report.Suggestions = append(report.Suggestions, s)
+	}
+	return report, nil
+}
+
+// SeedMatcherCorpus - 从 go-service 日志中提取技能 OCR 结果（"OCR ok" 记录），生成待人工核对的语料。
+// 同一槽位的相同文本只保留一条；skill_id 由当前匹配器预标注，并在 note 中注明，需逐条核对后再用于评估：
+// 预标注错误的要改正，匹配器未匹配的条目 skill_id 为 0，应确认其为负样本或补上正确技能。
+func SeedMatcherCorpus(dataDir, logPath, lang string) (*MatcherCorpus, error) {
+	if err := loadMatcherData(dataDir); err != nil {
+		return nil, err
+	}
+	if err := SetSkillLanguage(lang); err != nil {
+		return nil, err
+	}
+	buildSlotIndicesOnce.Do(buildSlotIndices)
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	corpus := &MatcherCorpus{Language: activeLanguage.Code, Source: MatcherCorpusSourceOCRLog}
+	seen := make(map[MatcherCorpusEntry]bool)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var line struct {
+			Component string `json:"component"`
+			Message   string `json:"message"`
+			Slot      int    `json:"slot"`
+			Skill     string `json:"skill"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &line) != nil || line.Component != "EssenceFilter" || line.Message != "OCR ok" {
+			continue
+		}
+		e := MatcherCorpusEntry{Slot: line.Slot, OCR: line.Skill}
+		if e.Slot < 1 || e.Slot > 3 || activeLanguage.clean(e.OCR) == "" || seen[e] {
+			continue
+		}
+		seen[e] = true
+		if id, step, ok := matchSkillIDWithStep(e.Slot, e.OCR); ok {
+			e.SkillID = id
+			e.Note = "待核对：匹配器预标注 " + step
+		} else {
+			e.Note = "待核对：匹配器未匹配"
+		}
+		corpus.Entries = append(corpus.Entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return corpus, nil
+}
+
+// loadMatcherData - 加载离线工具所需的武器数据库与匹配器配置
+func loadMatcherData(dataDir string) error {
+	if _, err := LoadWeaponDatabase(filepath.Join(dataDir, "weapons_data.json"), ""); err != nil {
+		return fmt.Errorf("load weapon database: %w", err)
+	}
+	if err := LoadMatcherConfig(filepath.Join(dataDir, "matcher_config.json")); err != nil {
+		return fmt.Errorf("load matcher config: %w", err)
+	}
+	return nil
+}
+
+// evaluateCorpus - 逐条运行匹配器
+func evaluateCorpus(entries []MatcherCorpusEntry) []matcherEvalResult {
+	buildSlotIndicesOnce.Do(buildSlotIndices)
+	results := make([]matcherEvalResult, len(entries))
+	for i, e := range entries {
+		results[i].id, results[i].step, results[i].ok = matchSkillIDWithStep(e.Slot, e.OCR)
+	}
+	return results
+}
+
+// add - 累计一条语料的结果
+func (s *MatcherSlotStats) add(e MatcherCorpusEntry, r matcherEvalResult) {
+	s.Entries++
+	if r.ok {
+		s.Predicted++
+	}
+	switch {
+	case e.SkillID == 0:
+		if r.ok {
+			s.FalsePositive++
+		}
+	case !r.ok:
+		s.Positives++
+		s.Missed++
+	case r.id == e.SkillID:
+		s.Positives++
+		s.Correct++
+	default:
+		s.Positives++
+		s.Wrong++
+	}
+}
+
+// finish - 计算精确率与召回率
+func (s *MatcherSlotStats) finish() {
+	s.Precision = ratio(s.Correct, s.Predicted)
+	s.Recall = ratio(s.Correct, s.Positives)
+}
+
+// ratio - 比例；分母为 0 时返回 nil，由调用方显示为 N/A
+func ratio(a, b int) *float64 {
+	if b == 0 {
+		return nil
+	}
+	v := float64(a) / float64(b)
+	return &v
+}
+
+// collectConfusions - 对比匹配失败的 OCR 文本与正确技能名，统计逐字替换（误识字 → 正确字）。
+// 误识字本身出现在某个技能名中时，直接全局替换会破坏正确文本，改为带前一个字的双字条目（如 "力运" → "力量"）。
+// 已在配置中的条目不再建议。
+func collectConfusions(entries []MatcherCorpusEntry, results []matcherEvalResult) []SimilarWordSuggestion {
+	skillChars := make(map[rune]bool)
+	for slot := 1; slot <= 3; slot++ {
+		for _, s := range getPoolBySlot(slot) {
+			for _, r := range activeLanguage.clean(activeLanguage.skillName(s)) {
+				skillChars[r] = true
+			}
+		}
+	}
+	existing := activeMatcherConfig().SimilarWordMap
+
+	counts := make(map[[2]string]int)
+	for i, e := range entries {
+		if e.SkillID == 0 || results[i].correct(e) {
+			continue
+		}
+		ocr := []rune(activeLanguage.clean(e.OCR))
+		want := []rune(activeLanguage.clean(activeLanguage.skillName(skillByID(e.Slot, e.SkillID))))
+		for _, pos := range alignSubstitutions(ocr, want) {
+			from, to := string(ocr[pos[0]]), string(want[pos[1]])
+			if skillChars[ocr[pos[0]]] {
+				if pos[0] == 0 || pos[1] == 0 || ocr[pos[0]-1] != want[pos[1]-1] {
+					continue
+				}
+				from, to = string(ocr[pos[0]-1:pos[0]+1]), string(want[pos[1]-1:pos[1]+1])
+			}
+			if _, ok := existing[from]; ok {
+				continue
+			}
+			counts[[2]string{from, to}]++
+		}
+	}
+
+	suggestions := make([]SimilarWordSuggestion, 0, len(counts))
+	for k, n := range counts {
+		suggestions = append(suggestions, SimilarWordSuggestion{From: k[0], To: k[1], Count: n})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Count != suggestions[j].Count {
+			return suggestions[i].Count > suggestions[j].Count
+		}
+		return suggestions[i].From < suggestions[j].From
+	})
+	return suggestions
+}
+
+// alignSubstitutions - 编辑距离对齐 a 与 b，返回替换操作的位置对 [a 下标, b 下标]
+func alignSubstitutions(a, b []rune) [][2]int {
+	la, lb := len(a), len(b)
+	dp := make([][]int, la+1)
+	for i := range dp {
+		dp[i] = make([]int, lb+1)
+		dp[i][0] = i
+	}
+	for j := 0; j <= lb; j++ {
+		dp[0][j] = j
+	}
+	for i := 1; i <= la; i++ {
+		for j := 1; j <= lb; j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			dp[i][j] = min3(dp[i-1][j]+1, dp[i][j-1]+1, dp[i-1][j-1]+cost)
+		}
+	}
+
+	var subs [][2]int
+	for i, j := la, lb; i > 0 && j > 0; {
+		switch {
+		case a[i-1] == b[j-1] && dp[i][j] == dp[i-1][j-1]:
+			i, j = i-1, j-1
+		case dp[i][j] == dp[i-1][j-1]+1:
+			subs = append(subs, [2]int{i - 1, j - 1})
+			i, j = i-1, j-1
+		case dp[i][j] == dp[i-1][j]+1:
+			i--
+		default:
+			j--
+		}
+	}
+	return subs
+}
+
+// skillByID - 按槽位与 ID 查找技能
+func skillByID(slot, id int) SkillPool {
+	for _, s := range getPoolBySlot(slot) {
+		if s.ID == id {
+			return s
+		}
+	}
+	return SkillPool{}
+}
+
+// trialSimilarWord - 临时加入一条相近字映射重新评估，返回被修正与被破坏的语料数
+func trialSimilarWord(entries []MatcherCorpusEntry, base []matcherEvalResult, from, to string) (fixed, broken int) {
+	cfg := activeMatcherConfig()
+	trial := make(map[string]string, len(cfg.SimilarWordMap)+1)
+	for k, v := range cfg.SimilarWordMap {
+		trial[k] = v
+	}
+	trial[from] = to
+	restore := setActiveSimilarWordMap(trial)
+	defer restore()
+
+	for i, r := range evaluateCorpus(entries) {
+		was, now := base[i].correct(entries[i]), r.correct(entries[i])
+		switch {
+		case !was && now:
+			fixed++
+		case was && !now:
+			broken++
+		}
+	}
+	return fixed, broken
+}
+
+// setActiveSimilarWordMap - 替换当前语言的相近字映射，返回恢复函数
+func setActiveSimilarWordMap(m map[string]string) (restore func()) {
+	code := activeLanguage.Code
+	if cfg, ok := matcherConfig.Languages[code]; ok {
+		trial := cfg
+		trial.SimilarWordMap = m
+		matcherConfig.Languages[code] = trial
+		return func() { matcherConfig.Languages[code] = cfg }
+	}
+	if code == defaultClientLanguage {
+		old := matcherConfig.SimilarWordMap
+		matcherConfig.SimilarWordMap = m
+		return func() { matcherConfig.SimilarWordMap = old }
+	}
+	if matcherConfig.Languages == nil {
+		matcherConfig.Languages = make(map[string]LanguageMatcherConfig)
+	}
+	matcherConfig.Languages[code] = LanguageMatcherConfig{SimilarWordMap: m}
+	return func() { delete(matcherConfig.Languages, code) }
+}
+
+// WriteText - 输出可读的评估报告
+func (r *MatcherEvalReport) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "EssenceFilter matcher evaluation: %s (%s)\n", r.Corpus, r.Language)
+	if r.Source != MatcherCorpusSourceOCRLog {
+		fmt.Fprintf(w, "warning: corpus source is %q, not real OCR logs; results only cover the constructed errors\n", orDash(r.Source))
+	}
+	writeStats := func(name string, s MatcherSlotStats) {
+		fmt.Fprintf(w, "%-8s entries %4d  correct %4d  wrong %3d  missed %3d  false+ %3d  precision %6s  recall %6s\n",
+			name, s.Entries, s.Correct, s.Wrong, s.Missed, s.FalsePositive, formatRatio(s.Precision), formatRatio(s.Recall))
+	}
+	for i, s := range r.Slots {
+		writeStats(fmt.Sprintf("slot %d", i+1), s)
+	}
+	writeStats("overall", r.Overall)
+
+	steps := make([]string, 0, len(r.Steps))
+	for step := range r.Steps {
+		steps = append(steps, step)
+	}
+	sort.Strings(steps)
+	fmt.Fprintln(w, "\n== steps ==")
+	for _, step := range steps {
+		st := r.Steps[step]
+		fmt.Fprintf(w, "%-26s hits %4d  correct %4d  precision %5.1f%%\n", step, st.Hits, st.Correct, st.Precision*100)
+	}
+
+	if len(r.Failures) > 0 {
+		fmt.Fprintln(w, "\n== failures ==")
+		for _, f := range r.Failures {
+			fmt.Fprintf(w, "slot %d  %-16q expected %-12s got %-12s %s\n", f.Slot, f.OCR, orDash(f.Expected), orDash(f.Got), f.Step)
+		}
+	}
+	if len(r.Suggestions) > 0 {
+		fmt.Fprintln(w, "\n== similarWordMap suggestions ==")
+		for _, s := range r.Suggestions {
+			fmt.Fprintf(w, "%q: %q  seen %d  fixed %d  broken %d\n", s.From, s.To, s.Count, s.Fixed, s.Broken)
+		}
+	}
+}
+
+// orDash - 空技能名显示为 "-"
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// formatRatio - 百分比显示，无法评估时显示 N/A
+func formatRatio(v *float64) string {
+	if v == nil {
+		return "N/A"
+	}
+	return fmt.Sprintf("%.1f%%", *v*100)
+}
+
+// MinRecall - 各槽位召回率的最小值；任一槽位无法评估时 ok 为 false
+func (r *MatcherEvalReport) MinRecall() (float64, bool) {
+	return minRatio(r.Slots[:], func(s MatcherSlotStats) *float64 { return s.Recall })
+}
+
+// MinPrecision - 各槽位精确率的最小值；任一槽位无法评估时 ok 为 false
+func (r *MatcherEvalReport) MinPrecision() (float64, bool) {
+	return minRatio(r.Slots[:], func(s MatcherSlotStats) *float64 { return s.Precision })
+}
+
+func minRatio(slots []MatcherSlotStats, get func(MatcherSlotStats) *float64) (float64, bool) {
+	v := 1.0
+	for _, s := range slots {
+		p := get(s)
+		if p == nil {
+			return 0, false
+		}
+		v = math.Min(v, *p)
+	}
+	return v, true
+}
@@ -0,0 +1,360 @@
+package essencefilter
+
+import (
+	"image"
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/minicv"
+	"github.com/rs/zerolog/log"
+)
+
+// 网格跟踪参数
+const (
+	gridLearnRate      = 0.25 // 行距、列距与滑动位移的学习率（新观测的权重）
+	gridStuckMove      = 1.5  // 滑动后内容位移小于该值（px）视为已到列表边界
+	gridMinScrollRatio = 0.5  // 校准滑动比例的合理范围
+	gridMaxScrollRatio = 3.0
+	gridMatchMinScore  = 0.8  // 滑动前后行横条匹配的最低 NCC
+	gridMatchSlackX    = 3    // 行横条匹配允许的水平偏移（px）
+	gridMatchMargin    = 0.05 // 远离预期位移的匹配须高出该得分才被采用
+	gridMatchMinStd    = 4.0  // 横条灰度标准差低于该值视为空白
+)
+
+// 校准滑动的 pendingKind
+const gridCalibrateKind = "calibrate"
+
+// gridRow - 一次识别中位于同一行的格子
+type gridRow struct {
+	Y     int
+	Boxes [][4]int // 按 X 排序
+}
+
+// gridTracker - 由识别到的格子推断背包网格（行距、列距、列数），并跟踪当前逻辑行在屏幕上的位置。
+// 每次滑动后在新画面中匹配滑动前当前行的横条得到实际位移（匹配失败时按学习到的滑动位移预测），
+// 再吸附到观测到的行格上，因此滑动过冲或不足不会把相邻行误认为当前行；
+// 列表到底滑不动时位移为 0，后续行在屏幕内继续定位。
+type gridTracker struct {
+	// 几何，均由识别结果推断
+	boxW, boxH int
+	colPitch   float64
+	rowPitch   float64
+	originX    int // 第一列左边界
+	alignY     int // 行对齐基准：首次观测到的首行 Y
+	columns    int // 一整行的格子数
+	total      int // 基质总数（背包计数 OCR），0 表示未知
+
+	// 滚动状态
+	anchored    bool
+	rowY        float64            // 当前逻辑行在屏幕上的 Y
+	pendingMove float64            // 最近一次滑动预期带动的内容位移（向上为正）
+	pendingKind string             // 最近一次滑动的来源：滑动节点名或 gridCalibrateKind
+	swipeMove   map[string]float64 // 各滑动节点实际带动的内容位移（学习值）
+	scrollRatio float64            // 校准滑动：手指滑动距离 / 内容位移（学习值）
+	stuck       bool               // 最近一次滑动未带动内容（已到列表边界）
+	frame       *image.RGBA        // 最近一次定位时的画面，用于测量下一次滑动的实际位移
+	frameRows   []int              // 该画面中完整可见的行 Y
+}
+
+// newGridTracker - 创建尚未观测到任何格子的网格
+func newGridTracker() *gridTracker {
+	return &gridTracker{
+		swipeMove:   make(map[string]float64),
+		scrollRatio: calibrateScrollRatio,
+	}
+}
+
+// groupGridRows - 按 Y 将格子聚成行，行内按 X 排序；Y 相差不超过半个格高视为同一行
+func groupGridRows(boxes [][4]int) []gridRow {
+	if len(boxes) == 0 {
+		return nil
+	}
+	sorted := append([][4]int(nil), boxes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i][1] < sorted[j][1] })
+	tol := medianInt(boxColumn(sorted, 3)) / 2
+
+	var rows []gridRow
+	for _, b := range sorted {
+		if n := len(rows); n > 0 && b[1]-rows[n-1].Y <= tol {
+			rows[n-1].Boxes = append(rows[n-1].Boxes, b)
+			continue
+		}
+		rows = append(rows, gridRow{Y: b[1], Boxes: [][4]int{b}})
+	}
+	for i := range rows {
+		row := rows[i].Boxes
+		sort.Slice(row, func(a, b int) bool { return row[a][0] < row[b][0] })
+		rows[i].Y = medianInt(boxColumn(row, 1))
+	}
+	return rows
+}
+
+// observe - 从一次识别结果学习网格几何（格子尺寸、行距、列距、列数），返回聚好的行
+func (g *gridTracker) observe(boxes [][4]int) []gridRow {
+	rows := groupGridRows(boxes)
+	if len(rows) == 0 {
+		return nil
+	}
+	g.boxW = medianInt(boxColumn(boxes, 2))
+	g.boxH = medianInt(boxColumn(boxes, 3))
+
+	points := make([][2]float64, 0, len(boxes))
+	minX := boxes[0][0]
+	for _, b := range boxes {
+		points = append(points, [2]float64{float64(b[0]) + float64(b[2])/2, float64(b[1]) + float64(b[3])/2})
+		minX = min(minX, b[0])
+	}
+	geom := minicv.InferGrid(points, float64(min(g.boxW, g.boxH))/4)
+	if geom.PitchX > 0 {
+		g.colPitch = learn(g.colPitch, geom.PitchX)
+	}
+	if geom.PitchY > 0 {
+		g.rowPitch = learn(g.rowPitch, geom.PitchY)
+	}
+	if g.columns == 0 || minX < g.originX {
+		g.originX = minX
+	}
+	for _, row := range rows {
+		g.columns = max(g.columns, g.column(row.Boxes[len(row.Boxes)-1])+1)
+	}
+	if !g.anchored {
+		g.anchored = true
+		g.alignY = rows[0].Y
+		g.rowY = float64(rows[0].Y)
+	}
+	return rows
+}
+
+// pitch - 行距；尚未同时观测到两行时按列间隙估计
+func (g *gridTracker) pitch() float64 {
+	if g.rowPitch > 0 {
+		return g.rowPitch
+	}
+	if g.colPitch > 0 {
+		return float64(g.boxH) + g.colPitch - float64(g.boxW)
+	}
+	return float64(g.boxH)
+}
+
+// column - 格子所在列（从 0 开始）
+func (g *gridTracker) column(box [4]int) int {
+	if g.colPitch <= 0 {
+		return 0
+	}
+	return int(math.Round(float64(box[0]-g.originX) / g.colPitch))
+}
+
+// full - 该行格子数是否已满（未满说明是最后一行）
+func (g *gridTracker) full(count int) bool {
+	return g.columns > 0 && count >= g.columns
+}
+
+// hasRowAfter - 逻辑行 row 之后是否可能还有行；已知基质总数时以总行数为上限，防止外观相同的行导致无限循环
+func (g *gridTracker) hasRowAfter(row int) bool {
+	if g.total <= 0 || g.columns <= 0 {
+		return true
+	}
+	return row < (g.total+g.columns-1)/g.columns
+}
+
+// measureMove - 在滑动后的画面中匹配滑动前某一可见行的横条，返回内容实际位移（向上为正）。
+// 向上滑时优先用最下方的行、向下滑时优先用最上方的行，使横条在滑动后仍留在可见区域内；
+// 预期位移附近与更大范围内的最佳匹配得分接近时取前者，避免外观相同的行造成误判。
+func (g *gridTracker) measureMove(img *image.RGBA) (float64, bool) {
+	if g.frame == nil || img == nil || g.columns == 0 {
+		return 0, false
+	}
+	intArr := minicv.GetIntegralArray(img)
+	pitch := g.pitch()
+	order := append([]int(nil), g.frameRows...)
+	if g.pendingMove >= 0 {
+		slices.Reverse(order)
+	}
+	for _, top := range order {
+		strip := image.Rect(g.originX, top, g.originX+int(float64(g.columns-1)*g.colPitch)+g.boxW, top+g.boxH)
+		if !strip.In(g.frame.Bounds()) {
+			continue
+		}
+		tpl := minicv.ImageCropRect(g.frame, strip)
+		stats := minicv.GetImageStats(tpl)
+		if stats.Std < gridMatchMinStd {
+			continue
+		}
+		cx := strip.Min.X + strip.Dx()/2
+		cy := float64(strip.Min.Y+strip.Dy()/2) - g.pendingMove
+		match := func(radius float64) (float64, float64) {
+			_, y, score := minicv.MatchTemplateInArea(img, intArr, tpl, stats,
+				cx-gridMatchSlackX, int(cy-radius), 2*gridMatchSlackX+1, int(2*radius)+1)
+			return y, score
+		}
+		y, score := match(pitch / 2)
+		if wy, wscore := match(1.5 * pitch); wscore > score+gridMatchMargin {
+			y, score = wy, wscore
+		}
+		if score < gridMatchMinScore {
+			continue
+		}
+		return float64(top) - y, true
+	}
+	return 0, false
+}
+
+// resolve - 确定逻辑行 row 在画面 img 中的位置：滑动后先测量实际位移（失败时用预期位移），
+// 再吸附到观测到的行格上，并据此学习滑动效果。返回该行的屏幕 Y。
+func (g *gridTracker) resolve(rows []gridRow, row int, img *image.RGBA) float64 {
+	defer func() {
+		g.frame = img
+		g.frameRows = g.frameRows[:0]
+		for _, r := range rows {
+			g.frameRows = append(g.frameRows, r.Y)
+		}
+	}()
+	if len(rows) == 0 {
+		return g.rowY
+	}
+	predicted := g.rowY - g.pendingMove
+	measured := false
+	if g.pendingKind != "" {
+		if move, ok := g.measureMove(img); ok {
+			predicted = g.rowY - move
+			measured = true
+		}
+	}
+	pitch := g.pitch()
+
+	nearest := rows[0].Y
+	for _, r := range rows {
+		if math.Abs(float64(r.Y)-predicted) < math.Abs(float64(nearest)-predicted) {
+			nearest = r.Y
+		}
+	}
+	k := math.Round((predicted - float64(nearest)) / pitch)
+	y := float64(nearest) + k*pitch
+
+	moved := g.rowY - y
+	switch g.pendingKind {
+	case "":
+	case gridCalibrateKind:
+		g.stuck = math.Abs(moved) < gridStuckMove
+		if !g.stuck && g.pendingMove != 0 {
+			finger := math.Abs(g.pendingMove) * g.scrollRatio
+			ratio := finger / math.Abs(moved)
+			if ratio >= gridMinScrollRatio && ratio <= gridMaxScrollRatio {
+				g.scrollRatio = learn(g.scrollRatio, ratio)
+			}
+		}
+	default:
+		g.stuck = moved < gridStuckMove
+		if !g.stuck {
+			g.swipeMove[g.pendingKind] = learn(g.swipeMove[g.pendingKind], moved)
+		}
+	}
+	if g.pendingKind != "" {
+		log.Debug().
+			Str("component", "EssenceFilter").
+			Str("step", "GridResolve").
+			Str("swipe", g.pendingKind).
+			Int("row", row).
+			Bool("measured", measured).
+			Float64("expected_move", g.pendingMove).
+			Float64("actual_move", moved).
+			Float64("row_y", y).
+			Float64("row_pitch", pitch).
+			Msg("grid position resolved")
+	}
+
+	g.rowY = y
+	g.pendingMove = 0
+	g.pendingKind = ""
+	return y
+}
+
+// rowAt - 取出屏幕 Y 处的观测行；该位置没有格子时返回 false
+func (g *gridTracker) rowAt(rows []gridRow, y float64) (gridRow, bool) {
+	for _, r := range rows {
+		if math.Abs(float64(r.Y)-y) <= g.pitch()/2 {
+			return r, true
+		}
+	}
+	return gridRow{}, false
+}
+
+// cells - 行内格子，每列最多保留一个
+func (g *gridTracker) cells(row gridRow) [][4]int {
+	seen := make(map[int]bool, len(row.Boxes))
+	cells := make([][4]int, 0, len(row.Boxes))
+	for _, b := range row.Boxes {
+		col := g.column(b)
+		if seen[col] {
+			continue
+		}
+		seen[col] = true
+		cells = append(cells, b)
+	}
+	return cells
+}
+
+// advance - 当前行处理完毕，准备通过滑动节点 swipe 前往下一行
+func (g *gridTracker) advance(swipe string) {
+	pitch := g.pitch()
+	g.rowY += pitch
+	move, ok := g.swipeMove[swipe]
+	if !ok {
+		move = pitch // 滑动节点按一行的距离设计
+	}
+	g.pendingMove = move
+	g.pendingKind = swipe
+}
+
+// calibrateSwipe - 计算把当前行对齐到基准所需的校准滑动，返回起点与终点；已对齐时返回 false
+func (g *gridTracker) calibrateSwipe() (begin, end [2]int, ok bool) {
+	delta := g.rowY - float64(g.alignY)
+	if math.Abs(delta) <= calibrateTolerance {
+		return begin, end, false
+	}
+	finger := math.Abs(delta) * g.scrollRatio
+	finger = max(finger, calibrateSwipeMin)
+	finger = math.Min(finger, g.pitch()*g.scrollRatio)
+
+	// 从第一列、第二行的中心起滑，确保落在网格内
+	begin = [2]int{g.originX + g.boxW/2, g.alignY + int(g.pitch()) + g.boxH/2}
+	end = begin
+	move := finger / g.scrollRatio
+	if delta > 0 {
+		end[1] -= int(finger) // 当前行偏下，向上滑
+	} else {
+		end[1] += int(finger)
+		move = -move
+	}
+	g.pendingMove = move
+	g.pendingKind = gridCalibrateKind
+	return begin, end, true
+}
+
+// learn - 按学习率更新估计值；尚无估计时直接采用观测值
+func learn(prev, observed float64) float64 {
+	if prev <= 0 {
+		return observed
+	}
+	return prev*(1-gridLearnRate) + observed*gridLearnRate
+}
+
+// boxColumn - 取出所有格子的第 i 个分量
+func boxColumn(boxes [][4]int, i int) []int {
+	values := make([]int, len(boxes))
+	for j, b := range boxes {
+		values[j] = b[i]
+	}
+	return values
+}
+
+// medianInt - 中位数
+func medianInt(values []int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	return sorted[len(sorted)/2]
+}
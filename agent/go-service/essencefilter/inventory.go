@@ -0,0 +1,265 @@
+package essencefilter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// 库存数据库格式版本
+const inventoryDBVersion = 1
+
+// 每记录多少条基质落盘一次，避免中途异常丢失整轮数据
+const inventorySaveInterval = 20
+
+// 基质处理结果
+const (
+	InventoryDecisionLock    = "lock"
+	InventoryDecisionDiscard = "discard"
+	InventoryDecisionSkip    = "skip"
+	InventoryDecisionReview  = "review" // 疑似目标但置信度不足，跳过并等待人工确认
+)
+
+// InventoryRecord - 一条已扫描的基质记录。
+// 同一基质在多轮扫描中按 Key 去重，只更新最后一次的处理结果与时间。
+type InventoryRecord struct {
+	Key       string    `json:"key"`
+	Type      string    `json:"type"`      // 基质类型名，未知时为空
+	Skills    [3]string `json:"skills"`    // OCR 到的技能文本
+	SkillIDs  [3]int    `json:"skill_ids"` // 各槽位技能 ID，0 表示未匹配
+	Levels    [3]int    `json:"levels"`
+	Decision  string    `json:"decision"` // lock / discard / skip / review
+	Reason    string    `json:"reason,omitempty"`
+	Weapons   []string  `json:"weapons,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	SeenCount int       `json:"seen_count"`
+	LastRun   string    `json:"last_run"`
+}
+
+// InventoryDatabase - 本地持久化的基质库存
+type InventoryDatabase struct {
+	Version int                `json:"version"`
+	LastRun string             `json:"last_run"`
+	Records []*InventoryRecord `json:"records"`
+
+	path      string
+	index     map[string]*InventoryRecord
+	runCounts map[string]int // 本轮每个指纹出现的次数，用于区分完全相同的基质
+	unsaved   int
+}
+
+// OpenInventoryDatabase - 打开库存数据库并开始新一轮记录；文件不存在时创建空库
+func OpenInventoryDatabase(path string) (*InventoryDatabase, error) {
+	db := &InventoryDatabase{Version: inventoryDBVersion, path: path}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, db); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		if db.Version > inventoryDBVersion {
+			return nil, fmt.Errorf("unsupported inventory version %d", db.Version)
+		}
+		db.Version = inventoryDBVersion
+	case os.IsNotExist(err):
+	default:
+		return nil, err
+	}
+
+	db.index = make(map[string]*InventoryRecord, len(db.Records))
+	for _, r := range db.Records {
+		db.index[r.Key] = r
+	}
+	db.runCounts = make(map[string]int)
+	db.LastRun = time.Now().Format("20060102_150405")
+	return db, nil
+}
+
+// inventoryFingerprint - 基质指纹：类型 + 各槽位技能（优先 ID）+ 等级
+func inventoryFingerprint(essenceType string, skills [3]string, ids [3]int, levels [3]int) string {
+	parts := make([]string, 0, 7)
+	parts = append(parts, essenceType)
+	for i := 0; i < 3; i++ {
+		if ids[i] != 0 {
+			parts = append(parts, strconv.Itoa(ids[i]))
+		} else {
+			parts = append(parts, skills[i])
+		}
+	}
+	for _, lv := range levels {
+		parts = append(parts, strconv.Itoa(lv))
+	}
+	return strings.Join(parts, "|")
+}
+
+// Record - 记录一条本轮扫描到的基质。
+// 同一指纹在本轮第 k 次出现时对应 Key "<指纹>#k"，从而跨轮去重且不合并完全相同的多个基质。
+// 游戏内基质没有可读取的唯一标识，序号只依赖本轮的扫描顺序：完全相同的基质之间无法区分，
+// 若其中之一被消耗，后面的记录会前移并继承它的 FirstSeen / SeenCount，而最后一条不再被看到；
+// 因此完全相同基质的逐条历史只是近似值，按指纹聚合的数量才是准确的。
+func (db *InventoryDatabase) Record(r InventoryRecord) *InventoryRecord {
+	fp := inventoryFingerprint(r.Type, r.Skills, r.SkillIDs, r.Levels)
+	db.runCounts[fp]++
+	key := fmt.Sprintf("%s#%d", fp, db.runCounts[fp])
+	now := time.Now()
+
+	rec, ok := db.index[key]
+	if !ok {
+		rec = &InventoryRecord{Key: key, FirstSeen: now}
+		db.index[key] = rec
+		db.Records = append(db.Records, rec)
+	}
+	rec.Type, rec.Skills, rec.SkillIDs, rec.Levels = r.Type, r.Skills, r.SkillIDs, r.Levels
+	rec.Decision, rec.Reason, rec.Weapons = r.Decision, r.Reason, r.Weapons
+	rec.LastSeen = now
+	rec.SeenCount++
+	rec.LastRun = db.LastRun
+
+	db.unsaved++
+	if db.unsaved >= inventorySaveInterval {
+		if err := db.Save(); err != nil {
+			log.Warn().Err(err).Str("component", "EssenceFilter").Str("path", db.path).Msg("save inventory failed")
+		}
+	}
+	return rec
+}
+
+// Save - 将库存写回文件（先写临时文件再替换）
+func (db *InventoryDatabase) Save() error {
+	data, err := json.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(db.path), 0755); err != nil {
+		return err
+	}
+	tmp := db.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, db.path); err != nil {
+		return err
+	}
+	db.unsaved = 0
+	return nil
+}
+
+// CurrentRecords - 返回最近一轮扫描中出现过的记录（即当前库存）
+func (db *InventoryDatabase) CurrentRecords() []*InventoryRecord {
+	records := make([]*InventoryRecord, 0, len(db.Records))
+	for _, r := range db.Records {
+		if r.LastRun == db.LastRun {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// ExportJSON - 导出全部记录为 JSON 数组
+func (db *InventoryDatabase) ExportJSON(path string) error {
+	data, err := json.MarshalIndent(db.Records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ExportCSV - 导出全部记录为 CSV，in_latest_run 标记该基质是否出现在最近一轮扫描中
+func (db *InventoryDatabase) ExportCSV(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(f)
+	_ = w.Write([]string{
+		"key", "type",
+		"skill1", "skill2", "skill3",
+		"skill1_id", "skill2_id", "skill3_id",
+		"level1", "level2", "level3",
+		"decision", "reason", "weapons",
+		"first_seen", "last_seen", "seen_count", "in_latest_run",
+	})
+	for _, r := range db.Records {
+		_ = w.Write([]string{
+			r.Key, r.Type,
+			r.Skills[0], r.Skills[1], r.Skills[2],
+			strconv.Itoa(r.SkillIDs[0]), strconv.Itoa(r.SkillIDs[1]), strconv.Itoa(r.SkillIDs[2]),
+			strconv.Itoa(r.Levels[0]), strconv.Itoa(r.Levels[1]), strconv.Itoa(r.Levels[2]),
+			r.Decision, r.Reason, strings.Join(r.Weapons, "、"),
+			r.FirstSeen.Format(time.RFC3339), r.LastSeen.Format(time.RFC3339),
+			strconv.Itoa(r.SeenCount), strconv.FormatBool(r.LastRun == db.LastRun),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// inventoryExportPath - 导出文件路径：与库存文件同目录同名，替换扩展名
+func inventoryExportPath(dbPath, ext string) string {
+	return strings.TrimSuffix(dbPath, filepath.Ext(dbPath)) + "_export" + ext
+}
+
+// recordInventory - 将当前基质及其处理结果写入库存（未开启库存记录时不做任何事）
+func recordInventory(skills []string, match *SkillCombinationMatch, decision, reason string) {
+	if inventoryDB == nil {
+		return
+	}
+	r := InventoryRecord{
+		Type:     currentEssenceType,
+		Levels:   currentSkillLevels,
+		Decision: decision,
+		Reason:   reason,
+	}
+	copy(r.Skills[:], skills)
+	r.SkillIDs, _ = ocrSkillIDsBySlot(skills)
+	if match != nil {
+		for _, w := range match.Weapons {
+			r.Weapons = append(r.Weapons, w.ChineseName)
+		}
+	}
+	rec := inventoryDB.Record(r)
+	log.Debug().Str("component", "EssenceFilter").Str("key", rec.Key).Str("decision", decision).Int("seen_count", rec.SeenCount).Msg("inventory recorded")
+}
+
+// closeInventory - 保存库存并按需导出，结束后释放
+func closeInventory(export bool) {
+	if inventoryDB == nil {
+		return
+	}
+	if err := inventoryDB.Save(); err != nil {
+		log.Error().Err(err).Str("component", "EssenceFilter").Str("path", inventoryDB.path).Msg("save inventory failed")
+	}
+	if export {
+		for _, e := range []struct {
+			ext string
+			fn  func(string) error
+		}{{".json", inventoryDB.ExportJSON}, {".csv", inventoryDB.ExportCSV}} {
+			path := inventoryExportPath(inventoryDB.path, e.ext)
+			if err := e.fn(path); err != nil {
+				log.Error().Err(err).Str("component", "EssenceFilter").Str("path", path).Msg("export inventory failed")
+			} else {
+				log.Info().Str("component", "EssenceFilter").Str("path", path).Int("records", len(inventoryDB.Records)).Msg("inventory exported")
+			}
+		}
+	}
+	inventoryDB = nil
+}
@@ -0,0 +1,96 @@
+package essencefilter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// 默认客户端语言
+const defaultClientLanguage = "zh_cn"
+
+// skillLanguage - 客户端语言相关的技能匹配设置
+type skillLanguage struct {
+	Code string
+	// clean 清洗 OCR 文本与技能名：只保留该语言的有效字符，统一大小写
+	clean func(string) string
+	// display 生成用于日志展示的 OCR 文本
+	display func(string) string
+}
+
+// skillLanguages - 支持的客户端语言，键与 assets/misc/locales 的语言代码一致。
+// 技能池只提供中文与英文技能名，其他客户端语言暂不支持
+var skillLanguages = map[string]*skillLanguage{
+	"zh_cn": {Code: "zh_cn", clean: cleanChinese, display: cleanChinese},
+	"en_us": {Code: "en_us", clean: cleanLatin, display: strings.TrimSpace},
+}
+
+// activeLanguage - 当前使用的语言，由 EssenceFilterInit 根据选项设置
+var activeLanguage = skillLanguages[defaultClientLanguage]
+
+// skillName - 技能在当前语言下的名称
+func (l *skillLanguage) skillName(s SkillPool) string {
+	if l.Code == "en_us" {
+		return s.English
+	}
+	return s.Chinese
+}
+
+// SetSkillLanguage - 切换技能匹配语言，并在下次匹配时按新语言重建技能索引。
+// code 为空时使用简体中文；语言不受支持或技能池缺少该语言的技能名时返回错误。
+func SetSkillLanguage(code string) error {
+	if code == "" {
+		code = defaultClientLanguage
+	}
+	lang, ok := skillLanguages[code]
+	if !ok {
+		return fmt.Errorf("unsupported client language %q", code)
+	}
+	for slot := 1; slot <= 3; slot++ {
+		for _, s := range getPoolBySlot(slot) {
+			if lang.clean(lang.skillName(s)) == "" {
+				return fmt.Errorf("skill %d of slot %d has no %s name", s.ID, slot, code)
+			}
+		}
+	}
+	activeLanguage = lang
+	resetSlotIndices()
+	return nil
+}
+
+// activeMatcherConfig - 当前语言的相近字映射与停用后缀；
+// 顶层配置对应简体中文，其他语言取 languages 中的同名条目
+func activeMatcherConfig() LanguageMatcherConfig {
+	if cfg, ok := matcherConfig.Languages[activeLanguage.Code]; ok {
+		return cfg
+	}
+	if activeLanguage.Code == defaultClientLanguage {
+		return LanguageMatcherConfig{
+			SimilarWordMap:  matcherConfig.SimilarWordMap,
+			SuffixStopwords: matcherConfig.SuffixStopwords,
+		}
+	}
+	return LanguageMatcherConfig{}
+}
+
+// 清洗：只保留汉字
+func cleanChinese(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// cleanLatin - 只保留字母与数字并转为小写；空格与标点在 OCR 中不稳定，一律去除
+func cleanLatin(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
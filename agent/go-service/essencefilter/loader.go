@@ -2,16 +2,296 @@ package essencefilter
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 )
 
-// LoadWeaponDatabase - 加载武器数据库
-func LoadWeaponDatabase(filepath string) error {
-	data, err := os.ReadFile(filepath)
+// 武器数据库格式版本；文件未写 version 时视为 1
+const weaponDBVersion = 1
+
+// 用户覆盖目录：目录下的 *.json 按文件名顺序合并到武器数据库，
+// 用于在数据更新前自行补充新武器、新地点或修正技能池
+const defaultWeaponOverlayDir = "config/essence_weapons"
+
+// 武器数据库问题级别
+const (
+	DBIssueError   = "error"   // 引用不存在的技能、ID 重复等：所在文件被拒绝
+	DBIssueWarning = "warning" // 技能名与技能池不一致等：不影响匹配
+)
+
+// DBIssue - 武器数据库校验发现的一个问题
+type DBIssue struct {
+	Severity string
+	Source   string // 文件名
+	Message  string
+}
+
+// WeaponDBReport - 一次加载的结果：版本、生效的文件与校验问题
+type WeaponDBReport struct {
+	Version  int
+	Revision string
+	Sources  []string // 生效的文件，基础数据库在前
+	Rejected []string // 因错误被拒绝的覆盖文件
+	Issues   []DBIssue
+	Reloaded bool // 本次是否重新读取；文件均未变化时沿用上次的数据与结果
+}
+
+// Count - 指定级别的问题数量
+func (r *WeaponDBReport) Count(severity string) int {
+	n := 0
+	for _, i := range r.Issues {
+		if i.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// 上次成功加载时各文件的状态与结果，用于判断是否需要重新加载
+var (
+	loadedWeaponDBStamp  string
+	loadedWeaponDBReport *WeaponDBReport
+)
+
+// LoadWeaponDatabase - 加载武器数据库并合并 overlayDir 下的覆盖文件（为空时不合并），校验引用完整性。
+// 文件均未变化时直接返回上次的结果；基础数据库有错误时返回错误且保留已加载的数据，
+// 覆盖文件有错误时只拒绝该文件。加载成功后技能索引会在下次匹配时重建。
+func LoadWeaponDatabase(path, overlayDir string) (*WeaponDBReport, error) {
+	files := []string{path}
+	if overlayDir != "" {
+		overlays, err := filepath.Glob(filepath.Join(overlayDir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(overlays)
+		files = append(files, overlays...)
+	}
+	stamp, err := weaponDBFilesStamp(files)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if stamp == loadedWeaponDBStamp && loadedWeaponDBReport != nil {
+		report := *loadedWeaponDBReport
+		report.Reloaded = false
+		return &report, nil
+	}
+
+	report := &WeaponDBReport{Reloaded: true}
+	db, err := readWeaponDatabase(path)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	report.Issues = appendNewIssues(report.Issues, validateWeaponDatabase(&db, filepath.Base(path)), seen)
+	if report.Count(DBIssueError) > 0 {
+		return report, fmt.Errorf("%s: %d errors", filepath.Base(path), report.Count(DBIssueError))
+	}
+	report.Sources = append(report.Sources, path)
+
+	for _, file := range files[1:] {
+		source := filepath.Base(file)
+		overlay, err := readWeaponDatabase(file)
+		if err == nil && overlay.Version > weaponDBVersion {
+			err = fmt.Errorf("unsupported version %d", overlay.Version)
+		}
+		if err != nil {
+			report.Issues = append(report.Issues, DBIssue{Severity: DBIssueError, Source: source, Message: err.Error()})
+			report.Rejected = append(report.Rejected, file)
+			continue
+		}
+		merged := mergeWeaponDatabase(db, overlay)
+		issues := validateWeaponDatabase(&merged, source)
+		report.Issues = appendNewIssues(report.Issues, issues, seen)
+		if slices.ContainsFunc(issues, func(i DBIssue) bool { return i.Severity == DBIssueError }) {
+			report.Rejected = append(report.Rejected, file)
+			continue
+		}
+		db = merged
+		report.Sources = append(report.Sources, file)
+	}
+
+	weaponDB = db
+	report.Version, report.Revision = db.Version, db.Revision
+	resetSlotIndices()
+	loadedWeaponDBStamp, loadedWeaponDBReport = stamp, report
+	return report, nil
+}
+
+// weaponDBFilesStamp - 各文件路径、大小与修改时间的摘要；基础数据库不存在时返回错误
+func weaponDBFilesStamp(files []string) (string, error) {
+	var b strings.Builder
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s|%d|%d;", f, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// readWeaponDatabase - 读取单个数据库或覆盖文件
+func readWeaponDatabase(path string) (WeaponDatabase, error) {
+	var db WeaponDatabase
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return db, err
+	}
+	if err := json.Unmarshal(data, &db); err != nil {
+		return db, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// mergeWeaponDatabase - 将覆盖文件合并到数据库：武器类型与技能按 ID、武器按 internal_id、地点按名称新增或替换
+func mergeWeaponDatabase(base, overlay WeaponDatabase) WeaponDatabase {
+	merged := base
+	merged.WeaponTypes = upsertBy(base.WeaponTypes, overlay.WeaponTypes, func(t WeaponType) int { return t.ID })
+	merged.SkillPools.Slot1 = upsertBy(base.SkillPools.Slot1, overlay.SkillPools.Slot1, func(s SkillPool) int { return s.ID })
+	merged.SkillPools.Slot2 = upsertBy(base.SkillPools.Slot2, overlay.SkillPools.Slot2, func(s SkillPool) int { return s.ID })
+	merged.SkillPools.Slot3 = upsertBy(base.SkillPools.Slot3, overlay.SkillPools.Slot3, func(s SkillPool) int { return s.ID })
+	merged.Weapons = upsertBy(base.Weapons, overlay.Weapons, func(w WeaponData) string { return w.InternalID })
+	merged.Locations = upsertBy(base.Locations, overlay.Locations, func(l Location) string { return l.Name })
+	return merged
+}
+
+// upsertBy - 返回 base 的副本，key 相同的条目被 overlay 替换，其余 overlay 条目追加在末尾
+func upsertBy[T any, K comparable](base, overlay []T, key func(T) K) []T {
+	out := slices.Clone(base)
+	index := make(map[K]int, len(out))
+	for i, v := range out {
+		index[key(v)] = i
+	}
+	for _, v := range overlay {
+		if i, ok := index[key(v)]; ok {
+			out[i] = v
+			continue
+		}
+		index[key(v)] = len(out)
+		out = append(out, v)
+	}
+	return out
+}
+
+// validateWeaponDatabase - 校验版本、ID 唯一性与引用完整性（武器技能、地点词条都必须在技能池中），
+// 缺省的 version 补为 1
+func validateWeaponDatabase(db *WeaponDatabase, source string) []DBIssue {
+	var issues []DBIssue
+	report := func(severity, format string, args ...any) {
+		issues = append(issues, DBIssue{Severity: severity, Source: source, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if db.Version == 0 {
+		db.Version = weaponDBVersion
+	}
+	if db.Version > weaponDBVersion {
+		report(DBIssueError, "unsupported version %d", db.Version)
+		return issues
+	}
+
+	typeIDs := make(map[int]bool, len(db.WeaponTypes))
+	for _, t := range db.WeaponTypes {
+		if typeIDs[t.ID] {
+			report(DBIssueError, "duplicate weapon type id %d", t.ID)
+		}
+		typeIDs[t.ID] = true
+	}
+
+	var pools [3]map[int]SkillPool
+	for slot := 1; slot <= 3; slot++ {
+		pools[slot-1] = make(map[int]SkillPool)
+		names := make(map[string]int)
+		for _, s := range db.skillPool(slot) {
+			switch _, dup := pools[slot-1][s.ID]; {
+			case s.ID <= 0:
+				report(DBIssueError, "slot%d skill %q has invalid id %d", slot, s.Chinese, s.ID)
+			case dup:
+				report(DBIssueError, "slot%d has duplicate skill id %d", slot, s.ID)
+			case s.Chinese == "":
+				report(DBIssueError, "slot%d skill %d has no chinese name", slot, s.ID)
+			}
+			if other, ok := names[s.Chinese]; ok && s.Chinese != "" {
+				report(DBIssueWarning, "slot%d skills %d and %d share the name %q", slot, other, s.ID, s.Chinese)
+			}
+			pools[slot-1][s.ID] = s
+			names[s.Chinese] = s.ID
+		}
+	}
+
+	weaponIDs := make(map[string]bool, len(db.Weapons))
+	for i := range db.Weapons {
+		w := &db.Weapons[i]
+		if w.InternalID == "" {
+			report(DBIssueError, "weapon %q has no internal_id", w.ChineseName)
+		} else if weaponIDs[w.InternalID] {
+			report(DBIssueError, "duplicate weapon internal_id %s", w.InternalID)
+		}
+		weaponIDs[w.InternalID] = true
+		if !typeIDs[w.TypeID] {
+			report(DBIssueError, "weapon %s: unknown type_id %d", w.InternalID, w.TypeID)
+		}
+		if w.Rarity < 1 || w.Rarity > 6 {
+			report(DBIssueWarning, "weapon %s: rarity %d out of range", w.InternalID, w.Rarity)
+		}
+		if len(w.SkillIDs) != 3 {
+			report(DBIssueError, "weapon %s: expected 3 skill_ids, got %d", w.InternalID, len(w.SkillIDs))
+			continue
+		}
+		for slot, id := range w.SkillIDs {
+			if id == 0 {
+				continue // 低稀有度武器可能没有该槽位的技能
+			}
+			s, ok := pools[slot][id]
+			if !ok {
+				report(DBIssueError, "weapon %s: slot%d skill id %d not in pool", w.InternalID, slot+1, id)
+				continue
+			}
+			// skills_chinese 带等级后缀（如“力量提升·中”），只要求以技能池名称开头
+			if slot < len(w.SkillsChinese) && !strings.HasPrefix(w.SkillsChinese[slot], s.Chinese) {
+				report(DBIssueWarning, "weapon %s: slot%d name %q does not match pool skill %q", w.InternalID, slot+1, w.SkillsChinese[slot], s.Chinese)
+			}
+		}
+	}
+
+	locationNames := make(map[string]bool, len(db.Locations))
+	for _, l := range db.Locations {
+		if l.Name == "" {
+			report(DBIssueError, "location without name")
+		} else if locationNames[l.Name] {
+			report(DBIssueError, "duplicate location %q", l.Name)
+		}
+		locationNames[l.Name] = true
+		for _, ref := range []struct {
+			slot int
+			ids  []int
+		}{{2, l.Slot2IDs}, {3, l.Slot3IDs}} {
+			if len(ref.ids) == 0 {
+				report(DBIssueWarning, "location %q has no slot%d skills", l.Name, ref.slot)
+			}
+			for _, id := range ref.ids {
+				if _, ok := pools[ref.slot-1][id]; !ok {
+					report(DBIssueError, "location %q: slot%d skill id %d not in pool", l.Name, ref.slot, id)
+				}
+			}
+		}
+	}
+	return issues
+}
+
+// appendNewIssues - 追加尚未报告过的问题（合并覆盖文件后重新校验时，基础数据库的警告不重复报告）
+func appendNewIssues(dst, issues []DBIssue, seen map[string]bool) []DBIssue {
+	for _, i := range issues {
+		if seen[i.Severity+i.Message] {
+			continue
+		}
+		seen[i.Severity+i.Message] = true
+		dst = append(dst, i)
 	}
-	return json.Unmarshal(data, &weaponDB)
+	return dst
 }
 
 // LoadMatcherConfig - 加载匹配器配置
@@ -3,7 +3,6 @@ package essencefilter
 import (
 	"strings"
 	"sync"
-	"unicode"
 	"unicode/utf8"
 
 	maa "github.com/MaaXYZ/maa-framework-go/v4"
@@ -12,8 +11,14 @@ import (
 
 var buildSlotIndicesOnce sync.Once
 
+// resetSlotIndices - 武器数据库或识别语言变化后，令技能索引在下次使用时重建
+func resetSlotIndices() {
+	buildSlotIndicesOnce = sync.Once{}
+}
+
 // MatchEssenceSkills - 先用原始清洗文本匹配，失败后再用相近字替换后的文本匹配
 // 返回结构化的技能组合匹配结果（可能对应多把武器），不再在此处拼接武器名字符串。
+// 逐槽精确匹配失败时回退到 top-k 候选的组合感知解码；置信度不足时返回 Ambiguous 的匹配且 ok 为 false。
 func MatchEssenceSkills(ctx *maa.Context, ocrSkills []string) (*SkillCombinationMatch, bool) {
 	if len(ocrSkills) != 3 {
 		log.Warn().Int("len", len(ocrSkills)).Strs("ocr_skills", ocrSkills).Msg("[EssenceFilter] MatchEssenceSkills: OCR 数量不足")
@@ -27,7 +32,7 @@ func MatchEssenceSkills(ctx *maa.Context, ocrSkills []string) (*SkillCombination
 		id, ok := matchSkillIDEnhanced(i+1, skill)
 		if !ok {
 			log.Info().Int("slot", i+1).Str("skill", skill).Msg("[EssenceFilter] MatchEssenceSkills: OCR 未匹配到技能 ID")
-			return nil, false
+			return matchEssenceSkillsDecoded(ocrSkills)
 		}
 		ocrSkillIDs[i] = id
 		log.Debug().Int("slot", i+1).Str("skill", skill).Int("skill_id", id).Msg("[EssenceFilter] OCR 技能映射结果")
@@ -60,6 +65,7 @@ func MatchEssenceSkills(ctx *maa.Context, ocrSkills []string) (*SkillCombination
 			SkillIDs:      skillIDs,
 			SkillsChinese: skillsChinese,
 			Weapons:       matchedWeapons,
+			Confidence:    1,
 		}
 
 		log.Info().
@@ -78,7 +84,7 @@ func MatchEssenceSkills(ctx *maa.Context, ocrSkills []string) (*SkillCombination
 		Int("target_combo_total", len(targetSkillCombinations)).
 		Msg("[EssenceFilter] MatchEssenceSkills: 未找到匹配组合")
 
-	return nil, false
+	return matchEssenceSkillsDecoded(ocrSkills)
 }
 
 // MatchFuturePromising - 保留未来可期基质：三种词条齐全且总等级 >= minTotal
@@ -195,7 +201,7 @@ func buildSlotIndices() {
 			lastCharNorm:  make(map[string][]int),
 		}
 		for _, s := range pool {
-			rawFull := cleanChinese(s.Chinese)
+			rawFull := activeLanguage.clean(activeLanguage.skillName(s))
 			rawCore := trimStopSuffix(rawFull)
 			// 技能池不做相近字替换，保持原始文本，避免全局误替换
 			normFull := rawFull
@@ -238,20 +244,9 @@ func buildSlotIndices() {
 	}
 }
 
-// 清洗：只保留汉字
-func cleanChinese(text string) string {
-	var b strings.Builder
-	for _, r := range text {
-		if unicode.Is(unicode.Han, r) {
-			b.WriteRune(r)
-		}
-	}
-	return b.String()
-}
-
 // trimStopSuffix - 去除停用后缀（从配置文件加载）
 func trimStopSuffix(s string) string {
-	for _, suf := range matcherConfig.SuffixStopwords {
+	for _, suf := range activeMatcherConfig().SuffixStopwords {
 		if strings.HasSuffix(s, suf) && utf8.RuneCountInString(s) > utf8.RuneCountInString(suf) {
 			return strings.TrimSuffix(s, suf)
 		}
@@ -261,7 +256,7 @@ func trimStopSuffix(s string) string {
 
 // normalizeSimilar - 相近/误识替换（键为误识，值为正确），仅作用于 OCR 文本，不改技能池（从配置文件加载）
 func normalizeSimilar(s string) string {
-	for old, val := range matcherConfig.SimilarWordMap {
+	for old, val := range activeMatcherConfig().SimilarWordMap {
 		s = strings.ReplaceAll(s, old, val)
 	}
 	return s
@@ -308,6 +303,12 @@ func editDistance(a, b string, max int) int {
 
 // 先用原始，再用相近替换后的文本匹配；每阶段都有详细日志
 func matchSkillIDEnhanced(slot int, ocrText string) (int, bool) {
+	id, _, ok := matchSkillIDWithStep(slot, ocrText)
+	return id, ok
+}
+
+// matchSkillIDWithStep - 同 matchSkillIDEnhanced，额外返回命中的阶段与步骤（如 "raw/exact_full"），供离线评估统计
+func matchSkillIDWithStep(slot int, ocrText string) (int, string, bool) {
 	idx := slotIndices[slot-1]
 	pool := getPoolBySlot(slot)
 	idToName := make(map[int]string, len(pool))
@@ -315,31 +316,31 @@ func matchSkillIDEnhanced(slot int, ocrText string) (int, bool) {
 		idToName[s.ID] = s.Chinese
 	}
 
-	cleanedRaw := cleanChinese(ocrText)
+	cleanedRaw := activeLanguage.clean(ocrText)
 	if cleanedRaw == "" {
 		log.Debug().Int("slot", slot).Str("ocr_raw", ocrText).Msg("[EssenceFilter] match: cleaned empty")
-		return 0, false
+		return 0, "", false
 	}
 	coreRaw := trimStopSuffix(cleanedRaw)
 
-	if id, ok := attemptMatch("raw", slot, cleanedRaw, coreRaw, idx, idToName); ok {
-		return id, true
+	if id, step, ok := attemptMatch("raw", slot, cleanedRaw, coreRaw, idx, idToName); ok {
+		return id, "raw/" + step, true
 	}
 
 	cleanedNorm := normalizeSimilar(cleanedRaw)
 	coreNorm := trimStopSuffix(cleanedNorm)
 	// 若替换后无变化，仍再试一次，以保持日志区分
-	if id, ok := attemptMatch("norm", slot, cleanedNorm, coreNorm, idx, idToName); ok {
-		return id, true
+	if id, step, ok := attemptMatch("norm", slot, cleanedNorm, coreNorm, idx, idToName); ok {
+		return id, "norm/" + step, true
 	}
 
 	log.Info().Int("slot", slot).Str("step", "no_match").Str("cleaned_raw", cleanedRaw).Str("cleaned_norm", cleanedNorm).Msg("[EssenceFilter] match miss")
-	return 0, false
+	return 0, "", false
 }
 
 type matchPhase string
 
-func attemptMatch(phase matchPhase, slot int, cleaned, core string, idx slotIndex, idToName map[int]string) (int, bool) {
+func attemptMatch(phase matchPhase, slot int, cleaned, core string, idx slotIndex, idToName map[int]string) (int, string, bool) {
 	useNorm := phase == "norm"
 	var fullIndex, coreIndex map[string][]int
 	var firstChar, lastChar map[string][]int
@@ -362,14 +363,14 @@ func attemptMatch(phase matchPhase, slot int, cleaned, core string, idx slotInde
 		log.Info().Int("slot", slot).Str("phase", string(phase)).Str("step", "exact_full").Str("cleaned", cleaned).
 			Int("skill_id", ids[0]).Str("skill_name", idToName[ids[0]]).
 			Msg("[EssenceFilter] match hit")
-		return ids[0], true
+		return ids[0], "exact_full", true
 	}
 	// 2) 核心前缀精确
 	if ids, ok := coreIndex[core]; ok && len(ids) > 0 {
 		log.Info().Int("slot", slot).Str("phase", string(phase)).Str("step", "exact_core").Str("core", core).
 			Int("skill_id", ids[0]).Str("skill_name", idToName[ids[0]]).
 			Msg("[EssenceFilter] match hit")
-		return ids[0], true
+		return ids[0], "exact_core", true
 	}
 	// 3) 完整子串（长度差 ≤2）
 	for _, e := range idx.entries {
@@ -387,7 +388,7 @@ func attemptMatch(phase matchPhase, slot int, cleaned, core string, idx slotInde
 				Str("cleaned", cleaned).Str("target", tFull).
 				Int("skill_id", e.ID).Str("skill_name", idToName[e.ID]).
 				Msg("[EssenceFilter] match hit")
-			return e.ID, true
+			return e.ID, "substring_full", true
 		}
 	}
 	// 4) 核心子串（长度差 ≤2）
@@ -406,7 +407,7 @@ func attemptMatch(phase matchPhase, slot int, cleaned, core string, idx slotInde
 				Str("core", core).Str("target_core", tCore).
 				Int("skill_id", e.ID).Str("skill_name", idToName[e.ID]).
 				Msg("[EssenceFilter] match hit")
-			return e.ID, true
+			return e.ID, "substring_core", true
 		}
 	}
 	// 5) 双字-单字兜底（首/尾且唯一）
@@ -415,13 +416,13 @@ func attemptMatch(phase matchPhase, slot int, cleaned, core string, idx slotInde
 			log.Info().Int("slot", slot).Str("phase", string(phase)).Str("step", "single_char_first").
 				Str("char", cleaned).Int("skill_id", ids[0]).Str("skill_name", idToName[ids[0]]).
 				Msg("[EssenceFilter] match hit")
-			return ids[0], true
+			return ids[0], "single_char_first", true
 		}
 		if ids := lastChar[cleaned]; len(ids) == 1 {
 			log.Info().Int("slot", slot).Str("phase", string(phase)).Str("step", "single_char_last").
 				Str("char", cleaned).Int("skill_id", ids[0]).Str("skill_name", idToName[ids[0]]).
 				Msg("[EssenceFilter] match hit")
-			return ids[0], true
+			return ids[0], "single_char_last", true
 		}
 	}
 
@@ -449,12 +450,12 @@ func attemptMatch(phase matchPhase, slot int, cleaned, core string, idx slotInde
 				Str("core", core).Int("distance", bestDistCore).
 				Int("skill_id", bestIDCore).Str("skill_name", idToName[bestIDCore]).
 				Msg("[EssenceFilter] match hit")
-			return bestIDCore, true
+			return bestIDCore, "edit_distance_core", true
 		}
 		log.Debug().Int("slot", slot).Str("phase", string(phase)).Str("step", "edit_distance_core").
 			Str("core", core).Int("max_ed", maxEdCore).
 			Msg("[EssenceFilter] match miss")
-		return 0, false
+		return 0, "", false
 	}
 
 	// core 没变化（没命中 stopword 后缀）时，才用 full string 做 edit distance
@@ -478,20 +479,25 @@ func attemptMatch(phase matchPhase, slot int, cleaned, core string, idx slotInde
 			Str("cleaned", cleaned).Int("distance", bestDist).
 			Int("skill_id", bestID).Str("skill_name", idToName[bestID]).
 			Msg("[EssenceFilter] match hit")
-		return bestID, true
+		return bestID, "edit_distance", true
 	}
-	return 0, false
+	return 0, "", false
 }
 
 // getPoolBySlot - 按槽位获取技能池
 func getPoolBySlot(slot int) []SkillPool {
+	return weaponDB.skillPool(slot)
+}
+
+// skillPool - 按槽位获取数据库中的技能池
+func (db *WeaponDatabase) skillPool(slot int) []SkillPool {
 	switch slot {
 	case 1:
-		return weaponDB.SkillPools.Slot1
+		return db.SkillPools.Slot1
 	case 2:
-		return weaponDB.SkillPools.Slot2
+		return db.SkillPools.Slot2
 	case 3:
-		return weaponDB.SkillPools.Slot3
+		return db.SkillPools.Slot3
 	default:
 		return nil
 	}
@@ -0,0 +1,445 @@
+package essencefilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// 精确求解的搜索节点上限，超过后退回贪心结果
+const plannerMaxNodes = 200000
+
+// EngravePlan - 一个预刻写方案：在某地点选择三个基础属性（slot1），并固定附加属性（slot2）或技能属性（slot3）。
+// 方案覆盖的武器需满足：基础属性在所选三项中、固定槽位一致、另一槽位技能在该地点的池中。
+type EngravePlan struct {
+	Location   string    `json:"location"`
+	Slot1IDs   [3]int    `json:"slot1_ids"`
+	Slot1Names [3]string `json:"slot1_names"`
+	FixedSlot  int       `json:"fixed_slot"` // 2 = 附加属性固定, 3 = 技能属性固定
+	FixedID    int       `json:"fixed_id"`
+	FixedName  string    `json:"fixed_name"`
+	// Cost 为刷出其中某把指定武器基质的期望掉落数：3 个基础属性 × 未固定槽位在该地点的池大小（按等概率估算）
+	Cost   float64  `json:"cost"`
+	Covers []string `json:"covers"` // 覆盖的全部未毕业武器
+
+	covers plannerBits
+}
+
+// EngraveScheduleStep - 排期中的一步：执行该方案后新增覆盖的武器
+type EngraveScheduleStep struct {
+	Step       int          `json:"step"`
+	Plan       EngravePlan  `json:"plan"`
+	NewWeapons []string     `json:"new_weapons"`
+	Priority   float64      `json:"priority"` // 新增武器的优先级之和
+	Covered    int          `json:"covered"`  // 截至该步累计覆盖的武器数
+	newWeapons []WeaponData // 用于 HTML 展示
+}
+
+// EngraveSchedule - 预刻写排期：覆盖全部可覆盖的未毕业武器所需的最少方案，按优先级/成本排序
+type EngraveSchedule struct {
+	GeneratedAt string                `json:"generated_at"`
+	Optimal     bool                  `json:"optimal"` // 方案数已证明最少；搜索超限时为贪心结果
+	Targets     int                   `json:"targets"`
+	Covered     int                   `json:"covered"`
+	TotalCost   float64               `json:"total_cost"`
+	Steps       []EngraveScheduleStep `json:"steps"`
+	Uncoverable []string              `json:"uncoverable"` // 没有任何地点能刷出的武器
+	uncoverable []WeaponData
+}
+
+// plannerTarget - 参与规划的未毕业武器及其优先级
+type plannerTarget struct {
+	weapon WeaponData
+	weight float64
+}
+
+// plannerBits - 武器集合的位图
+type plannerBits []uint64
+
+func newPlannerBits(n int) plannerBits { return make(plannerBits, (n+63)/64) }
+
+func (b plannerBits) set(i int)      { b[i/64] |= 1 << (i % 64) }
+func (b plannerBits) has(i int) bool { return b[i/64]&(1<<(i%64)) != 0 }
+
+func (b plannerBits) count() int {
+	n := 0
+	for _, w := range b {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// subsetOf - b 是否为 o 的子集
+func (b plannerBits) subsetOf(o plannerBits) bool {
+	for i := range b {
+		if b[i]&^o[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadPlannerPriorities - 读取武器优先级文件：武器名（internal_id / 中文名 / 英文名）→ 权重。
+// 未列出的武器权重为 1，权重 <= 0 的武器不参与规划。path 为空或文件不存在时返回空表；
+// 找不到的武器名记录警告后跳过，不影响其他条目。
+func LoadPlannerPriorities(path string) (map[string]float64, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]float64
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	priorities := make(map[string]float64, len(raw))
+	for name, weight := range raw {
+		w, ok := weaponByName(name)
+		if !ok {
+			log.Warn().Str("component", "EssenceFilter").Str("path", path).Str("weapon", name).Msg("unknown weapon in planner priorities, skipped")
+			continue
+		}
+		priorities[w.InternalID] = weight
+	}
+	return priorities, nil
+}
+
+// PlanEngraveSchedule - 为未毕业武器规划预刻写排期。
+//
+// 先枚举各地点的全部方案并剔除被支配的方案（覆盖集合是另一方案的子集且成本不更低），
+// 再求最少方案数的集合覆盖（方案数相同时取总成本最低，再取高优先级武器更早完成者）：先用贪心（优先级/成本最大者优先）得到上界，
+// 再在 plannerMaxNodes 内做分支定界搜索。最终方案按 新增优先级/成本 依次排序为排期。
+// priorities 以 internal_id 为键，缺省权重为 1。
+func PlanEngraveSchedule(weapons []WeaponData, priorities map[string]float64) *EngraveSchedule {
+	schedule := &EngraveSchedule{GeneratedAt: time.Now().Format(time.RFC3339), Optimal: true}
+
+	var targets []plannerTarget
+	for _, w := range weapons {
+		weight, ok := priorities[w.InternalID]
+		if !ok {
+			weight = 1
+		}
+		if weight > 0 && len(w.SkillIDs) == 3 {
+			targets = append(targets, plannerTarget{weapon: w, weight: weight})
+		}
+	}
+	schedule.Targets = len(targets)
+	if len(targets) == 0 {
+		return schedule
+	}
+
+	plans := prunePlans(enumeratePlans(targets))
+	reachable := newPlannerBits(len(targets))
+	for _, p := range plans {
+		for i := range reachable {
+			reachable[i] |= p.covers[i]
+		}
+	}
+	for i, t := range targets {
+		if !reachable.has(i) {
+			schedule.Uncoverable = append(schedule.Uncoverable, t.weapon.ChineseName)
+			schedule.uncoverable = append(schedule.uncoverable, t.weapon)
+		}
+	}
+
+	chosen := greedyCover(plans, targets, reachable)
+	better, optimal := exactCover(plans, targets, reachable, chosen)
+	if better != nil {
+		chosen = better
+	}
+	schedule.Optimal = optimal
+
+	covered := newPlannerBits(len(targets))
+	for _, p := range orderPlans(chosen, targets) {
+		step := EngraveScheduleStep{Step: len(schedule.Steps) + 1, Plan: p}
+		for i, t := range targets {
+			if p.covers.has(i) && !covered.has(i) {
+				covered.set(i)
+				step.NewWeapons = append(step.NewWeapons, t.weapon.ChineseName)
+				step.newWeapons = append(step.newWeapons, t.weapon)
+				step.Priority += t.weight
+			}
+		}
+		step.Covered = covered.count()
+		schedule.Steps = append(schedule.Steps, step)
+		schedule.TotalCost += p.Cost
+	}
+	schedule.Covered = covered.count()
+	return schedule
+}
+
+// enumeratePlans - 枚举所有地点（无地点数据时为全部技能池）下覆盖至少一把武器的方案
+func enumeratePlans(targets []plannerTarget) []EngravePlan {
+	locations := weaponDB.Locations
+	if len(locations) == 0 {
+		all := Location{Name: "全部地点"}
+		for _, s := range weaponDB.SkillPools.Slot2 {
+			all.Slot2IDs = append(all.Slot2IDs, s.ID)
+		}
+		for _, s := range weaponDB.SkillPools.Slot3 {
+			all.Slot3IDs = append(all.Slot3IDs, s.ID)
+		}
+		locations = []Location{all}
+	}
+
+	slot1 := weaponDB.SkillPools.Slot1
+	var plans []EngravePlan
+	for _, loc := range locations {
+		inSlot2 := make(map[int]bool, len(loc.Slot2IDs))
+		for _, id := range loc.Slot2IDs {
+			inSlot2[id] = true
+		}
+		inSlot3 := make(map[int]bool, len(loc.Slot3IDs))
+		for _, id := range loc.Slot3IDs {
+			inSlot3[id] = true
+		}
+		for i := 0; i < len(slot1); i++ {
+			for j := i + 1; j < len(slot1); j++ {
+				for k := j + 1; k < len(slot1); k++ {
+					s1 := [3]int{slot1[i].ID, slot1[j].ID, slot1[k].ID}
+					for _, fixed := range []struct {
+						slot  int
+						ids   []int
+						other int // 未固定槽位的池大小
+					}{{2, loc.Slot2IDs, len(loc.Slot3IDs)}, {3, loc.Slot3IDs, len(loc.Slot2IDs)}} {
+						for _, id := range fixed.ids {
+							p := EngravePlan{
+								Location:   loc.Name,
+								Slot1IDs:   s1,
+								Slot1Names: [3]string{slot1[i].Chinese, slot1[j].Chinese, slot1[k].Chinese},
+								FixedSlot:  fixed.slot,
+								FixedID:    id,
+								FixedName:  skillNameByID(id, getPoolBySlot(fixed.slot)),
+								Cost:       float64(3 * max(1, fixed.other)),
+								covers:     newPlannerBits(len(targets)),
+							}
+							for t, target := range targets {
+								ids := target.weapon.SkillIDs
+								if ids[0] != s1[0] && ids[0] != s1[1] && ids[0] != s1[2] {
+									continue
+								}
+								if ids[fixed.slot-1] != id || !inSlot2[ids[1]] || !inSlot3[ids[2]] {
+									continue
+								}
+								p.covers.set(t)
+								p.Covers = append(p.Covers, target.weapon.ChineseName)
+							}
+							if len(p.Covers) > 0 {
+								plans = append(plans, p)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return plans
+}
+
+// prunePlans - 剔除被支配的方案：覆盖集合是另一方案的子集且成本不更低（完全相同时保留先出现者）
+func prunePlans(plans []EngravePlan) []EngravePlan {
+	kept := make([]EngravePlan, 0, len(plans))
+	for i, p := range plans {
+		dominated := false
+		for j, q := range plans {
+			if i == j || !p.covers.subsetOf(q.covers) || q.Cost > p.Cost {
+				continue
+			}
+			if q.covers.subsetOf(p.covers) && q.Cost == p.Cost && j > i {
+				continue
+			}
+			dominated = true
+			break
+		}
+		if !dominated {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// newWeight - 方案在已覆盖集合之外新增武器的优先级之和
+func newWeight(p EngravePlan, covered plannerBits, targets []plannerTarget) float64 {
+	w := 0.0
+	for i, t := range targets {
+		if p.covers.has(i) && !covered.has(i) {
+			w += t.weight
+		}
+	}
+	return w
+}
+
+// orderPlans - 按 新增优先级/成本 依次排序为排期
+func orderPlans(chosen []EngravePlan, targets []plannerTarget) []EngravePlan {
+	rest := append([]EngravePlan(nil), chosen...)
+	ordered := make([]EngravePlan, 0, len(rest))
+	covered := newPlannerBits(len(targets))
+	for len(rest) > 0 {
+		best, bestScore := 0, -1.0
+		for i, p := range rest {
+			if score := newWeight(p, covered, targets) / p.Cost; score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		p := rest[best]
+		rest = append(rest[:best], rest[best+1:]...)
+		ordered = append(ordered, p)
+		for i := range covered {
+			covered[i] |= p.covers[i]
+		}
+	}
+	return ordered
+}
+
+// weightedWaitCost - 排期中每把武器的优先级 × 覆盖它之前（含当步）累计的成本之和，越小表示高优先级武器越早完成
+func weightedWaitCost(chosen []EngravePlan, targets []plannerTarget) float64 {
+	covered := newPlannerBits(len(targets))
+	total, spent := 0.0, 0.0
+	for _, p := range orderPlans(chosen, targets) {
+		spent += p.Cost
+		for i, t := range targets {
+			if p.covers.has(i) && !covered.has(i) {
+				covered.set(i)
+				total += t.weight * spent
+			}
+		}
+	}
+	return total
+}
+
+// greedyCover - 加权贪心集合覆盖：每次选 新增优先级/成本 最大的方案
+func greedyCover(plans []EngravePlan, targets []plannerTarget, reachable plannerBits) []EngravePlan {
+	covered := newPlannerBits(len(targets))
+	var chosen []EngravePlan
+	for covered.count() < reachable.count() {
+		best, bestScore := -1, 0.0
+		for i, p := range plans {
+			if score := newWeight(p, covered, targets) / p.Cost; score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			break
+		}
+		chosen = append(chosen, plans[best])
+		for i := range covered {
+			covered[i] |= plans[best].covers[i]
+		}
+	}
+	return chosen
+}
+
+// exactCover - 分支定界求最少方案数的覆盖，以 incumbent 为初始上界。
+// 方案数相同时取总成本最低，成本也相同时取 weightedWaitCost 最小者，即让高优先级武器更早完成。
+// 返回更优的解（没有更优时为 nil）以及搜索是否在节点上限内完成。
+func exactCover(plans []EngravePlan, targets []plannerTarget, reachable plannerBits, incumbent []EngravePlan) ([]EngravePlan, bool) {
+	n := len(reachable) * 64
+	// 每把武器可被哪些方案覆盖，按覆盖数降序尝试
+	byTarget := make([][]int, n)
+	maxCover := 1
+	for i, p := range plans {
+		maxCover = max(maxCover, p.covers.count())
+		for t := 0; t < n; t++ {
+			if p.covers.has(t) {
+				byTarget[t] = append(byTarget[t], i)
+			}
+		}
+	}
+	for t := range byTarget {
+		sort.SliceStable(byTarget[t], func(a, b int) bool {
+			return plans[byTarget[t][a]].covers.count() > plans[byTarget[t][b]].covers.count()
+		})
+	}
+
+	bestCount, bestCost := len(incumbent), 0.0
+	for _, p := range incumbent {
+		bestCost += p.Cost
+	}
+	bestWait := weightedWaitCost(incumbent, targets)
+	var best []int
+	nodes := 0
+	complete := true
+
+	var path []int
+	var search func(covered plannerBits, cost float64)
+	search = func(covered plannerBits, cost float64) {
+		nodes++
+		if nodes > plannerMaxNodes {
+			complete = false
+			return
+		}
+		remaining := reachable.count() - covered.count()
+		if remaining == 0 {
+			if len(path) > bestCount || (len(path) == bestCount && cost > bestCost) {
+				return
+			}
+			cand := make([]EngravePlan, len(path))
+			for i, pi := range path {
+				cand[i] = plans[pi]
+			}
+			wait := weightedWaitCost(cand, targets)
+			if len(path) < bestCount || cost < bestCost || wait < bestWait {
+				bestCount, bestCost, bestWait = len(path), cost, wait
+				best = append([]int(nil), path...)
+			}
+			return
+		}
+		bound := len(path) + (remaining+maxCover-1)/maxCover
+		if bound > bestCount || (bound == bestCount && cost >= bestCost) {
+			return
+		}
+
+		// 选择可选方案最少的未覆盖武器分支
+		pick, pickLen := -1, 0
+		for t := 0; t < n; t++ {
+			if reachable.has(t) && !covered.has(t) && (pick < 0 || len(byTarget[t]) < pickLen) {
+				pick, pickLen = t, len(byTarget[t])
+			}
+		}
+		for _, pi := range byTarget[pick] {
+			next := append(plannerBits(nil), covered...)
+			for i := range next {
+				next[i] |= plans[pi].covers[i]
+			}
+			path = append(path, pi)
+			search(next, cost+plans[pi].Cost)
+			path = path[:len(path)-1]
+			if !complete {
+				return
+			}
+		}
+	}
+	search(newPlannerBits(n), 0)
+
+	if best == nil {
+		return nil, complete
+	}
+	result := make([]EngravePlan, len(best))
+	for i, pi := range best {
+		result[i] = plans[pi]
+	}
+	return result, complete
+}
+
+// SaveEngraveSchedule - 将排期写入 JSON 文件
+func SaveEngraveSchedule(schedule *EngraveSchedule, path string) error {
+	data, err := json.MarshalIndent(schedule, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,276 @@
+package essencefilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// EssenceTargetRule - 用户自定义目标规则，比按稀有度整档选择更细粒度。
+//
+// 武器条件（weapons / weapon_types / rarities）之间取交集，三者全空表示不按武器筛选；
+// 指定了武器条件时，基质的三个词条必须与其中某把武器的技能组合完全一致。
+// 词条条件（slot_skills / min_levels）按槽位检查，空列表或 0 表示该槽位不限。
+// exclude 为 true 时规则作为排除项：命中即不锁定，优先于其他所有匹配。
+type EssenceTargetRule struct {
+	Name        string   `json:"name"`
+	Weapons     []string `json:"weapons"`      // internal_id / 中文名 / 英文名
+	WeaponTypes []string `json:"weapon_types"` // 类型 ID / 中文名 / 英文名
+	Rarities    []int    `json:"rarities"`
+	SlotSkills  [3][]int `json:"slot_skills"` // 各槽位允许的技能 ID
+	MinLevels   [3]int   `json:"min_levels"`  // 各槽位最低等级
+	Exclude     bool     `json:"exclude"`
+}
+
+// compiledTargetRule - 校验后的规则，武器条件已解析为具体武器
+type compiledTargetRule struct {
+	EssenceTargetRule
+	label   string       // 规则名，未命名时为序号
+	weapons []WeaponData // 无武器条件时为 nil
+}
+
+// hasWeaponFilter - 规则是否包含武器条件
+func (r *EssenceTargetRule) hasWeaponFilter() bool {
+	return len(r.Weapons) > 0 || len(r.WeaponTypes) > 0 || len(r.Rarities) > 0
+}
+
+// displayName - 用于日志展示的规则名
+func (r *EssenceTargetRule) displayName(index int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("#%d", index+1)
+}
+
+// LoadTargetRules - 合并选项中的内联规则与规则文件，并按武器数据库校验。
+// 规则文件为 EssenceTargetRule 数组的 JSON；path 为空或文件不存在时仅使用内联规则。
+// 任一规则引用了不存在的武器、类型或技能，或没有任何条件时返回错误。
+func LoadTargetRules(inline []EssenceTargetRule, path string) ([]compiledTargetRule, error) {
+	rules := append([]EssenceTargetRule(nil), inline...)
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case os.IsNotExist(err):
+			log.Debug().Str("component", "EssenceFilter").Str("path", path).Msg("target rules file not found, using inline rules only")
+		case err != nil:
+			return nil, err
+		default:
+			var fileRules []EssenceTargetRule
+			if err := json.Unmarshal(data, &fileRules); err != nil {
+				return nil, fmt.Errorf("parse %s: %w", path, err)
+			}
+			rules = append(rules, fileRules...)
+		}
+	}
+
+	compiled := make([]compiledTargetRule, 0, len(rules))
+	for i, r := range rules {
+		c, err := compileTargetRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.displayName(i), err)
+		}
+		c.label = r.displayName(i)
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// compileTargetRule - 校验单条规则并解析武器条件
+func compileTargetRule(r EssenceTargetRule) (compiledTargetRule, error) {
+	c := compiledTargetRule{EssenceTargetRule: r}
+
+	hasSlotFilter := false
+	for i := 0; i < 3; i++ {
+		pool := getPoolBySlot(i + 1)
+		for _, id := range r.SlotSkills[i] {
+			if skillNameByID(id, pool) == "" {
+				return c, fmt.Errorf("unknown skill id %d in slot %d", id, i+1)
+			}
+		}
+		if r.MinLevels[i] < 0 || r.MinLevels[i] > 6 {
+			return c, fmt.Errorf("min level %d of slot %d out of range", r.MinLevels[i], i+1)
+		}
+		if len(r.SlotSkills[i]) > 0 || r.MinLevels[i] > 0 {
+			hasSlotFilter = true
+		}
+	}
+	if !r.hasWeaponFilter() {
+		if !hasSlotFilter {
+			return c, fmt.Errorf("no condition")
+		}
+		return c, nil
+	}
+
+	typeIDs := make([]int, 0, len(r.WeaponTypes))
+	for _, t := range r.WeaponTypes {
+		id, ok := weaponTypeIDByName(t)
+		if !ok {
+			return c, fmt.Errorf("unknown weapon type %q", t)
+		}
+		typeIDs = append(typeIDs, id)
+	}
+	for _, name := range r.Weapons {
+		if _, ok := weaponByName(name); !ok {
+			return c, fmt.Errorf("unknown weapon %q", name)
+		}
+	}
+
+	for _, w := range weaponDB.Weapons {
+		if len(r.Weapons) > 0 && !slices.ContainsFunc(r.Weapons, func(name string) bool { return weaponHasName(w, name) }) {
+			continue
+		}
+		if len(typeIDs) > 0 && !slices.Contains(typeIDs, w.TypeID) {
+			continue
+		}
+		if len(r.Rarities) > 0 && !slices.Contains(r.Rarities, w.Rarity) {
+			continue
+		}
+		if len(w.SkillIDs) == 3 {
+			c.weapons = append(c.weapons, w)
+		}
+	}
+	if len(c.weapons) == 0 {
+		return c, fmt.Errorf("weapon conditions select no weapon")
+	}
+	return c, nil
+}
+
+// weaponHasName - 按 internal_id / 中文名 / 英文名（忽略大小写）判断武器
+func weaponHasName(w WeaponData, name string) bool {
+	name = strings.TrimSpace(name)
+	return name == w.InternalID || name == w.ChineseName || (w.EnglishName != "" && strings.EqualFold(name, w.EnglishName))
+}
+
+// weaponByName - 按名称查找武器
+func weaponByName(name string) (WeaponData, bool) {
+	for _, w := range weaponDB.Weapons {
+		if weaponHasName(w, name) {
+			return w, true
+		}
+	}
+	return WeaponData{}, false
+}
+
+// weaponTypeIDByName - 按类型 ID / 中文名 / 英文名（忽略大小写）查找武器类型
+func weaponTypeIDByName(name string) (int, bool) {
+	name = strings.TrimSpace(name)
+	id, err := strconv.Atoi(name)
+	for _, t := range weaponDB.WeaponTypes {
+		if (err == nil && t.ID == id) || name == t.Chinese || strings.EqualFold(name, t.English) {
+			return t.ID, true
+		}
+	}
+	return 0, false
+}
+
+// ocrSkillIDsBySlot - 将 OCR 技能按位置映射到对应槽位的技能 ID，未匹配的位置 ok 为 false
+func ocrSkillIDsBySlot(ocrSkills []string) (ids [3]int, ok [3]bool) {
+	buildSlotIndicesOnce.Do(buildSlotIndices)
+	for i := 0; i < 3 && i < len(ocrSkills); i++ {
+		ids[i], ok[i] = matchSkillIDEnhanced(i+1, ocrSkills[i])
+	}
+	return ids, ok
+}
+
+// match - 判断基质是否满足规则，返回命中的武器（无武器条件时为空）
+func (r *compiledTargetRule) match(ids [3]int, ok [3]bool, levels [3]int) ([]WeaponData, bool) {
+	for i := 0; i < 3; i++ {
+		if len(r.SlotSkills[i]) > 0 && (!ok[i] || !slices.Contains(r.SlotSkills[i], ids[i])) {
+			return nil, false
+		}
+		if levels[i] < r.MinLevels[i] {
+			return nil, false
+		}
+	}
+	if r.weapons == nil {
+		return []WeaponData{}, true
+	}
+	if !ok[0] || !ok[1] || !ok[2] {
+		return nil, false
+	}
+	var weapons []WeaponData
+	for _, w := range r.weapons {
+		if w.SkillIDs[0] == ids[0] && w.SkillIDs[1] == ids[1] && w.SkillIDs[2] == ids[2] {
+			weapons = append(weapons, w)
+		}
+	}
+	return weapons, len(weapons) > 0
+}
+
+// MatchExcludeRules - 检查排除规则，返回第一条命中的排除规则；未命中时返回 nil
+func MatchExcludeRules(ocrSkills []string, levels [3]int) *compiledTargetRule {
+	if len(targetRules) == 0 {
+		return nil
+	}
+	ids, ok := ocrSkillIDsBySlot(ocrSkills)
+	for i := range targetRules {
+		r := &targetRules[i]
+		if !r.Exclude {
+			continue
+		}
+		if _, hit := r.match(ids, ok, levels); hit {
+			return r
+		}
+	}
+	return nil
+}
+
+// MatchTargetRules - 按顺序检查保留规则，返回第一条命中的规则及匹配结果。
+// 有武器条件的规则返回命中的武器；否则 Weapons 为空。
+// 优先度低于 MatchEssenceSkills，高于未来可期/实用基质等扩展规则
+func MatchTargetRules(ocrSkills []string, levels [3]int) (*SkillCombinationMatch, *compiledTargetRule, bool) {
+	if len(targetRules) == 0 {
+		return nil, nil, false
+	}
+	ids, ok := ocrSkillIDsBySlot(ocrSkills)
+	for i := range targetRules {
+		r := &targetRules[i]
+		if r.Exclude {
+			continue
+		}
+		weapons, hit := r.match(ids, ok, levels)
+		if !hit {
+			continue
+		}
+		match := &SkillCombinationMatch{
+			SkillIDs:      []int{ids[0], ids[1], ids[2]},
+			SkillsChinese: append([]string(nil), ocrSkills...),
+			Weapons:       weapons,
+		}
+		if len(weapons) > 0 {
+			match.SkillsChinese = append([]string(nil), weapons[0].SkillsChinese...)
+		}
+		log.Info().
+			Str("component", "EssenceFilter").
+			Str("rule", r.label).
+			Ints("skill_ids", match.SkillIDs).
+			Ints("levels", levels[:]).
+			Int("weapons", len(weapons)).
+			Msg("target rule hit")
+		return match, r, true
+	}
+	return nil, nil, false
+}
+
+// logTargetRules - 在日志中展示已加载的自定义规则
+func logTargetRules() {
+	for _, r := range targetRules {
+		names := make([]string, 0, len(r.weapons))
+		for _, w := range r.weapons {
+			names = append(names, w.ChineseName)
+		}
+		log.Info().
+			Str("component", "EssenceFilter").
+			Str("step", "LoadTargetRules").
+			Str("rule", r.label).
+			Bool("exclude", r.Exclude).
+			Strs("weapons", names).
+			Ints("min_levels", r.MinLevels[:]).
+			Msg("target rule loaded")
+	}
+}
@@ -1,9 +1,12 @@
 package essencefilter
 
+import "github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/minicv"
+
 // WeaponData - weapon data
 type WeaponData struct {
 	InternalID    string   `json:"internal_id"`
 	ChineseName   string   `json:"chinese_name"`
+	EnglishName   string   `json:"english_name"`
 	TypeID        int      `json:"type_id"`
 	Rarity        int      `json:"rarity"`
 	SkillIDs      []int    `json:"skill_ids"`      // [slot1_id, slot2_id, slot3_id]
@@ -24,14 +27,19 @@ type Location struct {
 	Slot3IDs []int  `json:"slot3_ids"`
 }
 
-// WeaponDatabase - weapon DB
+// WeaponType - 武器类型
+type WeaponType struct {
+	ID      int    `json:"id"`
+	English string `json:"english"`
+	Chinese string `json:"chinese"`
+}
+
+// WeaponDatabase - weapon DB；覆盖文件使用同一格式，只需写出新增或替换的条目
 type WeaponDatabase struct {
-	WeaponTypes []struct {
-		ID      int    `json:"id"`
-		English string `json:"english"`
-		Chinese string `json:"chinese"`
-	} `json:"weapon_types"`
-	SkillPools struct {
+	Version     int          `json:"version"`            // 格式版本，缺省为 1
+	Revision    string       `json:"revision,omitempty"` // 数据修订标识，仅用于展示
+	WeaponTypes []WeaponType `json:"weapon_types"`
+	SkillPools  struct {
 		Slot1 []SkillPool `json:"slot1"`
 		Slot2 []SkillPool `json:"slot2"`
 		Slot3 []SkillPool `json:"slot3"`
@@ -52,6 +60,9 @@ type SkillCombinationMatch struct {
 	SkillIDs      []int
 	SkillsChinese []string
 	Weapons       []WeaponData
+	Confidence    float64 // 组合解码的平均相似度；逐槽精确匹配时为 1
+	Decoded       bool    // 是否由 top-k 候选组合解码得到
+	Ambiguous     bool    // 可能是目标组合但置信度不足，需人工确认
 }
 
 // SkillCombinationSummary - 本次运行中某一套技能组合的锁定统计
@@ -63,8 +74,15 @@ type SkillCombinationSummary struct {
 	Count         int
 }
 
-// MatcherConfig - 匹配器配置结构
+// MatcherConfig - 匹配器配置结构；顶层为简体中文配置，languages 按语言代码覆盖
 type MatcherConfig struct {
+	SimilarWordMap  map[string]string                `json:"similarWordMap"`
+	SuffixStopwords []string                         `json:"suffixStopwords"`
+	Languages       map[string]LanguageMatcherConfig `json:"languages"`
+}
+
+// LanguageMatcherConfig - 单一语言的相近字映射与停用后缀（均作用于清洗后的文本）
+type LanguageMatcherConfig struct {
 	SimilarWordMap  map[string]string `json:"similarWordMap"`
 	SuffixStopwords []string          `json:"suffixStopwords"`
 }
@@ -75,6 +93,8 @@ type EssenceFilterOptions struct {
 	Rarity4Weapon   bool `json:"rarity4_weapon"`
 	FlawlessEssence bool `json:"flawless_essence"`
 	PureEssence     bool `json:"pure_essence"`
+	// 额外处理的基质类型 ID（见 essence_types.json），与上面两个选项合并
+	EssenceTypes []string `json:"essence_types"`
 
 	// 保留未来可期基质：三种词条且总等级 >= n
 	KeepFuturePromising     bool `json:"keep_future_promising"`
@@ -86,26 +106,42 @@ type EssenceFilterOptions struct {
 	DiscardUnmatched bool `json:"discard_unmatched"`
 	// 筛选结束后推荐预刻写方案（枚举最优方案并输出到日志）
 	ExportCalculatorScript bool `json:"export_calculator_script"`
+	// 预刻写排期：武器优先级文件与排期 JSON 输出路径（见 planner.go）
+	PlannerPriorityPath string `json:"planner_priority_path"`
+	PlannerOutputPath   string `json:"planner_output_path"`
+	// 自定义目标规则：内联规则与规则文件路径（见 rules.go）
+	TargetRules     []EssenceTargetRule `json:"target_rules"`
+	TargetRulesPath string              `json:"target_rules_path"`
+	// 库存记录：持久化每个扫描到的基质，结束时可导出 JSON/CSV（见 inventory.go）
+	RecordInventory bool   `json:"record_inventory"`
+	InventoryPath   string `json:"inventory_path"`
+	ExportInventory bool   `json:"export_inventory"`
+	// 试运行：只识别并汇总预期的锁定/废弃结果，不操作物品
+	DryRun bool `json:"dry_run"`
+	// 客户端语言（如 zh_cn / en_us），决定技能 OCR 的清洗与匹配方式
+	ClientLanguage string `json:"client_language"`
+	// 续扫：从上次中断的断点继续（见 checkpoint.go），断点路径为空时使用默认路径
+	ResumeScan     bool   `json:"resume_scan"`
+	CheckpointPath string `json:"checkpoint_path"`
 }
 
-type ColorRange struct {
-	Lower [3]int
-	Upper [3]int
-}
+// ColorRange is an HSV range in OpenCV 8-bit units, see minicv.HSVRange
+type ColorRange = minicv.HSVRange
 
-type EssenceMeta struct {
-	Name  string
-	Range ColorRange
-}
+// Minimum pixel count of the largest connected region for an essence color match
+const essenceColorMatchMinCount = 100
 
 // Global variables
 var (
 	weaponDB                WeaponDatabase
 	targetSkillCombinations []SkillCombination
+	targetRules             []compiledTargetRule
 	visitedCount            int
 	matchedCount            int
 	extFuturePromisingCount int
 	extSlot3PracticalCount  int
+	extTargetRuleCount      int
+	excludedCount           int
 	filteredSkillStats      [3]map[int]int
 	statsLogged             bool
 
@@ -113,41 +149,43 @@ var (
 	matchedCombinationSummary map[string]*SkillCombinationSummary
 
 	// Grid traversal state
-	currentCol          int // 1~9
-	currentRow          int // row index
-	maxItemsPerRow      int
-	firstRowSwipeDone   bool // true after first row swipe is used
-	finalLargeScanUsed  bool // true if final large scan has been used
-	swipeCalibrateRetry int  // 校准重试次数，防止死循环
+	currentRow          int          // row index
+	grid                *gridTracker // 背包网格几何与当前逻辑行位置
+	firstRowSwipeDone   bool         // true after first row swipe is used
+	finalLargeScanUsed  bool         // true if final large scan has been used
+	swipeCalibrateRetry int          // 校准重试次数，防止死循环
 
 	// Current item's three skills cache
 	currentSkills      [3]string
 	currentSkillLevels [3]int // 从 OCR 解析出的等级 (+1/+2/+3)，0 表示未识别
+	currentEssenceType string // 当前基质类型名（来自行内颜色分类）
 
 	// Row processing: collected boxes and index
 	rowBoxes       [][4]int
+	rowBoxTypes    map[[4]int]string // box -> 基质类型名
 	rowIndex       int
 	weaponDataPath string
 
+	// 试运行模式下记录的预期处理结果
+	auditEntries []auditEntry
+	// 疑似目标但识别置信度不足、需要人工确认的基质
+	reviewEntries []auditEntry
+
+	// 库存数据库，未开启库存记录时为 nil
+	inventoryDB *InventoryDatabase
+
+	// 扫描断点与续扫状态
+	scanCheckpoint        *ScanCheckpoint
+	pendingCheckpointKey  string // 已决定、等待锁定/废弃完成后落盘的基质
+	pendingCheckpointItem checkpointItem
+	resumeRow             int // 续扫时需快进到的行，0 表示不快进
+
 	// Matcher config - loaded from JSON config file, used for skill name matching
 	matcherConfig MatcherConfig
 
-	// Essence color matching parameters
-	FlawlessEssenceMeta = EssenceMeta{
-		// Name: "Flawless Essence",
-		Name: "无暇基质",
-		Range: ColorRange{
-			Lower: [3]int{18, 70, 220},
-			Upper: [3]int{26, 255, 255},
-		},
-	}
-	PureEssenceMeta = EssenceMeta{
-		Name: "高纯基质",
-		Range: ColorRange{
-			Lower: [3]int{130, 55, 80},
-			Upper: [3]int{136, 255, 255},
-		},
-	}
+	// 基质类型定义（essence_types.json）
+	essenceTypeDefs []EssenceMeta
 
+	// 本次选择处理的基质类型
 	EssenceTypes []EssenceMeta
 )
@@ -8,6 +8,7 @@ import (
 
 	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/maafocus"
 	maa "github.com/MaaXYZ/maa-framework-go/v4"
+	"github.com/rs/zerolog/log"
 )
 
 func LogMXUHTML(ctx *maa.Context, htmlText string) {
@@ -120,60 +121,13 @@ func escapeHTML(s string) string {
 	return html.EscapeString(s)
 }
 
-// calcPlan 描述一个预刻写方案及其对目标武器的覆盖情况。
-type calcPlan struct {
-	slot1Names [3]string
-	fixedSlot  int          // 2 = 附加属性固定, 3 = 技能属性固定
-	fixedID    int          // 固定槽位的技能 ID
-	fixedName  string       // 固定槽位的技能中文名
-	needs      []WeaponData // 未毕业目标武器中能满足的
-	matched    []WeaponData // 全部目标武器中能匹配的（含已毕业）
-}
-
 // spanColor 生成一个带颜色的 <span> 标签。
 func spanColor(color, text string) string {
 	return fmt.Sprintf(`<span style="color:%s;">%s</span>`, color, text)
 }
 
-// planCardHTML 将一个 calcPlan 格式化为带左边框的卡片 HTML 片段。
-func planCardHTML(borderColor string, idx int, p calcPlan, fixedSlotLabel [4]string) string {
-	return fmt.Sprintf(
-		`<div style="margin-top:3px;border-left:3px solid %s;padding-left:6px;">`+
-			`%s `+
-			`基础属性：%s | `+
-			`选择%s：%s<br>`+
-			`满足 <b>%d</b> 个需求 / 匹配 <b>%d</b> 件目标武器<br>`+
-			`满足的需求：%s<br>`+
-			`匹配的武器：%s</div>`,
-		borderColor,
-		spanColor("#98c379", fmt.Sprintf("方案 %d", idx)),
-		spanColor("#47b5ff", escapeHTML(strings.Join(p.slot1Names[:], "，"))),
-		fixedSlotLabel[p.fixedSlot], spanColor("#e877fe", escapeHTML(p.fixedName)),
-		len(p.needs), len(p.matched),
-		weaponListHTML(p.needs),
-		weaponListHTML(p.matched),
-	)
-}
-
-// skillIndex 是 slot1_id → fixedSlot_id → 武器列表 的二级索引，用于快速查找匹配武器。
-type skillIndex map[int]map[int][]WeaponData
-
-// buildSkillIndex 按 allTargets 中指定槽位（1=slot2, 2=slot3）构建索引。
-func buildSkillIndex(allTargets []SkillCombination, slotIdx int) skillIndex {
-	idx := make(skillIndex)
-	for _, combo := range allTargets {
-		s1 := combo.SkillIDs[0]
-		sN := combo.SkillIDs[slotIdx]
-		if idx[s1] == nil {
-			idx[s1] = make(map[int][]WeaponData)
-		}
-		idx[s1][sN] = append(idx[s1][sN], combo.Weapon)
-	}
-	return idx
-}
-
-// logCalculatorResult 在战利品摘要之后，按刷取地点枚举预刻写方案，
-// 对每个地点输出满足未毕业需求最多的前 N 个方案。
+// logCalculatorResult 在战利品摘要之后，为未毕业的目标武器规划预刻写排期（见 planner.go），
+// 输出到日志，并按选项写入 JSON 文件。
 func logCalculatorResult(ctx *maa.Context) {
 	// 1. 读取选中的武器稀有度（防御性过滤，确保计算器只含选中稀有度的武器）
 	opts, _ := getOptionsFromAttach(ctx, "EssenceFilterInit")
@@ -190,31 +144,36 @@ func logCalculatorResult(ctx *maa.Context) {
 		}
 	}
 
-	// 2. 收集已毕业（本次扫描锁定）的武器名
+	// 2. 收集已毕业的武器名：本次扫描锁定的，以及库存中最近一轮已锁定的
 	graduated := make(map[string]bool)
 	for _, s := range matchedCombinationSummary {
 		for _, w := range s.Weapons {
 			graduated[w.ChineseName] = true
 		}
 	}
+	if inventoryDB != nil {
+		for _, r := range inventoryDB.CurrentRecords() {
+			if r.Decision == InventoryDecisionLock {
+				for _, name := range r.Weapons {
+					graduated[name] = true
+				}
+			}
+		}
+	}
 
-	// 3. 去重后构建目标武器列表，仅含选中稀有度，区分已毕业与未毕业
+	// 3. 去重后构建未毕业武器列表，仅含选中稀有度
 	seenTarget := make(map[string]bool)
-	var allTargets []SkillCombination
-	var ungraduated []SkillCombination
+	var ungraduated []WeaponData
 	for _, combo := range targetSkillCombinations {
 		if len(selectedRarities) > 0 && !selectedRarities[combo.Weapon.Rarity] {
 			continue
 		}
 		name := combo.Weapon.ChineseName
-		if seenTarget[name] {
+		if seenTarget[name] || graduated[name] {
 			continue
 		}
 		seenTarget[name] = true
-		allTargets = append(allTargets, combo)
-		if !graduated[name] {
-			ungraduated = append(ungraduated, combo)
-		}
+		ungraduated = append(ungraduated, combo.Weapon)
 	}
 
 	if len(ungraduated) == 0 {
@@ -222,128 +181,67 @@ func logCalculatorResult(ctx *maa.Context) {
 		return
 	}
 
-	slot1Pool := weaponDB.SkillPools.Slot1
-	slot2Pool := weaponDB.SkillPools.Slot2
-	slot3Pool := weaponDB.SkillPools.Slot3
-	n1 := len(slot1Pool)
-	const maxPlansPerLocation = 2
-	fixedSlotLabel := [4]string{"", "", "附加属性", "技能属性"}
-
-	// 4. 预建索引：slot1_id → slot2/slot3_id → 武器列表，避免枚举时重复全量扫描
-	idx2 := buildSkillIndex(allTargets, 1)
-	idx3 := buildSkillIndex(allTargets, 2)
-
-	// lookupWeapons 通过索引快速查找给定 s1Set + fixedID 匹配的武器。
-	lookupWeapons := func(idx skillIndex, s1Set [3]int, fixedID int) (matched, needs []WeaponData) {
-		for _, s1ID := range s1Set {
-			for _, w := range idx[s1ID][fixedID] {
-				matched = append(matched, w)
-				if !graduated[w.ChineseName] {
-					needs = append(needs, w)
-				}
-			}
+	// 4. 读取武器优先级并规划
+	var priorities map[string]float64
+	if opts != nil {
+		var err error
+		priorities, err = LoadPlannerPriorities(opts.PlannerPriorityPath)
+		if err != nil {
+			log.Warn().Err(err).Str("component", "EssenceFilter").Str("path", opts.PlannerPriorityPath).Msg("load planner priorities failed")
+			LogMXUSimpleHTMLWithColor(ctx, fmt.Sprintf("武器优先级文件读取失败，按相同优先级规划：%s", err.Error()), "#ff7000")
 		}
-		return
 	}
-
-	// enumPlans 枚举某一 slot2/slot3 子集下的所有有效方案并按需求数降序排序。
-	enumPlans := func(availSlot2, availSlot3 []SkillPool) []calcPlan {
-		var plans []calcPlan
-		for i := 0; i < n1-2; i++ {
-			for j := i + 1; j < n1-1; j++ {
-				for k := j + 1; k < n1; k++ {
-					s1Names := [3]string{slot1Pool[i].Chinese, slot1Pool[j].Chinese, slot1Pool[k].Chinese}
-					s1IDs := [3]int{slot1Pool[i].ID, slot1Pool[j].ID, slot1Pool[k].ID}
-					for _, s2 := range availSlot2 {
-						matched, needs := lookupWeapons(idx2, s1IDs, s2.ID)
-						if len(needs) > 0 {
-							plans = append(plans, calcPlan{slot1Names: s1Names, fixedSlot: 2, fixedName: s2.Chinese, fixedID: s2.ID, needs: needs, matched: matched})
-						}
-					}
-					for _, s3 := range availSlot3 {
-						matched, needs := lookupWeapons(idx3, s1IDs, s3.ID)
-						if len(needs) > 0 {
-							plans = append(plans, calcPlan{slot1Names: s1Names, fixedSlot: 3, fixedName: s3.Chinese, fixedID: s3.ID, needs: needs, matched: matched})
-						}
-					}
-				}
-			}
+	schedule := PlanEngraveSchedule(ungraduated, priorities)
+	log.Info().
+		Str("component", "EssenceFilter").
+		Str("step", "PlanEngrave").
+		Int("targets", schedule.Targets).
+		Int("covered", schedule.Covered).
+		Int("plans", len(schedule.Steps)).
+		Bool("optimal", schedule.Optimal).
+		Msg("engrave schedule planned")
+
+	if opts != nil && opts.PlannerOutputPath != "" {
+		if err := SaveEngraveSchedule(schedule, opts.PlannerOutputPath); err != nil {
+			log.Error().Err(err).Str("component", "EssenceFilter").Str("path", opts.PlannerOutputPath).Msg("save engrave schedule failed")
+		} else {
+			log.Info().Str("component", "EssenceFilter").Str("path", opts.PlannerOutputPath).Msg("engrave schedule saved")
 		}
-		sort.Slice(plans, func(i, j int) bool {
-			if len(plans[i].needs) != len(plans[j].needs) {
-				return len(plans[i].needs) > len(plans[j].needs)
-			}
-			return len(plans[i].matched) > len(plans[j].matched)
-		})
-		return plans
 	}
+	LogMXUHTML(ctx, engraveScheduleHTML(schedule))
+}
 
+// engraveScheduleHTML 将预刻写排期格式化为 HTML：每一步一张卡片，列出新增覆盖的武器。
+func engraveScheduleHTML(s *EngraveSchedule) string {
+	fixedSlotLabel := [4]string{"", "", "附加属性", "技能属性"}
 	var b strings.Builder
 	b.WriteString(fmt.Sprintf(
-		`<div style="color:#00bfff;font-weight:900;margin-top:8px;">预刻写方案推荐（%d 个未毕业需求）：</div>`,
-		len(ungraduated),
+		`<div style="color:#00bfff;font-weight:900;margin-top:8px;">预刻写排期（%d 个方案覆盖 %d / %d 把未毕业武器）：</div>`,
+		len(s.Steps), s.Covered, s.Targets,
 	))
-	b.WriteString(weaponListHTML(func() []WeaponData {
-		ws := make([]WeaponData, 0, len(ungraduated))
-		for _, combo := range ungraduated {
-			ws = append(ws, combo.Weapon)
-		}
-		return ws
-	}()))
-	b.WriteString(`<br>`)
-
-	if len(weaponDB.Locations) > 0 {
-		// 按地点分组输出
-		for _, loc := range weaponDB.Locations {
-			slot2Set := make(map[int]bool)
-			for _, id := range loc.Slot2IDs {
-				slot2Set[id] = true
-			}
-			slot3Set := make(map[int]bool)
-			for _, id := range loc.Slot3IDs {
-				slot3Set[id] = true
-			}
-			var locSlot2, locSlot3 []SkillPool
-			for _, s := range slot2Pool {
-				if slot2Set[s.ID] {
-					locSlot2 = append(locSlot2, s)
-				}
-			}
-			for _, s := range slot3Pool {
-				if slot3Set[s.ID] {
-					locSlot3 = append(locSlot3, s)
-				}
-			}
-
-			plans := enumPlans(locSlot2, locSlot3)
-			if len(plans) == 0 {
-				continue
-			}
-
-			b.WriteString(fmt.Sprintf(
-				`<div style="color:#c8960c;font-weight:900;margin-top:6px;">%s</div>`,
-				escapeHTML(loc.Name),
-			))
-			show := maxPlansPerLocation
-			if len(plans) < show {
-				show = len(plans)
-			}
-			for idx, p := range plans[:show] {
-				b.WriteString(planCardHTML("#c8960c", idx+1, p, fixedSlotLabel))
-			}
-		}
-	} else {
-		// 无地点数据时退化为全局列表（兜底）
-		plans := enumPlans(slot2Pool, slot3Pool)
-		show := 10
-		if len(plans) < show {
-			show = len(plans)
-		}
-		for idx, p := range plans[:show] {
-			b.WriteString(planCardHTML("#00bfff", idx+1, p, fixedSlotLabel))
-		}
+	if !s.Optimal {
+		b.WriteString(`<div style="color:#ff7000;font-size:12px;">方案较多，未能证明方案数最少，以下为近似结果。</div>`)
 	}
-	LogMXUHTML(ctx, b.String())
+	for _, step := range s.Steps {
+		p := step.Plan
+		b.WriteString(fmt.Sprintf(
+			`<div style="margin-top:3px;border-left:3px solid #c8960c;padding-left:6px;">`+
+				`%s %s<br>`+
+				`基础属性：%s | `+
+				`选择%s：%s<br>`+
+				`新增 <b>%d</b> 把（累计 %d），期望 %.0f 次掉落/把：%s</div>`,
+			spanColor("#98c379", fmt.Sprintf("第 %d 步", step.Step)),
+			spanColor("#c8960c", escapeHTML(p.Location)),
+			spanColor("#47b5ff", escapeHTML(strings.Join(p.Slot1Names[:], "，"))),
+			fixedSlotLabel[p.FixedSlot], spanColor("#e877fe", escapeHTML(p.FixedName)),
+			len(step.newWeapons), step.Covered, p.Cost,
+			weaponListHTML(step.newWeapons),
+		))
+	}
+	if len(s.uncoverable) > 0 {
+		b.WriteString(fmt.Sprintf(`<div style="margin-top:3px;">无法在任何地点刷取：%s</div>`, weaponListHTML(s.uncoverable)))
+	}
+	return b.String()
 }
 
 // weaponListHTML 将武器列表格式化为按稀有度着色的 HTML 片段。
@@ -370,3 +268,62 @@ func formatWeaponNames(weapons []WeaponData) string {
 	// 这里采用顿号拼接，更符合中文习惯；如需本地化，可进一步抽象
 	return strings.Join(names, "、")
 }
+
+// targetRulesHTML - 展示已加载的自定义目标规则：规则名、武器与各词条条件
+func targetRulesHTML() string {
+	var b strings.Builder
+	b.WriteString(`<div style="color: #00bfff; font-weight: 900;">自定义规则：</div>`)
+	for _, r := range targetRules {
+		color, kind := "#064d7c", "保留"
+		if r.Exclude {
+			color, kind = "#ff6b6b", "排除"
+		}
+		conds := make([]string, 0, 4)
+		if r.weapons != nil {
+			conds = append(conds, "武器："+weaponListHTML(r.weapons))
+		}
+		for i := 0; i < 3; i++ {
+			if len(r.SlotSkills[i]) > 0 {
+				pool := getPoolBySlot(i + 1)
+				names := make([]string, len(r.SlotSkills[i]))
+				for j, id := range r.SlotSkills[i] {
+					names[j] = escapeHTML(skillNameByID(id, pool))
+				}
+				conds = append(conds, fmt.Sprintf("词条 %d：%s", i+1, strings.Join(names, "/")))
+			}
+			if r.MinLevels[i] > 0 {
+				conds = append(conds, fmt.Sprintf("词条 %d 等级 ≥ %d", i+1, r.MinLevels[i]))
+			}
+		}
+		b.WriteString(fmt.Sprintf(
+			`<div style="font-size: 12px;"><span style="color: %s; font-weight: 700;">[%s] %s</span> %s</div>`,
+			color, kind, escapeHTML(r.label), strings.Join(conds, "；"),
+		))
+	}
+	return b.String()
+}
+
+// logWeaponDBReport - 输出武器数据库校验问题：逐条写入日志，并在 MXU 中展示（错误红色、警告橙色）
+func logWeaponDBReport(ctx *maa.Context, report *WeaponDBReport) {
+	if !report.Reloaded || len(report.Issues) == 0 {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf(
+		`<div style="color: #00bfff; font-weight: 900;">武器数据校验：%d 个错误，%d 个警告</div>`,
+		report.Count(DBIssueError), report.Count(DBIssueWarning),
+	))
+	for _, issue := range report.Issues {
+		event, color := log.Warn(), "#ff8c00"
+		if issue.Severity == DBIssueError {
+			event, color = log.Error(), "#ff0000"
+		}
+		event.Str("component", "EssenceFilter").Str("step", "ValidateDatabase").Str("source", issue.Source).Msg(issue.Message)
+		b.WriteString(`<div style="font-size: 12px;">` +
+			spanColor(color, fmt.Sprintf("[%s] %s", escapeHTML(issue.Source), escapeHTML(issue.Message))) + `</div>`)
+	}
+	for _, file := range report.Rejected {
+		b.WriteString(`<div style="font-size: 12px;">` + spanColor("#ff0000", "已忽略："+escapeHTML(file)) + `</div>`)
+	}
+	LogMXUHTML(ctx, b.String())
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/essencefilter"
+)
+
+// runEvalEssenceMatcher runs the offline EssenceFilter skill matcher evaluation and returns the process exit code
+func runEvalEssenceMatcher(args []string) int {
+	fs := flag.NewFlagSet("eval-essencematcher", flag.ContinueOnError)
+	dataDir := fs.String("data", "assets/data/EssenceFilter", "directory containing weapons_data.json and matcher_config.json")
+	corpusPath := fs.String("corpus", "", "labelled OCR corpus (default: matcher_corpus.json inside the data directory)")
+	lang := fs.String("lang", "", "client language of the corpus (default: language in the corpus file)")
+	minSuggest := fs.Int("min-suggest", 2, "minimum occurrences for a similarWordMap suggestion")
+	jsonOut := fs.String("json", "", "write the full report as JSON to this path")
+	minPrecision := fs.Float64("min-precision", 0, "exit with code 1 if precision of any slot is below this value")
+	minRecall := fs.Float64("min-recall", 0, "exit with code 1 if recall of any slot is below this value")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *corpusPath == "" {
+		*corpusPath = filepath.Join(*dataDir, "matcher_corpus.json")
+	}
+
+	report, err := essencefilter.RunMatcherEvaluation(essencefilter.MatcherEvalOptions{
+		DataDir:         *dataDir,
+		CorpusPath:      *corpusPath,
+		Language:        *lang,
+		MinSuggestCount: *minSuggest,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "eval-essencematcher: %v\n", err)
+		return 1
+	}
+
+	report.WriteText(os.Stdout)
+
+	if *jsonOut != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "eval-essencematcher: failed to marshal report: %v\n", err)
+			return 1
+		}
+		if err := os.WriteFile(*jsonOut, data, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "eval-essencematcher: failed to write report: %v\n", err)
+			return 1
+		}
+	}
+
+	// A slot without predictions or positives cannot be evaluated, which fails any requested gate
+	if *minPrecision > 0 {
+		if p, ok := report.MinPrecision(); !ok {
+			fmt.Fprintf(os.Stderr, "eval-essencematcher: precision is N/A for some slot, cannot check required %.3f\n", *minPrecision)
+			return 1
+		} else if p < *minPrecision {
+			fmt.Fprintf(os.Stderr, "eval-essencematcher: precision %.3f is below required %.3f\n", p, *minPrecision)
+			return 1
+		}
+	}
+	if *minRecall > 0 {
+		if r, ok := report.MinRecall(); !ok {
+			fmt.Fprintf(os.Stderr, "eval-essencematcher: recall is N/A for some slot, cannot check required %.3f\n", *minRecall)
+			return 1
+		} else if r < *minRecall {
+			fmt.Fprintf(os.Stderr, "eval-essencematcher: recall %.3f is below required %.3f\n", r, *minRecall)
+			return 1
+		}
+	}
+	return 0
+}
+
+// runSeedEssenceCorpus extracts skill OCR results from a go-service log into a corpus to be reviewed by hand,
+// and returns the process exit code
+func runSeedEssenceCorpus(args []string) int {
+	fs := flag.NewFlagSet("seed-essencecorpus", flag.ContinueOnError)
+	dataDir := fs.String("data", "assets/data/EssenceFilter", "directory containing weapons_data.json and matcher_config.json")
+	logPath := fs.String("log", "debug/go-service.log", "go-service log recorded while running EssenceFilter")
+	lang := fs.String("lang", "", "client language the log was recorded with (default: zh_cn)")
+	outPath := fs.String("out", "", "write the corpus to this path (required)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *outPath == "" {
+		fmt.Fprintln(os.Stderr, "seed-essencecorpus: -out is required")
+		return 2
+	}
+
+	corpus, err := essencefilter.SeedMatcherCorpus(*dataDir, *logPath, *lang)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "seed-essencecorpus: %v\n", err)
+		return 1
+	}
+	data, err := json.MarshalIndent(corpus, "", "    ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "seed-essencecorpus: failed to marshal corpus: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(*outPath, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "seed-essencecorpus: failed to write corpus: %v\n", err)
+		return 1
+	}
+	fmt.Printf("%d entries written to %s; review every skill_id before using it for evaluation\n", len(corpus.Entries), *outPath)
+	return 0
+}
@@ -23,7 +23,24 @@ func main() {
 		Msg("MaaEnd Agent Service")
 
 	if len(os.Args) < 2 {
-		log.Fatal().Msg("Usage: go-service <identifier>")
+		log.Fatal().Msg("Usage: go-service <identifier> | go-service bench-maptracker [flags] | go-service eval-essencematcher [flags] | go-service seed-essencecorpus [flags]")
+	}
+
+	// Headless tools, no MAA framework required
+	if os.Args[1] == "bench-maptracker" {
+		code := runBenchMapTracker(os.Args[2:])
+		logFile.Close()
+		os.Exit(code)
+	}
+	if os.Args[1] == "eval-essencematcher" {
+		code := runEvalEssenceMatcher(os.Args[2:])
+		logFile.Close()
+		os.Exit(code)
+	}
+	if os.Args[1] == "seed-essencecorpus" {
+		code := runSeedEssenceCorpus(os.Args[2:])
+		logFile.Close()
+		os.Exit(code)
 	}
 
 	identifier := os.Args[1]
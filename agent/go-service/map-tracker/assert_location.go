@@ -4,7 +4,9 @@ package maptracker
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/MaaXYZ/maa-framework-go/v4"
@@ -13,15 +15,26 @@ import (
 
 type MapTrackerAssertLocation struct{}
 
-// LocationCondition represents a single condition to check
+// LocationCondition represents a single condition to check.
+// All specified fields of one condition must hold (AND logic).
 type LocationCondition struct {
-	MapName string     `json:"map_name"`
-	Target  [4]float64 `json:"target"` // [x, y, w, h]
+	MapName  string       `json:"map_name,omitempty"`
+	Target   *[4]float64  `json:"target,omitempty"`        // Rectangle [x, y, w, h]
+	Polygon  [][2]float64 `json:"polygon,omitempty"`       // Polygon vertices [[x, y], ...]
+	Circle   *[3]float64  `json:"circle,omitempty"`        // Circle [cx, cy, r]
+	Path     [][2]float64 `json:"path,omitempty"`          // Polyline [[x, y], ...], used with PathDist
+	PathDist float64      `json:"path_distance,omitempty"` // Maximum distance to Path
+	Rotation *[2]float64  `json:"rotation,omitempty"`      // Clockwise rotation range [from, to] in degrees
+
+	AllOf []LocationCondition `json:"all_of,omitempty"` // Sub-conditions using AND logic
+	AnyOf []LocationCondition `json:"any_of,omitempty"` // Sub-conditions using OR logic
+	Not   *LocationCondition  `json:"not,omitempty"`    // Sub-condition that must not hold
 }
 
 // MapTrackerAssertLocationParam represents the parameters for AssertLocation
 type MapTrackerAssertLocationParam struct {
 	// Expected is a list of conditions to check, using OR logic.
+	// Use all_of/any_of/not inside a condition for nested composition.
 	Expected []LocationCondition `json:"expected"`
 	// Precision controls the inference precision/speed tradeoff.
 	Precision float64 `json:"precision,omitempty"`
@@ -45,20 +58,23 @@ func (r *MapTrackerAssertLocation) Run(ctx *maa.Context, arg *maa.CustomRecognit
 	mapNameRegex := ".*"
 	if param.FastMode {
 		// Build map_name_regex based on expected conditions to focus the search
-		mapNamesMap := make(map[string]struct{})
 		var mapNames []string
+		restrictable := true
 		for _, condition := range param.Expected {
-			if _, exists := mapNamesMap[condition.MapName]; !exists {
-				mapNamesMap[condition.MapName] = struct{}{}
-				mapNames = append(mapNames, regexp.QuoteMeta(condition.MapName))
-			}
+			restrictable = condition.collectMapNames(&mapNames) && restrictable
 		}
-		if len(mapNames) == 0 {
+		if !restrictable {
+			log.Debug().Msg("Some conditions are not bound to a map, fast mode map filtering disabled")
+		} else if len(mapNames) == 0 {
 			log.Error().Msg("Failed to extract map names from expected conditions")
 			return nil, false
+		} else {
+			quotedNames := make([]string, 0, len(mapNames))
+			for _, name := range mapNames {
+				quotedNames = append(quotedNames, regexp.QuoteMeta(name))
+			}
+			mapNameRegex = "^(" + strings.Join(quotedNames, "|") + ")$"
 		}
-
-		mapNameRegex = "^(" + strings.Join(mapNames, "|") + ")$"
 	}
 
 	// Prepare and run MapTrackerInfer
@@ -104,18 +120,15 @@ func (r *MapTrackerAssertLocation) Run(ctx *maa.Context, arg *maa.CustomRecognit
 
 	// Check if current location satisfies any of the expected conditions
 	for _, condition := range param.Expected {
-		if result.MapName == condition.MapName {
-			x, y, w, h := condition.Target[0], condition.Target[1], condition.Target[2], condition.Target[3]
-			if result.X >= x && result.X < x+w && result.Y >= y && result.Y < y+h {
-				log.Info().
-					Interface("expected", condition).
-					Msg("Location assertion satisfied")
-
-				return &maa.CustomRecognitionResult{
-					Box:    arg.Roi,
-					Detail: res.DetailJson,
-				}, true
-			}
+		if condition.Eval(&result) {
+			log.Info().
+				Interface("expected", condition).
+				Msg("Location assertion satisfied")
+
+			return &maa.CustomRecognitionResult{
+				Box:    arg.Roi,
+				Detail: res.DetailJson,
+			}, true
 		}
 	}
 
@@ -135,17 +148,134 @@ func (r *MapTrackerAssertLocation) parseParam(paramStr string) (*MapTrackerAsser
 		return nil, fmt.Errorf("expected conditions must be provided")
 	}
 	for i, condition := range param.Expected {
-		if condition.MapName == "" {
-			return nil, fmt.Errorf("map_name must be provided for expected condition at index %d", i)
-		}
-		if len(condition.Target) != 4 {
-			return nil, fmt.Errorf("target must have 4 numbers [x, y, w, h] for expected condition at index %d", i)
-		}
-		if condition.Target[2] <= 0 || condition.Target[3] <= 0 {
-			return nil, fmt.Errorf("width and height in target must be positive for expected condition at index %d", i)
+		if err := condition.validate(); err != nil {
+			return nil, fmt.Errorf("%w for expected condition at index %d", err, i)
 		}
 	}
 	// Precision and Threshold will be validated in MapTrackerInfer, omitted here
 
 	return &param, nil
 }
+
+// Eval reports whether the inference result satisfies this condition
+func (c *LocationCondition) Eval(result *MapTrackerInferResult) bool {
+	if c.MapName != "" && result.MapName != c.MapName {
+		return false
+	}
+	if c.Target != nil {
+		x, y, w, h := c.Target[0], c.Target[1], c.Target[2], c.Target[3]
+		if result.X < x || result.X >= x+w || result.Y < y || result.Y >= y+h {
+			return false
+		}
+	}
+	if len(c.Polygon) > 0 && !isPointInPolygon(result.X, result.Y, c.Polygon) {
+		return false
+	}
+	if c.Circle != nil && math.Hypot(result.X-c.Circle[0], result.Y-c.Circle[1]) > c.Circle[2] {
+		return false
+	}
+	if len(c.Path) > 0 && distanceToPath(result.X, result.Y, c.Path) > c.PathDist {
+		return false
+	}
+	if c.Rotation != nil && !isRotationInRange(result.Rot, c.Rotation[0], c.Rotation[1]) {
+		return false
+	}
+	for i := range c.AllOf {
+		if !c.AllOf[i].Eval(result) {
+			return false
+		}
+	}
+	if len(c.AnyOf) > 0 {
+		anyHit := false
+		for i := range c.AnyOf {
+			if c.AnyOf[i].Eval(result) {
+				anyHit = true
+				break
+			}
+		}
+		if !anyHit {
+			return false
+		}
+	}
+	if c.Not != nil && c.Not.Eval(result) {
+		return false
+	}
+	return true
+}
+
+// hasLocationConstraint reports whether this condition constrains the location itself
+func (c *LocationCondition) hasLocationConstraint() bool {
+	return c.Target != nil || len(c.Polygon) > 0 || c.Circle != nil || len(c.Path) > 0
+}
+
+// collectMapNames appends map names this condition may be satisfied on.
+// It returns false if the condition can be satisfied on any map.
+func (c *LocationCondition) collectMapNames(names *[]string) bool {
+	if c.MapName != "" {
+		if !slices.Contains(*names, c.MapName) {
+			*names = append(*names, c.MapName)
+		}
+		return true
+	}
+	for i := range c.AllOf {
+		// One map-bound sub-condition is enough to bind the whole conjunction
+		var subNames []string
+		if c.AllOf[i].collectMapNames(&subNames) {
+			for _, name := range subNames {
+				if !slices.Contains(*names, name) {
+					*names = append(*names, name)
+				}
+			}
+			return true
+		}
+	}
+	if len(c.AnyOf) > 0 {
+		restrictable := true
+		for i := range c.AnyOf {
+			restrictable = c.AnyOf[i].collectMapNames(names) && restrictable
+		}
+		return restrictable
+	}
+	return false
+}
+
+func (c *LocationCondition) validate() error {
+	isLeaf := len(c.AllOf) == 0 && len(c.AnyOf) == 0 && c.Not == nil
+	if isLeaf && c.MapName == "" {
+		return fmt.Errorf("map_name must be provided")
+	}
+	if isLeaf && !c.hasLocationConstraint() && c.Rotation == nil {
+		return fmt.Errorf("one of target, polygon, circle, path or rotation must be provided")
+	}
+	if c.Target != nil && (c.Target[2] <= 0 || c.Target[3] <= 0) {
+		return fmt.Errorf("width and height in target must be positive")
+	}
+	if len(c.Polygon) > 0 && len(c.Polygon) < 3 {
+		return fmt.Errorf("polygon must have at least 3 vertices")
+	}
+	if c.Circle != nil && c.Circle[2] <= 0 {
+		return fmt.Errorf("radius in circle must be positive")
+	}
+	if len(c.Path) > 0 && c.PathDist <= 0 {
+		return fmt.Errorf("path_distance must be positive when path is provided")
+	}
+	if len(c.Path) == 0 && c.PathDist != 0 {
+		return fmt.Errorf("path must be provided when path_distance is set")
+	}
+	for i := range c.AllOf {
+		if err := c.AllOf[i].validate(); err != nil {
+			return fmt.Errorf("%w in all_of[%d]", err, i)
+		}
+	}
+	for i := range c.AnyOf {
+		if err := c.AnyOf[i].validate(); err != nil {
+			return fmt.Errorf("%w in any_of[%d]", err, i)
+		}
+	}
+	if c.Not != nil {
+		if err := c.Not.validate(); err != nil {
+			return fmt.Errorf("%w in not", err)
+		}
+	}
+	return nil
+}
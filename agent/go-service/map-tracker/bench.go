@@ -0,0 +1,364 @@
+// Copyright (c) 2026 Harry Huang
+package maptracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/minicv"
+)
+
+// BenchFrameLabel represents the ground truth of one recorded screenshot
+type BenchFrameLabel struct {
+	File    string  `json:"file"`
+	MapName string  `json:"map_name"`
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	Rot     int     `json:"rot"`
+	// TimeMs is the capture time of the frame in ms, relative to any origin.
+	// Frames without it are spaced by BenchOptions.FrameIntervalMs.
+	TimeMs int64 `json:"time_ms,omitempty"`
+}
+
+// BenchOptions configures an inference benchmark run
+type BenchOptions struct {
+	// FramesDir is the directory containing screenshots and the labels file.
+	FramesDir string
+	// LabelsFile is the labels file name inside FramesDir.
+	LabelsFile string
+	// ResourceDir overrides the resource base used to find map images.
+	ResourceDir string
+	// MapNameRegex is passed to MapTrackerInfer.
+	MapNameRegex string
+	// Precisions is the list of precision values to benchmark.
+	Precisions []float64
+	// Threshold is passed to MapTrackerInfer.
+	Threshold float64
+	// LocTolerance is the maximum location error (px) for a frame to be counted as correct.
+	LocTolerance float64
+	// RotTolerance is the maximum rotation error (degrees) for a frame to be counted as correct.
+	RotTolerance int
+	// FrameIntervalMs is the frame clock step for frames without time_ms.
+	FrameIntervalMs int64
+}
+
+// BenchDistribution summarizes a list of samples
+type BenchDistribution struct {
+	Mean float64 `json:"mean"`
+	Min  float64 `json:"min"`
+	P10  float64 `json:"p10"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// BenchModeStats represents hit statistics of one inference mode
+type BenchModeStats struct {
+	Count   int     `json:"count"`
+	Correct int     `json:"correct"`
+	HitRate float64 `json:"hitRate"`
+}
+
+// BenchPrecisionReport represents the benchmark result at one precision
+type BenchPrecisionReport struct {
+	Precision   float64           `json:"precision"`
+	Frames      int               `json:"frames"`
+	Recognized  int               `json:"recognized"`
+	MapCorrect  int               `json:"mapCorrect"`
+	Correct     int               `json:"correct"`
+	Accuracy    float64           `json:"accuracy"`
+	LocError    BenchDistribution `json:"locError"`
+	RotError    BenchDistribution `json:"rotError"`
+	InferTimeMs BenchDistribution `json:"inferTimeMs"`
+	// LocConf and WrongLocConf are the location confidences of correct and incorrect frames
+	// (virtual hits excluded), used to choose the inference threshold.
+	LocConf      BenchDistribution          `json:"locConf"`
+	WrongLocConf BenchDistribution          `json:"wrongLocConf"`
+	Modes        map[string]*BenchModeStats `json:"modes"`
+}
+
+// BenchReport represents the whole benchmark result
+type BenchReport struct {
+	FramesDir string                  `json:"framesDir"`
+	Results   []*BenchPrecisionReport `json:"results"`
+}
+
+type benchFrame struct {
+	label BenchFrameLabel
+	img   image.Image
+	// timeMs is the frame clock used by the time-series optimizations instead of the wall clock
+	timeMs int64
+}
+
+// RunInferBenchmark runs MapTrackerInfer over labelled screenshots at several precisions.
+// Frames are fed in label order with their own frame clock, so time-series optimizations
+// (fast search, virtual hits) behave as in the recording however fast the replay runs.
+func RunInferBenchmark(opts BenchOptions) (*BenchReport, error) {
+	if opts.ResourceDir != "" {
+		abs, err := filepath.Abs(opts.ResourceDir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resource dir: %w", err)
+		}
+		resourcePath.Store(abs)
+	}
+
+	frames, err := loadBenchFrames(opts.FramesDir, opts.LabelsFile, opts.FrameIntervalMs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load resources up front so that loading time is not counted in the first frame
+	infer := &MapTrackerInfer{}
+	infer.initMaps()
+	infer.initPointer()
+	if infer.mapsErr != nil {
+		return nil, fmt.Errorf("failed to load maps: %w", infer.mapsErr)
+	}
+	if infer.pointerErr != nil {
+		return nil, fmt.Errorf("failed to load pointer: %w", infer.pointerErr)
+	}
+
+	report := &BenchReport{FramesDir: opts.FramesDir}
+
+	for _, precision := range opts.Precisions {
+		param := MapTrackerInferParam{
+			MapNameRegex: opts.MapNameRegex,
+			Precision:    precision,
+			Threshold:    opts.Threshold,
+		}
+		paramBytes, err := json.Marshal(param)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal inference param: %w", err)
+		}
+		// Fill in the defaults exactly as the recognition does
+		inferParam, err := infer.parseParam(string(paramBytes))
+		if err != nil {
+			return nil, err
+		}
+		mapNameRegex, err := regexp.Compile(inferParam.MapNameRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid map_name_regex: %w", err)
+		}
+
+		resetInferState()
+		res := &BenchPrecisionReport{
+			Precision: precision,
+			Frames:    len(frames),
+			Modes:     make(map[string]*BenchModeStats),
+		}
+		var locErrors, rotErrors, times, confs, wrongConfs []float64
+
+		for _, frame := range frames {
+			t0 := time.Now()
+			// Shift the frame clock so that the reset state is already stale at the first frame, as it is with the wall clock
+			result := infer.infer(frame.img, mapNameRegex, inferParam, CONVINCED_VALID_TIME_MS+frame.timeMs)
+			times = append(times, float64(time.Since(t0).Microseconds())/1000.0)
+
+			if result == nil {
+				continue
+			}
+			res.Recognized++
+
+			mode := res.Modes[result.InferMode]
+			if mode == nil {
+				mode = &BenchModeStats{}
+				res.Modes[result.InferMode] = mode
+			}
+			mode.Count++
+
+			correct := false
+			if result.MapName == frame.label.MapName {
+				res.MapCorrect++
+
+				locErr := math.Hypot(result.X-frame.label.X, result.Y-frame.label.Y)
+				rotErr := math.Abs(float64(calcDeltaRotation(result.Rot, frame.label.Rot)))
+				locErrors = append(locErrors, locErr)
+				rotErrors = append(rotErrors, rotErr)
+
+				if locErr <= opts.LocTolerance && rotErr <= float64(opts.RotTolerance) {
+					res.Correct++
+					mode.Correct++
+					correct = true
+				}
+			}
+
+			if result.InferMode != string(VIRTUAL_HIT) {
+				if correct {
+					confs = append(confs, result.LocConf)
+				} else {
+					wrongConfs = append(wrongConfs, result.LocConf)
+				}
+			}
+		}
+
+		if res.Frames > 0 {
+			res.Accuracy = float64(res.Correct) / float64(res.Frames)
+		}
+		for _, mode := range res.Modes {
+			mode.HitRate = float64(mode.Correct) / float64(mode.Count)
+		}
+		res.LocError = summarizeSamples(locErrors)
+		res.RotError = summarizeSamples(rotErrors)
+		res.InferTimeMs = summarizeSamples(times)
+		res.LocConf = summarizeSamples(confs)
+		res.WrongLocConf = summarizeSamples(wrongConfs)
+		report.Results = append(report.Results, res)
+	}
+
+	resetInferState()
+	return report, nil
+}
+
+// WriteText writes a human-readable benchmark summary
+func (r *BenchReport) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "MapTracker inference benchmark: %s\n", r.FramesDir)
+	for _, res := range r.Results {
+		fmt.Fprintf(w, "\n== precision %.2f ==\n", res.Precision)
+		fmt.Fprintf(w, "frames: %d, recognized: %d, map correct: %d, correct: %d (accuracy %.1f%%)\n",
+			res.Frames, res.Recognized, res.MapCorrect, res.Correct, res.Accuracy*100)
+		writeDist := func(name string, d BenchDistribution) {
+			fmt.Fprintf(w, "%-14s mean %8.2f  min %8.2f  p10 %8.2f  p50 %8.2f  p90 %8.2f  p99 %8.2f  max %8.2f\n", name, d.Mean, d.Min, d.P10, d.P50, d.P90, d.P99, d.Max)
+		}
+		writeDist("loc error px", res.LocError)
+		writeDist("rot error deg", res.RotError)
+		writeDist("infer time ms", res.InferTimeMs)
+		writeDist("loc conf", res.LocConf)
+		writeDist("wrong loc conf", res.WrongLocConf)
+
+		modes := make([]string, 0, len(res.Modes))
+		for mode := range res.Modes {
+			modes = append(modes, mode)
+		}
+		sort.Strings(modes)
+		for _, mode := range modes {
+			m := res.Modes[mode]
+			fmt.Fprintf(w, "mode %-14s count %5d  correct %5d  hit rate %.1f%%\n", mode, m.Count, m.Correct, m.HitRate*100)
+		}
+	}
+}
+
+// MinAccuracy returns the lowest accuracy among all benchmarked precisions
+func (r *BenchReport) MinAccuracy() float64 {
+	minAcc := 1.0
+	for _, res := range r.Results {
+		minAcc = math.Min(minAcc, res.Accuracy)
+	}
+	return minAcc
+}
+
+func loadBenchFrames(dir, labelsFile string, intervalMs int64) ([]benchFrame, error) {
+	labelsPath := filepath.Join(dir, labelsFile)
+	data, err := os.ReadFile(labelsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read labels file: %w", err)
+	}
+	var labels []BenchFrameLabel
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal labels file: %w", err)
+	}
+	if len(labels) == 0 {
+		return nil, fmt.Errorf("labels file %s is empty", labelsPath)
+	}
+
+	frames := make([]benchFrame, 0, len(labels))
+	for i, label := range labels {
+		if label.File == "" || label.MapName == "" {
+			return nil, fmt.Errorf("file and map_name must be provided for label at index %d", i)
+		}
+		timeMs := int64(i) * intervalMs
+		if label.TimeMs != 0 {
+			timeMs = label.TimeMs
+		}
+		if i > 0 && timeMs < frames[i-1].timeMs {
+			return nil, fmt.Errorf("time_ms goes backwards at label index %d", i)
+		}
+		imgPath := filepath.Join(dir, filepath.FromSlash(label.File))
+		file, err := os.Open(imgPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open frame %s: %w", label.File, err)
+		}
+		img, _, err := image.Decode(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode frame %s: %w", label.File, err)
+		}
+
+		// Screenshots are expected at work resolution; rescale others by width
+		if w := img.Bounds().Dx(); w != WORK_W && w > 0 {
+			img = minicv.ImageScale(minicv.ImageConvertRGBA(img), float64(WORK_W)/float64(w))
+		}
+		frames = append(frames, benchFrame{label: label, img: img, timeMs: timeMs})
+	}
+	return frames, nil
+}
+
+func resetInferState() {
+	globalInferState.mu.Lock()
+	defer globalInferState.mu.Unlock()
+	globalInferState.convinced = emptyLocationRawResult
+	globalInferState.convincedLastHitTime = 0
+	globalInferState.convincedMoveDirection = 0
+	globalInferState.convincedMoveSpeed = 0
+	globalInferState.pending = emptyLocationRawResult
+	globalInferState.pendingFirstHitTime = 0
+	globalInferState.pendingHitCount = 0
+}
+
+func summarizeSamples(samples []float64) BenchDistribution {
+	if len(samples) == 0 {
+		return BenchDistribution{}
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	percentile := func(p float64) float64 {
+		idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+		return sorted[max(0, min(len(sorted)-1, idx))]
+	}
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	return BenchDistribution{
+		Mean: sum / float64(len(sorted)),
+		Min:  sorted[0],
+		P10:  percentile(0.10),
+		P50:  percentile(0.50),
+		P90:  percentile(0.90),
+		P99:  percentile(0.99),
+		Max:  sorted[len(sorted)-1],
+	}
+}
+
+// ParseBenchPrecisions parses a comma-separated precision list, e.g. "0.3,0.5,0.7"
+func ParseBenchPrecisions(s string) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	precisions := make([]float64, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		var p float64
+		if _, err := fmt.Sscanf(part, "%g", &p); err != nil {
+			return nil, fmt.Errorf("invalid precision %q: %w", part, err)
+		}
+		if p <= 0 || p > 1 {
+			return nil, fmt.Errorf("precision %g must be in (0, 1]", p)
+		}
+		precisions = append(precisions, p)
+	}
+	if len(precisions) == 0 {
+		return nil, fmt.Errorf("no precision given")
+	}
+	return precisions, nil
+}
@@ -55,7 +55,7 @@ func (r *MapTrackerBigMapInfer) Run(ctx *maa.Context, arg *maa.CustomRecognition
 		return nil, false
 	}
 
-	r.initMaps(ctx)
+	r.initMaps()
 	if r.mapsErr != nil {
 		log.Error().Err(r.mapsErr).Msg("Failed to initialize maps for MapTrackerBigMapInfer")
 		return nil, false
@@ -74,7 +74,7 @@ func (r *MapTrackerBigMapInfer) Run(ctx *maa.Context, arg *maa.CustomRecognition
 		return nil, false
 	}
 
-	fastTpl := minicv.ImageScale(sampleTemplate, WIRE_MATCH_PRECISION)
+	fastTpl := scaleNeedle(sampleTemplate, WIRE_MATCH_PRECISION)
 	fastTplStats := minicv.GetImageStats(fastTpl)
 	if fastTplStats.Std < 1e-6 {
 		log.Warn().Msg("Big-map template standard deviation is too small")
@@ -106,13 +106,13 @@ func (r *MapTrackerBigMapInfer) Run(ctx *maa.Context, arg *maa.CustomRecognition
 
 	if triedMaps == 1 {
 		single := candidateMaps[0]
-		_, _, score, tplScale := minicv.MatchTemplateAnyScaleInArea(
-			single.Img,
-			single.Integral,
+		_, _, score, tplScale := minicv.MatchTemplateAnyScalePyramid(
+			single.Pyramid,
 			fastTpl,
 			coarseTplScaleMin,
 			coarseTplScaleMax,
 			coarseMatchingSteps,
+			minicv.PyramidMatchOptions{},
 		)
 		coarseBestScore = score
 		coarseBestTplScale = tplScale
@@ -126,13 +126,13 @@ func (r *MapTrackerBigMapInfer) Run(ctx *maa.Context, arg *maa.CustomRecognition
 			wg.Add(1)
 			go func(m MapCache) {
 				defer wg.Done()
-				_, _, score, tplScale := minicv.MatchTemplateAnyScaleInArea(
-					m.Img,
-					m.Integral,
+				_, _, score, tplScale := minicv.MatchTemplateAnyScalePyramid(
+					m.Pyramid,
 					fastTpl,
 					coarseTplScaleMin,
 					coarseTplScaleMax,
 					coarseMatchingSteps,
+					minicv.PyramidMatchOptions{},
 				)
 				resChan <- coarseResult{score: score, tplScale: tplScale, m: m}
 			}(mapData)
@@ -168,13 +168,13 @@ func (r *MapTrackerBigMapInfer) Run(ctx *maa.Context, arg *maa.CustomRecognition
 	fineMinScale := max(coarseTplScaleMin, coarseBestTplScale-fineMatchingScaleOffset)
 	fineMaxScale := min(coarseTplScaleMax, coarseBestTplScale+fineMatchingScaleOffset)
 
-	matchX, matchY, fineScore, fineTplScale := minicv.MatchTemplateAnyScaleInArea(
-		coarseBestMap.Img,
-		coarseBestMap.Integral,
+	matchX, matchY, fineScore, fineTplScale := minicv.MatchTemplateAnyScalePyramid(
+		coarseBestMap.Pyramid,
 		fastTpl,
 		fineMinScale,
 		fineMaxScale,
 		fineMatchingSteps,
+		minicv.PyramidMatchOptions{},
 	)
 
 	if fineScore < param.Threshold {
@@ -251,24 +251,26 @@ func (r *MapTrackerBigMapInfer) parseParam(paramStr string) (*MapTrackerBigMapIn
 }
 
 // initMaps initializes map cache for big-map inference only.
-func (r *MapTrackerBigMapInfer) initMaps(ctx *maa.Context) {
+func (r *MapTrackerBigMapInfer) initMaps() {
 	r.mapsOnce.Do(func() {
 		loader := &MapTrackerInfer{}
-		maps, err := loader.loadMaps(ctx)
+		maps, err := loader.loadMaps()
 		if err != nil {
 			r.mapsErr = err
 			return
 		}
 
+		// Only the level at WIRE_MATCH_PRECISION is kept, as the base of the pyramid used for matching
 		fastMaps := make([]MapCache, 0, len(maps))
 		for _, m := range maps {
-			fastImg := minicv.ImageScale(m.Img, WIRE_MATCH_PRECISION)
+			fastLevel := m.Pyramid.AtScale(WIRE_MATCH_PRECISION)
 			fastMaps = append(fastMaps, MapCache{
 				Name:     m.Name,
-				Img:      fastImg,
-				Integral: minicv.GetIntegralArray(fastImg),
+				Img:      fastLevel.Img,
+				Integral: fastLevel.Integral,
 				OffsetX:  m.OffsetX,
 				OffsetY:  m.OffsetY,
+				Pyramid:  minicv.NewPyramidFromLevel(fastLevel, MAP_PYRAMID_LEVELS, LOC_RADIUS),
 			})
 		}
 
@@ -4,9 +4,13 @@ package maptracker
 import (
 	"encoding/json"
 	"fmt"
+	"image"
 	"math"
 	"os"
 	"regexp"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 
 	maa "github.com/MaaXYZ/maa-framework-go/v4"
@@ -21,19 +25,34 @@ type MapTrackerBigMapPick struct {
 }
 
 type mapExternalDataItem struct {
-	SceneManagerNode string `json:"scene_manager_node,omitempty"`
+	SceneManagerNode string                `json:"scene_manager_node,omitempty"`
+	POIs             map[string][2]float64 `json:"pois,omitempty"`
+}
+
+// BigMapPickCandidate represents a possible location of the pick target.
+type BigMapPickCandidate struct {
+	// MapName is the map name where the target is located.
+	MapName string `json:"map_name"`
+	// Target is the target coordinate in the specified map file's original coordinate space.
+	Target [2]float64 `json:"target"`
 }
 
 // MapTrackerBigMapPickParam represents the custom_action_param for MapTrackerBigMapPick.
 type MapTrackerBigMapPickParam struct {
-	// MapName is the target map name.
-	MapName string `json:"map_name"`
+	// MapName is the target map name. Together with Target, it forms the first candidate.
+	MapName string `json:"map_name,omitempty"`
 	// Target is the target coordinate in the specified map file's original coordinate space.
 	Target [2]float64 `json:"target"`
+	// Candidates is a list of additional candidate locations of the target, tried in order.
+	Candidates []BigMapPickCandidate `json:"candidates,omitempty"`
+	// POI is the name of a point of interest defined in map external data, expanded into candidates.
+	POI string `json:"poi,omitempty"`
 	// OnFind controls behavior when target enters viewport. Valid values: "Click", "Teleport", "DoNothing".
 	OnFind string `json:"on_find,omitempty"`
 	// DisableAutoOpenMap controls whether to skip auto-running scene_manager_node before picking.
 	DisableAutoOpenMap bool `json:"disable_auto_open_map,omitempty"`
+	// DisableAutoZoom controls whether to skip zooming the big map when panning makes no progress.
+	DisableAutoZoom bool `json:"disable_auto_zoom,omitempty"`
 }
 
 var _ maa.CustomActionRunner = &MapTrackerBigMapPick{}
@@ -46,55 +65,87 @@ func (a *MapTrackerBigMapPick) Run(ctx *maa.Context, arg *maa.CustomActionArg) b
 		return false
 	}
 
-	sceneManagerNode, hasSceneMapping, err := a.getSceneManagerNode(param.MapName)
+	candidates, err := a.resolveCandidates(param)
 	if err != nil {
-		log.Error().Err(err).Str("map", param.MapName).Msg("Failed to resolve scene manager mapping")
+		log.Error().Err(err).Msg("Failed to resolve big-map pick candidates")
 		return false
 	}
-	if hasSceneMapping && !param.DisableAutoOpenMap {
-		if _, err := ctx.RunTask(sceneManagerNode); err != nil {
-			log.Error().Err(err).Str("map", param.MapName).Str("sceneManagerNode", sceneManagerNode).Msg("Failed to run scene manager node")
-			return false
-		}
-		log.Info().Str("map", param.MapName).Str("sceneManagerNode", sceneManagerNode).Msg("Scene manager node completed before big-map pick")
-	} else if hasSceneMapping {
-		log.Info().Str("map", param.MapName).Str("sceneManagerNode", sceneManagerNode).Msg("Auto-open map is disabled; skipping scene manager node")
+
+	ctrl := ctx.GetTasker().GetController()
+	aw := NewActionWrapper(ctx, ctrl)
+
+	candidate, err := a.enterCandidateMap(ctx, ctrl, candidates, param.DisableAutoOpenMap)
+	if err != nil {
+		log.Error().Err(err).Int("candidates", len(candidates)).Msg("Failed to show any candidate map on big map")
+		return false
 	}
 
 	if param.OnFind == "Teleport" {
 		if _, err := ctx.RunTask("__ScenePrivateMapFilterClear"); err != nil {
-			log.Error().Err(err).Str("map", param.MapName).Msg("Failed to clear map filters before pick")
+			log.Error().Err(err).Str("map", candidate.MapName).Msg("Failed to clear map filters before pick")
 			return false
 		}
 	}
 
-	ctrl := ctx.GetTasker().GetController()
-	aw := NewActionWrapper(ctx, ctrl)
+	// Several candidates may lie on the shown map: try them nearest to the current viewport first
+	targets := make([]BigMapPickCandidate, 0, len(candidates))
+	for _, c := range candidates {
+		if c.MapName == candidate.MapName {
+			targets = append(targets, c)
+		}
+	}
+	if len(targets) > 1 {
+		if inferRes, err := doBigMapInferForMaps(ctx, ctrl, []string{candidate.MapName}); err == nil {
+			centerX := (inferRes.ViewPort.Left + inferRes.ViewPort.Right) * 0.5
+			centerY := (inferRes.ViewPort.Top + inferRes.ViewPort.Bottom) * 0.5
+			dist := func(c BigMapPickCandidate) float64 {
+				x, y := inferRes.ViewPort.GetScreenCoordOf(c.Target[0], c.Target[1])
+				return math.Hypot(x-centerX, y-centerY)
+			}
+			sort.SliceStable(targets, func(i, j int) bool { return dist(targets[i]) < dist(targets[j]) })
+		}
+	}
+
+	for i := range targets {
+		if doPickTarget(ctx, ctrl, aw, &targets[i], param) {
+			return true
+		}
+		if ctx.GetTasker().Stopping() {
+			break
+		}
+	}
+	return false
+}
+
+// doPickTarget pans (and zooms if needed) the big map until the target is in view, then runs on_find.
+func doPickTarget(ctx *maa.Context, ctrl *maa.Controller, aw *ActionWrapper, candidate *BigMapPickCandidate, param *MapTrackerBigMapPickParam) bool {
+	var prevViewport *BigMapViewport
+	zoomIn := false
 
 	for attempt := 1; attempt <= BIG_MAP_PICK_RETRY; attempt++ {
-		inferRes, err := doBigMapInferForMap(ctx, ctrl, param.MapName)
+		inferRes, err := doBigMapInferForMaps(ctx, ctrl, []string{candidate.MapName})
 		if err != nil {
-			log.Error().Err(err).Str("map", param.MapName).Int("attempt", attempt).Msg("Currently not in that map")
+			log.Error().Err(err).Str("map", candidate.MapName).Int("attempt", attempt).Msg("Currently not in that map")
 			return false
 		}
 
-		targetInViewX, targetInViewY := inferRes.ViewPort.GetScreenCoordOf(param.Target[0], param.Target[1])
+		targetInViewX, targetInViewY := inferRes.ViewPort.GetScreenCoordOf(candidate.Target[0], candidate.Target[1])
 		if inferRes.ViewPort.IsViewCoordInView(targetInViewX, targetInViewY) {
 			if param.OnFind == "Click" {
 				aw.ClickSync(0, int(math.Round(targetInViewX)), int(math.Round(targetInViewY)), 50)
 			} else if param.OnFind == "Teleport" {
 				if err := runBigMapTeleportNode(ctx, aw, targetInViewX, targetInViewY); err != nil {
-					log.Error().Err(err).Str("map", param.MapName).Msg("Failed to run teleport sequence on find")
+					log.Error().Err(err).Str("map", candidate.MapName).Msg("Failed to run teleport sequence on find")
 					return false
 				}
 			}
 
 			log.Info().
-				Str("map", param.MapName).
+				Str("map", candidate.MapName).
 				Int("attempt", attempt).
 				Str("onFind", param.OnFind).
-				Float64("targetX", param.Target[0]).
-				Float64("targetY", param.Target[1]).
+				Float64("targetX", candidate.Target[0]).
+				Float64("targetY", candidate.Target[1]).
 				Float64("targetInViewX", targetInViewX).
 				Float64("targetInViewY", targetInViewY).
 				Msg("Big-map target is in valid viewport")
@@ -109,22 +160,45 @@ func (a *MapTrackerBigMapPick) Run(ctx *maa.Context, arg *maa.CustomActionArg) b
 		centerY := (inferRes.ViewPort.Top + inferRes.ViewPort.Bottom) * 0.5
 		deltaInViewX := targetInViewX - centerX
 		deltaInViewY := targetInViewY - centerY
-		log.Warn().
-			Str("map", param.MapName).
-			Int("attempt", attempt).
-			Float64("targetInViewX", targetInViewX).
-			Float64("targetInViewY", targetInViewY).
-			Msg("Panning big-map toward target")
-
-		if !doDragViewport(aw, &inferRes.ViewPort, deltaInViewX, deltaInViewY) {
-			continue
+
+		// The last pan did not move the viewport (or no drag is possible), so the target is probably
+		// hidden by map bounds: zoom out to widen the visible area. At the minimum scale the target may
+		// still be hidden under the screen padding, so zoom in from there to let panning move it into view.
+		stalled := prevViewport != nil && !hasViewportMoved(prevViewport, &inferRes.ViewPort)
+		if !stalled {
+			log.Warn().
+				Str("map", candidate.MapName).
+				Int("attempt", attempt).
+				Float64("targetInViewX", targetInViewX).
+				Float64("targetInViewY", targetInViewY).
+				Msg("Panning big-map toward target")
+			prevViewport = &inferRes.ViewPort
+			if doDragViewport(aw, &inferRes.ViewPort, deltaInViewX, deltaInViewY) {
+				continue
+			}
 		}
+		if param.DisableAutoZoom {
+			break
+		}
+		if !zoomIn && !doZoomViewport(aw, &inferRes.ViewPort, false) {
+			zoomIn = true
+		}
+		if zoomIn && !doZoomViewport(aw, &inferRes.ViewPort, true) {
+			log.Error().
+				Str("map", candidate.MapName).
+				Float64("scale", inferRes.ViewPort.Scale).
+				Float64("targetX", candidate.Target[0]).
+				Float64("targetY", candidate.Target[1]).
+				Msg("Big-map scale cannot be adjusted any further and the target is still out of view")
+			return false
+		}
+		prevViewport = nil
 	}
 
 	log.Error().
-		Str("map", param.MapName).
-		Float64("targetX", param.Target[0]).
-		Float64("targetY", param.Target[1]).
+		Str("map", candidate.MapName).
+		Float64("targetX", candidate.Target[0]).
+		Float64("targetY", candidate.Target[1]).
 		Msg("Failed to pan map to target")
 	return false
 }
@@ -139,8 +213,8 @@ func (a *MapTrackerBigMapPick) parseParam(paramStr string) (*MapTrackerBigMapPic
 		return nil, fmt.Errorf("failed to unmarshal parameters: %w", err)
 	}
 
-	if param.MapName == "" {
-		return nil, fmt.Errorf("map_name must be provided")
+	if param.MapName == "" && len(param.Candidates) == 0 && param.POI == "" {
+		return nil, fmt.Errorf("one of map_name, candidates or poi must be provided")
 	}
 	if param.OnFind == "" {
 		param.OnFind = "Click"
@@ -148,14 +222,123 @@ func (a *MapTrackerBigMapPick) parseParam(paramStr string) (*MapTrackerBigMapPic
 	if param.OnFind != "Click" && param.OnFind != "Teleport" && param.OnFind != "DoNothing" {
 		return nil, fmt.Errorf("on_find must be \"Click\", \"Teleport\", or \"DoNothing\"")
 	}
-	if math.IsNaN(param.Target[0]) || math.IsInf(param.Target[0], 0) || math.IsNaN(param.Target[1]) || math.IsInf(param.Target[1], 0) {
+	if !isFinitePoint(param.Target) {
 		return nil, fmt.Errorf("target must contain finite numbers")
 	}
+	for i, c := range param.Candidates {
+		if c.MapName == "" {
+			return nil, fmt.Errorf("map_name must be provided for candidate at index %d", i)
+		}
+		if !isFinitePoint(c.Target) {
+			return nil, fmt.Errorf("target must contain finite numbers for candidate at index %d", i)
+		}
+	}
 
 	return &param, nil
 }
 
-func (a *MapTrackerBigMapPick) getSceneManagerNode(mapName string) (string, bool, error) {
+// resolveCandidates flattens map_name, candidates and poi into an ordered candidate list.
+// Only exact duplicates are dropped, so every distinct target on the same map is kept.
+func (a *MapTrackerBigMapPick) resolveCandidates(param *MapTrackerBigMapPickParam) ([]BigMapPickCandidate, error) {
+	candidates := make([]BigMapPickCandidate, 0, len(param.Candidates)+1)
+	add := func(c BigMapPickCandidate) {
+		if !slices.Contains(candidates, c) {
+			candidates = append(candidates, c)
+		}
+	}
+
+	if param.MapName != "" {
+		add(BigMapPickCandidate{MapName: param.MapName, Target: param.Target})
+	}
+	for _, c := range param.Candidates {
+		add(c)
+	}
+
+	if param.POI != "" {
+		externalData, err := a.loadExternalData()
+		if err != nil {
+			return nil, err
+		}
+		mapNames := make([]string, 0, len(externalData))
+		for mapName := range externalData {
+			mapNames = append(mapNames, mapName)
+		}
+		sort.Strings(mapNames)
+		found := false
+		for _, mapName := range mapNames {
+			if target, ok := externalData[mapName].POIs[param.POI]; ok {
+				add(BigMapPickCandidate{MapName: mapName, Target: target})
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("poi %q is not defined in map external data", param.POI)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no candidate maps resolved")
+	}
+	return candidates, nil
+}
+
+// enterCandidateMap makes the big map show one of the candidate maps and returns that candidate.
+// The currently displayed map is checked first; other regions are then entered via their scene manager nodes.
+func (a *MapTrackerBigMapPick) enterCandidateMap(ctx *maa.Context, ctrl *maa.Controller, candidates []BigMapPickCandidate, disableAutoOpenMap bool) (*BigMapPickCandidate, error) {
+	mapNames := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		mapNames = append(mapNames, c.MapName)
+	}
+	findCandidate := func(mapName string) *BigMapPickCandidate {
+		for i := range candidates {
+			if candidates[i].MapName == mapName {
+				return &candidates[i]
+			}
+		}
+		return nil
+	}
+
+	if inferRes, err := doBigMapInferForMaps(ctx, ctrl, mapNames); err == nil {
+		log.Info().Str("map", inferRes.MapName).Msg("Big map already shows a candidate map")
+		return findCandidate(inferRes.MapName), nil
+	} else if disableAutoOpenMap {
+		return nil, fmt.Errorf("auto-open map is disabled and current map is not a candidate: %w", err)
+	} else {
+		log.Debug().Err(err).Msg("Big map does not show any candidate map yet")
+	}
+
+	for _, c := range candidates {
+		sceneManagerNode, hasSceneMapping, err := a.getSceneManagerNode(c.MapName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve scene manager mapping: %w", err)
+		}
+		if !hasSceneMapping {
+			log.Debug().Str("map", c.MapName).Msg("No scene manager node for candidate map; skipping")
+			continue
+		}
+		if ctx.GetTasker().Stopping() {
+			return nil, fmt.Errorf("task is stopping")
+		}
+
+		if _, err := ctx.RunTask(sceneManagerNode); err != nil {
+			log.Warn().Err(err).Str("map", c.MapName).Str("sceneManagerNode", sceneManagerNode).Msg("Failed to run scene manager node")
+			continue
+		}
+		log.Info().Str("map", c.MapName).Str("sceneManagerNode", sceneManagerNode).Msg("Scene manager node completed before big-map pick")
+
+		inferRes, err := doBigMapInferForMaps(ctx, ctrl, mapNames)
+		if err != nil {
+			log.Warn().Err(err).Str("map", c.MapName).Msg("Candidate map not recognized after scene manager node")
+			continue
+		}
+		return findCandidate(inferRes.MapName), nil
+	}
+
+	return nil, fmt.Errorf("none of the candidate maps could be shown")
+}
+
+// loadExternalData loads map external data once and caches it.
+func (a *MapTrackerBigMapPick) loadExternalData() (map[string]mapExternalDataItem, error) {
 	a.externalOnce.Do(func() {
 		a.externalData = map[string]mapExternalDataItem{}
 
@@ -176,11 +359,16 @@ func (a *MapTrackerBigMapPick) getSceneManagerNode(mapName string) (string, bool
 		}
 	})
 
-	if a.externalErr != nil {
-		return "", false, a.externalErr
+	return a.externalData, a.externalErr
+}
+
+func (a *MapTrackerBigMapPick) getSceneManagerNode(mapName string) (string, bool, error) {
+	externalData, err := a.loadExternalData()
+	if err != nil {
+		return "", false, err
 	}
 
-	item, ok := a.externalData[mapName]
+	item, ok := externalData[mapName]
 	if !ok || item.SceneManagerNode == "" {
 		return "", false, nil
 	}
@@ -209,7 +397,11 @@ func runBigMapTeleportNode(ctx *maa.Context, aw *ActionWrapper, targetInViewX, t
 	return nil
 }
 
-func doBigMapInferForMap(ctx *maa.Context, ctrl *maa.Controller, mapName string) (*MapTrackerBigMapInferResult, error) {
+func doBigMapInferForMaps(ctx *maa.Context, ctrl *maa.Controller, mapNames []string) (*MapTrackerBigMapInferResult, error) {
+	if len(mapNames) == 0 {
+		return nil, fmt.Errorf("no map names to infer")
+	}
+
 	ctrl.PostScreencap().Wait()
 	img, err := ctrl.CacheImage()
 	if err != nil {
@@ -219,8 +411,13 @@ func doBigMapInferForMap(ctx *maa.Context, ctrl *maa.Controller, mapName string)
 		return nil, fmt.Errorf("cached image is nil")
 	}
 
+	quotedNames := make([]string, 0, len(mapNames))
+	for _, name := range mapNames {
+		quotedNames = append(quotedNames, regexp.QuoteMeta(name))
+	}
+
 	inferConfig := map[string]any{
-		"map_name_regex": "^" + regexp.QuoteMeta(mapName) + "$",
+		"map_name_regex": "^(" + strings.Join(quotedNames, "|") + ")$",
 		"threshold":      DEFAULT_BIG_MAP_INFERENCE_PARAM.Threshold,
 	}
 	inferConfigBytes, err := json.Marshal(inferConfig)
@@ -252,8 +449,8 @@ func doBigMapInferForMap(ctx *maa.Context, ctrl *maa.Controller, mapName string)
 	if err := json.Unmarshal([]byte(res.Detail), &result); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal big-map inference result: %w", err)
 	}
-	if result.MapName != mapName {
-		return nil, fmt.Errorf("inference map mismatch: expect %s, got %s", mapName, result.MapName)
+	if !slices.Contains(mapNames, result.MapName) {
+		return nil, fmt.Errorf("inference map mismatch: expect one of %v, got %s", mapNames, result.MapName)
 	}
 	if result.ViewPort.Scale <= 0 {
 		return nil, fmt.Errorf("invalid inferred scale: %f", result.ViewPort.Scale)
@@ -262,6 +459,37 @@ func doBigMapInferForMap(ctx *maa.Context, ctrl *maa.Controller, mapName string)
 	return &result, nil
 }
 
+// hasViewportMoved reports whether the viewport origin or scale changed noticeably in screen space.
+func hasViewportMoved(prev, cur *BigMapViewport) bool {
+	if math.Abs(prev.Scale-cur.Scale) > BIG_MAP_ZOOM_SCALE_EPSILON {
+		return true
+	}
+	dx := (cur.OriginMapX - prev.OriginMapX) * cur.Scale
+	dy := (cur.OriginMapY - prev.OriginMapY) * cur.Scale
+	return math.Hypot(dx, dy) >= BIG_MAP_PAN_MIN_MOVE
+}
+
+// doZoomViewport zooms the big map in or out by one step.
+// It returns false if the map is already at the maximum or minimum scale respectively.
+func doZoomViewport(aw *ActionWrapper, viewport *BigMapViewport, in bool) bool {
+	delta := -BIG_MAP_ZOOM_SCROLL_DELTA
+	if in {
+		if viewport.Scale >= GAME_MAP_SCALE_MAX-BIG_MAP_ZOOM_SCALE_EPSILON {
+			return false
+		}
+		delta = BIG_MAP_ZOOM_SCROLL_DELTA
+	} else if viewport.Scale <= GAME_MAP_SCALE_MIN+BIG_MAP_ZOOM_SCALE_EPSILON {
+		return false
+	}
+	centerX := int(math.Round((viewport.Left + viewport.Right) * 0.5))
+	centerY := int(math.Round((viewport.Top + viewport.Bottom) * 0.5))
+	log.Info().Float64("scale", viewport.Scale).Bool("zoomIn", in).Msg("Panning made no progress, zooming big-map")
+	aw.ScrollSync(centerX, centerY, 0, delta, 0)
+	viewRect := image.Rect(int(viewport.Left), int(viewport.Top), int(viewport.Right), int(viewport.Bottom))
+	aw.WaitStableSync(viewRect, BIG_MAP_SETTLE_TIMEOUT_MILLIS)
+	return true
+}
+
 func doDragViewport(aw *ActionWrapper, viewport *BigMapViewport, deltaInViewX, deltaInViewY float64) bool {
 	left := int(math.Round(viewport.Left))
 	top := int(math.Round(viewport.Top))
@@ -299,7 +527,8 @@ func doDragViewport(aw *ActionWrapper, viewport *BigMapViewport, deltaInViewX, d
 		return false
 	}
 
-	aw.SwipeSync(startX, startY, dragDx, dragDy, 100, 50)
+	aw.SwipeSync(startX, startY, dragDx, dragDy, 100, 0)
+	aw.WaitStableSync(image.Rect(left, top, right, bottom), BIG_MAP_SETTLE_TIMEOUT_MILLIS)
 	return true
 }
 
@@ -321,3 +550,7 @@ func pickDragStartCorner(left, top, right, bottom int, rawDragDx, rawDragDy floa
 
 	return startX, startY
 }
+
+func isFinitePoint(p [2]float64) bool {
+	return !math.IsNaN(p[0]) && !math.IsInf(p[0], 0) && !math.IsNaN(p[1]) && !math.IsInf(p[1], 0)
+}
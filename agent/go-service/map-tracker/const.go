@@ -12,6 +12,16 @@ const (
 	LOC_CENTER_X = 108
 	LOC_CENTER_Y = 111
 	LOC_RADIUS   = 40
+	// Mini-map match mask, relative to LOC_RADIUS.
+	// The player pointer in the center and the corners outside the circular mini-map are ignored.
+	LOC_MASK_INNER_RATIO = 0.3
+	LOC_MASK_OUTER_RATIO = 1.0
+	// Number of image pyramid levels (including the original) kept for each map
+	MAP_PYRAMID_LEVELS = 3
+	// Coarse-to-fine full search: the coarsest level searched exhaustively and the number of
+	// candidates refined on each map. Deeper levels shrink the masked mini-map too much to rank candidates.
+	LOC_PYRAMID_MAX_LEVEL = 1
+	LOC_PYRAMID_TOP_K     = 4
 )
 
 // Rotation inference configuration
@@ -35,8 +45,13 @@ const (
 
 // Big map pick configuration
 const (
-	BIG_MAP_PAN_FACTOR = 1.5
-	BIG_MAP_PICK_RETRY = 10
+	BIG_MAP_PAN_FACTOR         = 1.5
+	BIG_MAP_PICK_RETRY         = 10
+	BIG_MAP_PAN_MIN_MOVE       = 2.0
+	BIG_MAP_ZOOM_SCROLL_DELTA  = 120
+	BIG_MAP_ZOOM_SCALE_EPSILON = 0.05
+	// Maximum time to wait for the big map to settle after zooming or panning
+	BIG_MAP_SETTLE_TIMEOUT_MILLIS = 1000
 )
 
 // Time-series empirical optimization configuration
@@ -47,6 +62,16 @@ const (
 	CONVINCED_VALID_TIME_MS          = 2000
 )
 
+// Map tier (floor) switching configuration
+const (
+	TIER_SWITCH_SCORE_MARGIN = 0.08
+)
+
+// Geofence configuration
+const (
+	GEOFENCE_LOST_MISS_COUNT = 3
+)
+
 // Resource paths
 const (
 	MAP_BBOX_DATA_PATH     = "data/MapTracker/map_bbox_data.json"
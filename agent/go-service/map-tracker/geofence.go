@@ -0,0 +1,289 @@
+// Copyright (c) 2026 Harry Huang
+package maptracker
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/maafocus"
+	"github.com/MaaXYZ/maa-framework-go/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// GeofenceEventType represents the kind of a geofence transition
+type GeofenceEventType string
+
+const (
+	GEOFENCE_ENTER GeofenceEventType = "Enter"
+	GEOFENCE_LEAVE GeofenceEventType = "Leave"
+)
+
+// Geofence represents a named polygon on a map with its event sinks
+type Geofence struct {
+	// Name is the unique name of the geofence.
+	Name string `json:"name"`
+	// MapName is the map where the polygon is defined.
+	MapName string `json:"map_name"`
+	// Polygon is a list of [x, y] vertices in map coordinates (at least 3).
+	Polygon [][2]float64 `json:"polygon"`
+	// OverrideNode is the node whose next list is overridden when an event fires.
+	// Required if OnEnter or OnLeave is given.
+	OverrideNode string `json:"override_node,omitempty"`
+	// OnEnter is the next list applied to OverrideNode when the player enters the polygon.
+	OnEnter []string `json:"on_enter,omitempty"`
+	// OnLeave is the next list applied to OverrideNode when the player leaves the polygon.
+	OnLeave []string `json:"on_leave,omitempty"`
+	// Print controls whether to print events to the GUI.
+	Print bool `json:"print,omitempty"`
+}
+
+// GeofenceEvent represents one fired enter/leave transition
+type GeofenceEvent struct {
+	Fence   *Geofence
+	Type    GeofenceEventType
+	MapName string
+	X       float64
+	Y       float64
+}
+
+type geofenceEntry struct {
+	fence  Geofence
+	inside bool
+	known  bool
+}
+
+// GeofenceTracker keeps registered geofences and their last known inside/outside state
+type GeofenceTracker struct {
+	fences map[string]*geofenceEntry
+	// Last located position, reported by leave events caused by lost tracking
+	lastMapName string
+	lastX       float64
+	lastY       float64
+	// Number of consecutive inference misses since the last located position
+	missCount int
+	mu        sync.Mutex
+}
+
+var globalGeofenceTracker = GeofenceTracker{fences: map[string]*geofenceEntry{}}
+
+//go:embed messages/geofence_event.html
+var geofenceEventHTML string
+
+// Register adds or replaces a geofence; its state is reset to unknown
+func (t *GeofenceTracker) Register(fence Geofence) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fences[fence.Name] = &geofenceEntry{fence: fence}
+}
+
+// Unregister removes the given geofences, or all geofences if names is empty
+func (t *GeofenceTracker) Unregister(names []string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(names) == 0 {
+		count := len(t.fences)
+		t.fences = map[string]*geofenceEntry{}
+		return count
+	}
+	count := 0
+	for _, name := range names {
+		if _, ok := t.fences[name]; ok {
+			delete(t.fences, name)
+			count++
+		}
+	}
+	return count
+}
+
+// Update feeds a new location into the tracker and returns the transitions it caused.
+// A geofence whose map differs from the current map is treated as outside.
+func (t *GeofenceTracker) Update(mapName string, x, y float64) []GeofenceEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lastMapName, t.lastX, t.lastY = mapName, x, y
+	t.missCount = 0
+	return t.update(func(fence *Geofence) bool {
+		return fence.MapName == mapName && isPointInPolygon(x, y, fence.Polygon)
+	})
+}
+
+// UpdateLost tells the tracker that the location could not be inferred.
+// After GEOFENCE_LOST_MISS_COUNT consecutive misses every geofence is treated as outside,
+// so leave events fire (reporting the last located position) even if the player is no longer tracked.
+func (t *GeofenceTracker) UpdateLost() []GeofenceEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.missCount++
+	if t.missCount < GEOFENCE_LOST_MISS_COUNT {
+		return nil
+	}
+	return t.update(func(*Geofence) bool { return false })
+}
+
+// update applies the inside predicate to every geofence; t.mu must be held
+func (t *GeofenceTracker) update(isInside func(fence *Geofence) bool) []GeofenceEvent {
+	names := make([]string, 0, len(t.fences))
+	for name := range t.fences {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	events := make([]GeofenceEvent, 0)
+	for _, name := range names {
+		entry := t.fences[name]
+		inside := isInside(&entry.fence)
+		if entry.known && entry.inside == inside {
+			continue
+		}
+		wasKnown := entry.known
+		entry.inside = inside
+		entry.known = true
+
+		// The first observation only fires when it is inside, there is nothing to leave yet
+		if !wasKnown && !inside {
+			continue
+		}
+		eventType := GEOFENCE_LEAVE
+		if inside {
+			eventType = GEOFENCE_ENTER
+		}
+		fence := entry.fence
+		events = append(events, GeofenceEvent{&fence, eventType, t.lastMapName, t.lastX, t.lastY})
+	}
+	return events
+}
+
+// dispatchGeofenceEvents sends the events to their sinks (override-next and/or GUI message)
+func dispatchGeofenceEvents(ctx *maa.Context, events []GeofenceEvent) {
+	for _, ev := range events {
+		log.Info().
+			Str("fence", ev.Fence.Name).
+			Str("event", string(ev.Type)).
+			Str("map", ev.MapName).
+			Float64("x", ev.X).
+			Float64("y", ev.Y).
+			Msg("Geofence event fired")
+
+		nextList := ev.Fence.OnEnter
+		if ev.Type == GEOFENCE_LEAVE {
+			nextList = ev.Fence.OnLeave
+		}
+		if len(nextList) > 0 && ev.Fence.OverrideNode != "" {
+			nextItems := make([]maa.NextItem, 0, len(nextList))
+			for _, name := range nextList {
+				nextItems = append(nextItems, maa.NextItem{Name: name})
+			}
+			if err := ctx.OverrideNext(ev.Fence.OverrideNode, nextItems); err != nil {
+				log.Warn().Err(err).Str("fence", ev.Fence.Name).Str("node", ev.Fence.OverrideNode).Msg("Failed to override next for geofence event")
+			}
+		}
+
+		if ev.Fence.Print {
+			maafocus.NodeActionStarting(ctx, "$global.geofence."+string(ev.Type))
+			maafocus.NodeActionStarting(ctx, fmt.Sprintf(geofenceEventHTML, ev.Fence.Name, ev.Type, ev.X, ev.Y, ev.MapName))
+		}
+	}
+}
+
+/* ******** Actions ******** */
+
+// MapTrackerGeofenceRegister registers named polygons to be tracked on every location update
+type MapTrackerGeofenceRegister struct{}
+
+// MapTrackerGeofenceRegisterParam represents the custom_action_param for MapTrackerGeofenceRegister
+type MapTrackerGeofenceRegisterParam struct {
+	// Fences is a list of geofences to register (required).
+	Fences []Geofence `json:"fences"`
+	// Replace controls whether to remove all previously registered geofences first.
+	Replace bool `json:"replace,omitempty"`
+}
+
+var _ maa.CustomActionRunner = &MapTrackerGeofenceRegister{}
+
+// Run implements maa.CustomActionRunner
+func (a *MapTrackerGeofenceRegister) Run(ctx *maa.Context, arg *maa.CustomActionArg) bool {
+	param, err := a.parseParam(arg.CustomActionParam)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse parameters for MapTrackerGeofenceRegister")
+		return false
+	}
+
+	if param.Replace {
+		globalGeofenceTracker.Unregister(nil)
+	}
+	for _, fence := range param.Fences {
+		globalGeofenceTracker.Register(fence)
+	}
+
+	log.Info().Int("count", len(param.Fences)).Bool("replace", param.Replace).Msg("Geofences registered")
+	return true
+}
+
+func (a *MapTrackerGeofenceRegister) parseParam(paramStr string) (*MapTrackerGeofenceRegisterParam, error) {
+	var param MapTrackerGeofenceRegisterParam
+	if err := json.Unmarshal([]byte(paramStr), &param); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal parameters: %w", err)
+	}
+
+	if len(param.Fences) == 0 {
+		return nil, fmt.Errorf("fences must be provided")
+	}
+	seen := make(map[string]struct{}, len(param.Fences))
+	for i, fence := range param.Fences {
+		if fence.Name == "" {
+			return nil, fmt.Errorf("name must be provided for fence at index %d", i)
+		}
+		if _, ok := seen[fence.Name]; ok {
+			return nil, fmt.Errorf("duplicate fence name %q at index %d", fence.Name, i)
+		}
+		seen[fence.Name] = struct{}{}
+		if fence.MapName == "" {
+			return nil, fmt.Errorf("map_name must be provided for fence at index %d", i)
+		}
+		if (len(fence.OnEnter) > 0 || len(fence.OnLeave) > 0) && fence.OverrideNode == "" {
+			return nil, fmt.Errorf("override_node must be provided with on_enter or on_leave for fence at index %d", i)
+		}
+		if len(fence.Polygon) < 3 {
+			return nil, fmt.Errorf("polygon must have at least 3 vertices for fence at index %d", i)
+		}
+		for j, p := range fence.Polygon {
+			if math.IsNaN(p[0]) || math.IsInf(p[0], 0) || math.IsNaN(p[1]) || math.IsInf(p[1], 0) {
+				return nil, fmt.Errorf("polygon[%d] contains invalid coordinate for fence at index %d", j, i)
+			}
+		}
+	}
+
+	return &param, nil
+}
+
+// MapTrackerGeofenceUnregister removes registered geofences
+type MapTrackerGeofenceUnregister struct{}
+
+// MapTrackerGeofenceUnregisterParam represents the custom_action_param for MapTrackerGeofenceUnregister
+type MapTrackerGeofenceUnregisterParam struct {
+	// Names is a list of geofence names to remove. All geofences are removed if empty.
+	Names []string `json:"names,omitempty"`
+}
+
+var _ maa.CustomActionRunner = &MapTrackerGeofenceUnregister{}
+
+// Run implements maa.CustomActionRunner
+func (a *MapTrackerGeofenceUnregister) Run(ctx *maa.Context, arg *maa.CustomActionArg) bool {
+	var param MapTrackerGeofenceUnregisterParam
+	if arg.CustomActionParam != "" {
+		if err := json.Unmarshal([]byte(arg.CustomActionParam), &param); err != nil {
+			log.Error().Err(err).Msg("Failed to parse parameters for MapTrackerGeofenceUnregister")
+			return false
+		}
+	}
+
+	count := globalGeofenceTracker.Unregister(param.Names)
+	log.Info().Int("count", count).Msg("Geofences unregistered")
+	return true
+}
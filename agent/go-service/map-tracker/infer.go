@@ -34,6 +34,8 @@ type MapTrackerInferResult struct {
 	RotTimeMs   int64   `json:"rotTimeMs"`   // Rotation inference time in ms
 	InferMode   string  `json:"inferMode"`   // Inference mode ("FullSearchHit", "FastSearchHit", "VirtualHit")
 	InferTimeMs int64   `json:"inferTimeMs"` // Total inference time in ms
+	BaseMapName string  `json:"baseMapName"` // Base map name (same as MapName for non-tier maps)
+	Tier        int     `json:"tier"`        // Tier ID of the floor (0 for base map)
 }
 
 // MapTrackerInferParam represents the custom_recognition_param for MapTrackerInfer
@@ -55,6 +57,9 @@ type MapCache struct {
 	Integral minicv.IntegralArray
 	OffsetX  int
 	OffsetY  int
+	// Pyramid of the map: for original maps it derives the scaled maps,
+	// for scaled maps it drives the coarse-to-fine full search
+	Pyramid *minicv.Pyramid
 }
 
 // MapTrackerInfer is the custom recognition component for map tracking
@@ -67,6 +72,9 @@ type MapTrackerInfer struct {
 	mapsErr     error
 	pointerErr  error
 
+	// Tier (floor) metadata of loaded maps
+	tiers *MapTierIndex
+
 	// Cache for scaled maps
 	scaledMu    sync.Mutex
 	scaledScale float64
@@ -137,11 +145,9 @@ func (i *MapTrackerInfer) Run(ctx *maa.Context, arg *maa.CustomRecognitionArg) (
 		return nil, false
 	}
 
-	rotStep := max(2, min(8, int(math.Round(8-param.Precision*6))))
-
 	// Initialize resources on first run
-	i.initMaps(ctx)
-	i.initPointer(ctx)
+	i.initMaps()
+	i.initPointer()
 
 	// Check for initialization errors
 	if i.mapsErr != nil {
@@ -153,8 +159,55 @@ func (i *MapTrackerInfer) Run(ctx *maa.Context, arg *maa.CustomRecognitionArg) (
 		return nil, false
 	}
 
+	result := i.infer(arg.Img, mapNameRegex, param, time.Now().UnixMilli())
+	if result == nil {
+		if param.Print {
+			maafocus.NodeActionStarting(ctx, inferenceFailedHTML)
+		}
+
+		// Lost tracking eventually counts as leaving every geofence
+		if events := globalGeofenceTracker.UpdateLost(); len(events) > 0 {
+			dispatchGeofenceEvents(ctx, events)
+		}
+
+		// Return as not hit
+		return &maa.CustomRecognitionResult{
+			Box:    arg.Roi,
+			Detail: "",
+		}, false
+	}
+
+	// Serialize result to JSON
+	detailJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to marshal result")
+		return nil, false
+	}
+
+	if param.Print {
+		maafocus.NodeActionStarting(ctx, fmt.Sprintf(inferenceFinishedHTML, result.X, result.Y, result.Rot, result.MapName))
+	}
+
+	// Feed geofence tracker with this location update
+	if events := globalGeofenceTracker.Update(result.MapName, result.X, result.Y); len(events) > 0 {
+		dispatchGeofenceEvents(ctx, events)
+	}
+
+	// Return as hit
+	return &maa.CustomRecognitionResult{
+		Box:    arg.Roi,
+		Detail: string(detailJSON),
+	}, true
+}
+
+// infer locates the player in one frame taken at nowMs and advances the time-series state.
+// It does not need a maa.Context, so the offline benchmark can drive it with the frame clock.
+// Returns nil if the location or the rotation is not hit.
+func (i *MapTrackerInfer) infer(img image.Image, mapNameRegex *regexp.Regexp, param *MapTrackerInferParam, nowMs int64) *MapTrackerInferResult {
+	rotStep := max(2, min(8, int(math.Round(8-param.Precision*6))))
+
 	// Perform inference
-	screenImg := minicv.ImageConvertRGBA(arg.Img)
+	screenImg := minicv.ImageConvertRGBA(img)
 	t0 := time.Now()
 
 	var wg sync.WaitGroup
@@ -165,7 +218,7 @@ func (i *MapTrackerInfer) Run(ctx *maa.Context, arg *maa.CustomRecognitionArg) (
 
 	go func() {
 		defer wg.Done()
-		loc = i.inferLocation(screenImg, mapNameRegex, param)
+		loc = i.inferLocation(screenImg, mapNameRegex, param, nowMs)
 	}()
 
 	go func() {
@@ -184,7 +237,6 @@ func (i *MapTrackerInfer) Run(ctx *maa.Context, arg *maa.CustomRecognitionArg) (
 	var finalRot *InferRotationRawResult
 
 	globalInferState.mu.Lock()
-	nowMs := time.Now().UnixMilli()
 
 	// Process internal location hit
 	if internalLocHit {
@@ -290,19 +342,11 @@ func (i *MapTrackerInfer) Run(ctx *maa.Context, arg *maa.CustomRecognitionArg) (
 
 	if !finalHit {
 		log.Info().Bool("finalLocHit", finalLoc != nil).Bool("finalRotHit", finalRot != nil).Msg("Map tracking inference did not hit")
-		if param.Print {
-			maafocus.NodeActionStarting(ctx, inferenceFailedHTML)
-		}
-
-		// Return as not hit
-		return &maa.CustomRecognitionResult{
-			Box:    arg.Roi,
-			Detail: "",
-		}, false
+		return nil
 	}
 
 	// Build hit result
-	result := MapTrackerInferResult{
+	result := &MapTrackerInferResult{
 		MapName:     finalLoc.mapName,
 		X:           finalLoc.x,
 		Y:           finalLoc.y,
@@ -313,13 +357,8 @@ func (i *MapTrackerInfer) Run(ctx *maa.Context, arg *maa.CustomRecognitionArg) (
 		RotTimeMs:   finalRot.elapsedTimeMs,
 		InferMode:   string(finalLoc.source),
 		InferTimeMs: finalElapsedTimeMs,
-	}
-
-	// Serialize result to JSON
-	detailJSON, err := json.Marshal(result)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to marshal result")
-		return nil, false
+		BaseMapName: i.tiers.BaseMap(finalLoc.mapName),
+		Tier:        i.tiers.TierID(finalLoc.mapName),
 	}
 
 	log.Info().Str("InferMode", result.InferMode).
@@ -329,16 +368,10 @@ func (i *MapTrackerInfer) Run(ctx *maa.Context, arg *maa.CustomRecognitionArg) (
 		Int("Rot", result.Rot).
 		Float64("LocConf", result.LocConf).
 		Float64("RotConf", result.RotConf).
+		Int("Tier", result.Tier).
 		Msg("Map tracking inference completed")
-	if param.Print {
-		maafocus.NodeActionStarting(ctx, fmt.Sprintf(inferenceFinishedHTML, finalLoc.x, finalLoc.y, result.Rot, finalLoc.mapName))
-	}
 
-	// Return as hit
-	return &maa.CustomRecognitionResult{
-		Box:    arg.Roi,
-		Detail: string(detailJSON),
-	}, true
+	return result
 }
 
 func (r *MapTrackerInfer) parseParam(paramStr string) (*MapTrackerInferParam, error) {
@@ -370,21 +403,27 @@ func (r *MapTrackerInfer) parseParam(paramStr string) (*MapTrackerInferParam, er
 }
 
 // initMaps initializes the map cache (thread-safe, runs once)
-func (i *MapTrackerInfer) initMaps(ctx *maa.Context) {
+func (i *MapTrackerInfer) initMaps() {
 	i.mapsOnce.Do(func() {
-		i.maps, i.mapsErr = i.loadMaps(ctx)
+		i.maps, i.mapsErr = i.loadMaps()
 		if i.mapsErr != nil {
 			log.Error().Err(i.mapsErr).Msg("Failed to load maps")
-		} else {
-			log.Info().Int("mapsCount", len(i.maps)).Msg("Map images loaded")
+			return
+		}
+		log.Info().Int("mapsCount", len(i.maps)).Msg("Map images loaded")
+
+		mapNames := make([]string, 0, len(i.maps))
+		for _, m := range i.maps {
+			mapNames = append(mapNames, m.Name)
 		}
+		i.tiers = loadMapTierIndex(mapNames)
 	})
 }
 
 // initPointer initializes the pointer template cache (thread-safe, runs once)
-func (i *MapTrackerInfer) initPointer(ctx *maa.Context) {
+func (i *MapTrackerInfer) initPointer() {
 	i.pointerOnce.Do(func() {
-		i.pointer, i.pointerErr = i.loadPointer(ctx)
+		i.pointer, i.pointerErr = i.loadPointer()
 		if i.pointerErr != nil {
 			log.Error().Err(i.pointerErr).Msg("Failed to load pointer template")
 		} else {
@@ -395,7 +434,7 @@ func (i *MapTrackerInfer) initPointer(ctx *maa.Context) {
 
 // loadMaps loads all map images from the resource directory
 // and try crops them if map bbox data exists
-func (i *MapTrackerInfer) loadMaps(ctx *maa.Context) ([]MapCache, error) {
+func (i *MapTrackerInfer) loadMaps() ([]MapCache, error) {
 	// Find map directory using search strategy
 	mapDir := findResource(MAP_DIR)
 	if mapDir == "" {
@@ -473,15 +512,16 @@ func (i *MapTrackerInfer) loadMaps(ctx *maa.Context) ([]MapCache, error) {
 			imgRGBA = minicv.ImageConvertRGBA(img)
 		}
 
-		// Precompute integral image
-		integral := minicv.GetIntegralArray(imgRGBA)
+		// Image pyramid, whose base level holds the integral image; coarser levels are built on demand
+		pyramid := minicv.NewPyramid(imgRGBA, MAP_PYRAMID_LEVELS, LOC_RADIUS)
 
 		maps = append(maps, MapCache{
 			Name:     name,
 			Img:      imgRGBA,
-			Integral: integral,
+			Integral: pyramid.Base().Integral,
 			OffsetX:  offsetX,
 			OffsetY:  offsetY,
+			Pyramid:  pyramid,
 		})
 	}
 
@@ -493,7 +533,7 @@ func (i *MapTrackerInfer) loadMaps(ctx *maa.Context) ([]MapCache, error) {
 }
 
 // loadPointer loads the pointer template image
-func (i *MapTrackerInfer) loadPointer(ctx *maa.Context) (*image.RGBA, error) {
+func (i *MapTrackerInfer) loadPointer() (*image.RGBA, error) {
 	// Find pointer template using search strategy
 	pointerPath := findResource(POINTER_PATH)
 	if pointerPath == "" {
@@ -518,7 +558,7 @@ func (i *MapTrackerInfer) loadPointer(ctx *maa.Context) (*image.RGBA, error) {
 
 // inferLocation infers the player's location on the map.
 // Returns a raw result with mapName, x/y (map coordinates), conf, source, and elapsedTimeMs.
-func (i *MapTrackerInfer) inferLocation(screenImg *image.RGBA, mapNameRegex *regexp.Regexp, param *MapTrackerInferParam) *InferLocationRawResult {
+func (i *MapTrackerInfer) inferLocation(screenImg *image.RGBA, mapNameRegex *regexp.Regexp, param *MapTrackerInferParam, nowMs int64) *InferLocationRawResult {
 	t0 := time.Now()
 
 	// Use cached scaled maps
@@ -531,13 +571,14 @@ func (i *MapTrackerInfer) inferLocation(screenImg *image.RGBA, mapNameRegex *reg
 
 	// Crop and scale mini-map area from screen
 	miniMap := minicv.ImageCropSquareByRadius(screenImg, LOC_CENTER_X, LOC_CENTER_Y, LOC_RADIUS)
-	miniMap = minicv.ImageScale(miniMap, scale)
+	miniMap = scaleNeedle(miniMap, scale)
 	miniMapBounds := miniMap.Bounds()
 	miniMapW, miniMapH := miniMapBounds.Dx(), miniMapBounds.Dy()
 	miniMapHalfW, miniMapHalfH := float64(miniMapW)/2.0, float64(miniMapH)/2.0
 
-	// Precompute needle (minimap) statistics for all matches
-	miniStats := minicv.GetImageStats(miniMap)
+	// Precompute needle (minimap) mask and statistics for all matches
+	miniMask := getMiniMapMask(miniMapW, miniMapH)
+	miniStats := minicv.GetMaskedImageStats(miniMap, miniMask)
 	if miniStats.Std < 1e-6 {
 		return nil
 	}
@@ -548,7 +589,7 @@ func (i *MapTrackerInfer) inferLocation(screenImg *image.RGBA, mapNameRegex *reg
 	globalInferState.mu.Lock()
 
 	isStable := globalInferState.convinced.mapName != "" &&
-		(time.Now().UnixMilli()-globalInferState.convincedLastHitTime < CONVINCED_VALID_TIME_MS) &&
+		(nowMs-globalInferState.convincedLastHitTime < CONVINCED_VALID_TIME_MS) &&
 		globalInferState.pendingHitCount == 0
 	stableMapName := globalInferState.convinced.mapName
 	stableLocX := globalInferState.convinced.x
@@ -558,64 +599,72 @@ func (i *MapTrackerInfer) inferLocation(screenImg *image.RGBA, mapNameRegex *reg
 
 	// Try fast search if stable
 	if isStable && mapNameRegex.MatchString(stableMapName) {
+		// Also search other floors covering this location, so that floor changes can be detected
+		fastCandidates := make([]locationCandidate, 0, 1)
 		for _, mapData := range scaledMaps {
-			if mapData.Name == stableMapName {
-				expectedCenterX := int(math.Round((stableLocX - float64(mapData.OffsetX)) * scale))
-				expectedCenterY := int(math.Round((stableLocY - float64(mapData.OffsetY)) * scale))
-				searchRadius := max(int(float64(CONVINCED_DISTANCE_THRESHOLD)*scale), 1)
-
-				matchX, matchY, matchVal := minicv.MatchTemplateInArea(
-					mapData.Img,
-					mapData.Integral,
-					miniMap,
-					miniStats,
-					expectedCenterX-searchRadius,
-					expectedCenterY-searchRadius,
-					searchRadius*2,
-					searchRadius*2,
-				)
-
-				if matchVal > param.Threshold {
-					// Fast search hit
-					bestX := roundTo1Decimal((matchX+miniMapHalfW)/scale + float64(mapData.OffsetX))
-					bestY := roundTo1Decimal((matchY+miniMapHalfH)/scale + float64(mapData.OffsetY))
-					elapsedTimeMs := time.Since(t0).Milliseconds()
-					log.Debug().Float64("conf", matchVal).
-						Str("map", stableMapName).
-						Float64("X", bestX).
-						Float64("Y", bestY).
-						Int64("elapsedTimeMs", elapsedTimeMs).
-						Msg("Internal fast search location inference completed")
-
-					return &InferLocationRawResult{
-						mapName:       mapData.Name,
-						x:             bestX,
-						y:             bestY,
-						conf:          matchVal,
-						source:        FAST_SEARCH_HIT,
-						elapsedTimeMs: elapsedTimeMs,
-					}
+			if mapData.Name != stableMapName {
+				if !mapNameRegex.MatchString(mapData.Name) ||
+					!i.tiers.IsSameFamily(mapData.Name, stableMapName) ||
+					!i.tiers.Contains(mapData.Name, stableLocX, stableLocY) {
+					continue
 				}
+			}
 
-				// If fast search fails (low confidence), fallback to full search
-				log.Debug().Float64("conf", matchVal).Msg("Empirical fast search miss")
-				break
+			expectedCenterX := int(math.Round((stableLocX - float64(mapData.OffsetX)) * scale))
+			expectedCenterY := int(math.Round((stableLocY - float64(mapData.OffsetY)) * scale))
+			searchRadius := max(int(float64(CONVINCED_DISTANCE_THRESHOLD)*scale), 1)
+
+			matchX, matchY, matchVal := minicv.MatchTemplateMaskedInArea(
+				mapData.Img,
+				mapData.Integral,
+				miniMap,
+				miniMask,
+				miniStats,
+				expectedCenterX-searchRadius,
+				expectedCenterY-searchRadius,
+				searchRadius*2,
+				searchRadius*2,
+			)
+			fastCandidates = append(fastCandidates, locationCandidate{
+				val:     matchVal,
+				x:       roundTo1Decimal((matchX+miniMapHalfW)/scale + float64(mapData.OffsetX)),
+				y:       roundTo1Decimal((matchY+miniMapHalfH)/scale + float64(mapData.OffsetY)),
+				mapName: mapData.Name,
+			})
+		}
+
+		best, kept := i.tiers.selectTierAware(fastCandidates, stableMapName)
+		if best.val > param.Threshold {
+			// Fast search hit
+			elapsedTimeMs := time.Since(t0).Milliseconds()
+			log.Debug().Float64("conf", best.val).
+				Str("map", best.mapName).
+				Float64("X", best.x).
+				Float64("Y", best.y).
+				Int("floorsTried", len(fastCandidates)).
+				Bool("tierKept", kept).
+				Int64("elapsedTimeMs", elapsedTimeMs).
+				Msg("Internal fast search location inference completed")
+
+			return &InferLocationRawResult{
+				mapName:       best.mapName,
+				x:             best.x,
+				y:             best.y,
+				conf:          best.val,
+				source:        FAST_SEARCH_HIT,
+				elapsedTimeMs: elapsedTimeMs,
 			}
 		}
+
+		// If fast search fails (low confidence), fallback to full search
+		log.Debug().Float64("conf", best.val).Msg("Empirical fast search miss")
 	} else {
 		log.Debug().Msg("Empirical fast search skipped, not in stable state or regex mismatch")
 	}
 
-	// Match against all maps in parallel
-	type mapResult struct {
-		val     float64
-		x, y    float64
-		mapName string
-	}
-
-	bestVal := -1.0
-	bestX, bestY := 0.0, 0.0
-	bestMapName := ""
+	// Match against all maps in parallel, coarse-to-fine over each scaled map's pyramid
+	locPyramidOpts := minicv.PyramidMatchOptions{TopK: LOC_PYRAMID_TOP_K, MaxLevel: LOC_PYRAMID_MAX_LEVEL}
+	candidates := make([]locationCandidate, 0)
 	triedCount := 0
 
 	// Special case: if there's only one map to check, run it directly to avoid goroutine overhead
@@ -633,13 +682,15 @@ func (i *MapTrackerInfer) inferLocation(screenImg *image.RGBA, mapNameRegex *reg
 	}
 
 	if singleMapToTry != nil {
-		matchX, matchY, matchVal := minicv.MatchTemplate(singleMapToTry.Img, singleMapToTry.Integral, miniMap, miniStats)
-		bestVal = matchVal
-		bestX = roundTo1Decimal((matchX+miniMapHalfW)/scale + float64(singleMapToTry.OffsetX))
-		bestY = roundTo1Decimal((matchY+miniMapHalfH)/scale + float64(singleMapToTry.OffsetY))
-		bestMapName = singleMapToTry.Name
+		matchX, matchY, matchVal := minicv.MatchTemplateMaskedPyramid(singleMapToTry.Pyramid, miniMap, miniMask, locPyramidOpts)
+		candidates = append(candidates, locationCandidate{
+			val:     matchVal,
+			x:       roundTo1Decimal((matchX+miniMapHalfW)/scale + float64(singleMapToTry.OffsetX)),
+			y:       roundTo1Decimal((matchY+miniMapHalfH)/scale + float64(singleMapToTry.OffsetY)),
+			mapName: singleMapToTry.Name,
+		})
 	} else if triedCount > 1 {
-		resChan := make(chan mapResult, triedCount)
+		resChan := make(chan locationCandidate, triedCount)
 		var wg sync.WaitGroup
 
 		for _, mapData := range scaledMaps {
@@ -650,10 +701,10 @@ func (i *MapTrackerInfer) inferLocation(screenImg *image.RGBA, mapNameRegex *reg
 			wg.Add(1)
 			go func(m MapCache) {
 				defer wg.Done()
-				matchX, matchY, matchVal := minicv.MatchTemplate(m.Img, m.Integral, miniMap, miniStats)
+				matchX, matchY, matchVal := minicv.MatchTemplateMaskedPyramid(m.Pyramid, miniMap, miniMask, locPyramidOpts)
 				mx := roundTo1Decimal((matchX+miniMapHalfW)/scale + float64(m.OffsetX))
 				my := roundTo1Decimal((matchY+miniMapHalfH)/scale + float64(m.OffsetY))
-				resChan <- mapResult{matchVal, mx, my, m.Name}
+				resChan <- locationCandidate{matchVal, mx, my, m.Name}
 			}(mapData)
 		}
 
@@ -663,15 +714,18 @@ func (i *MapTrackerInfer) inferLocation(screenImg *image.RGBA, mapNameRegex *reg
 		}()
 
 		for res := range resChan {
-			if res.val > bestVal {
-				bestVal = res.val
-				bestX = res.x
-				bestY = res.y
-				bestMapName = res.mapName
-			}
+			candidates = append(candidates, res)
 		}
 	}
 
+	// Prefer staying on the current floor when another floor of the same map scores similarly
+	currentMapName := ""
+	if isStable {
+		currentMapName = stableMapName
+	}
+	best, kept := i.tiers.selectTierAware(candidates, currentMapName)
+	bestVal, bestX, bestY, bestMapName := best.val, best.x, best.y, best.mapName
+
 	if triedCount == 0 {
 		log.Warn().Str("regex", mapNameRegex.String()).Msg("No maps matched the regex")
 	}
@@ -682,6 +736,7 @@ func (i *MapTrackerInfer) inferLocation(screenImg *image.RGBA, mapNameRegex *reg
 		Str("bestMap", bestMapName).
 		Float64("X", bestX).
 		Float64("Y", bestY).
+		Bool("tierKept", kept).
 		Int64("elapsedTimeMs", elapsedTimeMs).
 		Msg("Internal location inference completed")
 
@@ -707,13 +762,14 @@ func (i *MapTrackerInfer) getScaledMaps(scale float64) []MapCache {
 	log.Info().Float64("scale", scale).Msg("Recomputing scaled maps cache")
 	newScaled := make([]MapCache, 0, len(i.maps))
 	for _, m := range i.maps {
-		sImg := minicv.ImageScale(m.Img, scale)
+		level := m.Pyramid.AtScale(scale)
 		newScaled = append(newScaled, MapCache{
 			Name:     m.Name,
-			Img:      sImg,
-			Integral: minicv.GetIntegralArray(sImg),
+			Img:      level.Img,
+			Integral: level.Integral,
 			OffsetX:  m.OffsetX,
 			OffsetY:  m.OffsetY,
+			Pyramid:  minicv.NewPyramidFromLevel(level, MAP_PYRAMID_LEVELS, LOC_RADIUS),
 		})
 	}
 	i.scaledScale = scale
@@ -721,6 +777,28 @@ func (i *MapTrackerInfer) getScaledMaps(scale float64) []MapCache {
 	return i.scaledMaps
 }
 
+// scaleNeedle scales a template image the same way as map images are scaled by their pyramids,
+// whose depth is limited to MAP_PYRAMID_LEVELS
+func scaleNeedle(img *image.RGBA, scale float64) *image.RGBA {
+	coarsest := math.Ldexp(1, 1-MAP_PYRAMID_LEVELS)
+	if scale >= coarsest {
+		return minicv.ImagePyrScale(img, scale)
+	}
+	return minicv.ImageScale(minicv.ImagePyrScale(img, coarsest), scale/coarsest)
+}
+
+// getMiniMapMask builds the match mask for a scaled mini-map crop of size w*h,
+// ignoring the player pointer in the center and the area outside the circular mini-map
+func getMiniMapMask(w, h int) *minicv.TemplateMask {
+	cx, cy := float64(w)/2.0, float64(h)/2.0
+	radius := math.Min(cx, cy)
+	inner, outer := radius*LOC_MASK_INNER_RATIO, radius*LOC_MASK_OUTER_RATIO
+	return minicv.NewTemplateMaskFunc(w, h, func(x, y int) bool {
+		d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy)
+		return d >= inner && d <= outer
+	})
+}
+
 // inferRotation infers the player's rotation angle
 // Returns (angle, confidence)
 func (i *MapTrackerInfer) inferRotation(screenImg *image.RGBA, rotStep int) *InferRotationRawResult {
@@ -603,7 +603,7 @@ func buildNavigationPreviewDataURL(path [][2]float64, targetIndex int, mapName s
 	}
 
 	canvas := image.NewRGBA(image.Rect(0, 0, canvasSize, canvasSize))
-	draw.Draw(canvas, canvas.Bounds(), &image.Uniform{C: color.RGBA{0xf7, 0xfb, 0xff, 0xff}}, image.Point{}, draw.Src)
+	minicv.ImageFillRect(canvas, canvas.Bounds(), color.RGBA{0xf7, 0xfb, 0xff, 0xff})
 
 	b := mapRGBA.Bounds()
 	srcMinX := int(math.Floor((-offsetX) / scale))
@@ -634,25 +634,20 @@ func buildNavigationPreviewDataURL(path [][2]float64, targetIndex int, mapName s
 		colorBlue  = color.RGBA{0x2b, 0x62, 0xc0, 0xff} // 0x2b62c0
 	)
 
-	for i := 0; i+1 < len(drawPath); i++ {
-		x1 := int(math.Round(drawPath[i][0]*scale + offsetX))
-		y1 := int(math.Round(drawPath[i][1]*scale + offsetY))
-		x2 := int(math.Round(drawPath[i+1][0]*scale + offsetX))
-		y2 := int(math.Round(drawPath[i+1][1]*scale + offsetY))
-		minicv.ImageDrawLine(canvas, x1, y1, x2, y2, colorBlue, 3)
-	}
-
+	pathPoints := make([]image.Point, 0, len(drawPath))
 	for _, p := range drawPath {
-		x := int(math.Round(p[0]*scale + offsetX))
-		y := int(math.Round(p[1]*scale + offsetY))
-		minicv.ImageDrawFilledCircle(canvas, x, y, 4, colorBlue)
+		pathPoints = append(pathPoints, image.Pt(int(math.Round(p[0]*scale+offsetX)), int(math.Round(p[1]*scale+offsetY))))
+	}
+	minicv.ImageDrawPolyline(canvas, pathPoints, colorBlue, 3)
+	for _, p := range pathPoints {
+		minicv.ImageDrawFilledCircle(canvas, p.X, p.Y, 4, colorBlue)
 	}
 
 	curX := int(math.Round(currentViewX))
 	curY := int(math.Round(currentViewY))
 	tgtX := int(math.Round(targetX*scale + offsetX))
 	tgtY := int(math.Round(targetY*scale + offsetY))
-	minicv.ImageDrawLine(canvas, curX, curY, tgtX, tgtY, colorRed, 3)
+	minicv.ImageDrawArrow(canvas, curX, curY, tgtX, tgtY, colorRed, 3, 12)
 	minicv.ImageDrawFilledCircle(canvas, tgtX, tgtY, 5, colorRed)
 	minicv.ImageDrawFilledCircle(canvas, curX, curY, 5, colorGreen)
 
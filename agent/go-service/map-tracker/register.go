@@ -12,4 +12,6 @@ func Register() {
 	maa.AgentServerRegisterCustomRecognition("MapTrackerAssertLocation", &MapTrackerAssertLocation{})
 	maa.AgentServerRegisterCustomAction("MapTrackerMove", &MapTrackerMove{})
 	maa.AgentServerRegisterCustomAction("MapTrackerBigMapPick", &MapTrackerBigMapPick{})
+	maa.AgentServerRegisterCustomAction("MapTrackerGeofenceRegister", &MapTrackerGeofenceRegister{})
+	maa.AgentServerRegisterCustomAction("MapTrackerGeofenceUnregister", &MapTrackerGeofenceUnregister{})
 }
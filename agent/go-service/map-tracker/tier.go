@@ -0,0 +1,139 @@
+// Copyright (c) 2026 Harry Huang
+package maptracker
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+// MapTierInfo describes one floor (tier) of a multi-storey map.
+// Tier maps share the coordinate space of their parent map.
+type MapTierInfo struct {
+	// Name is the tier map name, e.g. "map01_lv001_tier_114".
+	Name string
+	// Parent is the base map name, e.g. "map01_lv001".
+	Parent string
+	// TierID is the numeric tier suffix of the map name.
+	// It identifies the floor but does not tell its height: the vertical order of floors is not modelled.
+	TierID int
+	// Bounds is the tier area [x1, y1, x2, y2] in parent map coordinates.
+	Bounds [4]int
+}
+
+// MapTierIndex indexes tier metadata by map name
+type MapTierIndex struct {
+	tiers map[string]*MapTierInfo
+}
+
+var tierMapNamePattern = regexp.MustCompile(`^(.+)_tier_(\d+)$`)
+
+// loadMapTierIndex builds tier metadata for the given map names.
+// Parent and tier ID are derived from the map name and bounds from map bbox data.
+func loadMapTierIndex(mapNames []string) *MapTierIndex {
+	index := &MapTierIndex{tiers: make(map[string]*MapTierInfo)}
+
+	bboxList := make(map[string][]int)
+	if path := findResource(MAP_BBOX_DATA_PATH); path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			if err := json.Unmarshal(data, &bboxList); err != nil {
+				log.Warn().Err(err).Str("path", path).Msg("Failed to unmarshal map bbox data for tiers")
+			}
+		}
+	}
+
+	for _, name := range mapNames {
+		m := tierMapNamePattern.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		tierID, _ := strconv.Atoi(m[2])
+		info := &MapTierInfo{Name: name, Parent: m[1], TierID: tierID}
+		if b, ok := bboxList[name]; ok && len(b) == 4 {
+			info.Bounds = [4]int{b[0], b[1], b[2], b[3]}
+		}
+		index.tiers[name] = info
+	}
+
+	return index
+}
+
+// Get returns the tier info of a map, or nil if it is not a tier map
+func (idx *MapTierIndex) Get(mapName string) *MapTierInfo {
+	if idx == nil {
+		return nil
+	}
+	return idx.tiers[mapName]
+}
+
+// BaseMap returns the parent map name of a tier map, or the name itself otherwise
+func (idx *MapTierIndex) BaseMap(mapName string) string {
+	if info := idx.Get(mapName); info != nil {
+		return info.Parent
+	}
+	return mapName
+}
+
+// TierID returns the tier ID of a map (0 for non-tier maps)
+func (idx *MapTierIndex) TierID(mapName string) int {
+	if info := idx.Get(mapName); info != nil {
+		return info.TierID
+	}
+	return 0
+}
+
+// IsSameFamily reports whether two maps are floors of the same base map
+func (idx *MapTierIndex) IsSameFamily(a, b string) bool {
+	return idx.BaseMap(a) == idx.BaseMap(b)
+}
+
+// Contains reports whether (x, y) lies within the area of the given map's floor.
+// Non-tier maps are treated as unbounded.
+func (idx *MapTierIndex) Contains(mapName string, x, y float64) bool {
+	info := idx.Get(mapName)
+	if info == nil || info.Bounds == [4]int{} {
+		return true
+	}
+	return x >= float64(info.Bounds[0]) && x < float64(info.Bounds[2]) &&
+		y >= float64(info.Bounds[1]) && y < float64(info.Bounds[3])
+}
+
+// locationCandidate is one map's best match during location inference
+type locationCandidate struct {
+	val     float64
+	x, y    float64
+	mapName string
+}
+
+// selectTierAware picks the best candidate, but keeps the current floor unless another floor
+// of the same map is clearly better, which prevents flipping between floors with similar scores.
+// Floor changes are detected from the mini-map match alone; elevators and stairs are not known.
+func (idx *MapTierIndex) selectTierAware(candidates []locationCandidate, currentMap string) (locationCandidate, bool) {
+	if len(candidates) == 0 {
+		return locationCandidate{val: -1.0}, false
+	}
+
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if c.val > best.val {
+			best = c
+		}
+	}
+	if currentMap == "" || best.mapName == currentMap || !idx.IsSameFamily(best.mapName, currentMap) {
+		return best, false
+	}
+
+	for _, c := range candidates {
+		if c.mapName != currentMap {
+			continue
+		}
+		if best.val-c.val < TIER_SWITCH_SCORE_MARGIN {
+			return c, true
+		}
+		break
+	}
+	return best, false
+}
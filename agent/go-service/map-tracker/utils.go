@@ -2,9 +2,12 @@
 package maptracker
 
 import (
+	"image"
 	"math"
 	"time"
 
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/framewait"
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/minicv"
 	"github.com/MaaXYZ/maa-framework-go/v4"
 )
 
@@ -63,9 +66,68 @@ func (bmv *BigMapViewport) IsViewCoordInView(viewX, viewY float64) bool {
 	return viewX >= bmv.Left && viewX <= bmv.Right && viewY >= bmv.Top && viewY <= bmv.Bottom
 }
 
+/* ******** Geometry ******** */
+
+// isPointInPolygon reports whether (x, y) is inside the polygon using ray casting
+func isPointInPolygon(x, y float64, polygon [][2]float64) bool {
+	inside := false
+	n := len(polygon)
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		xi, yi := polygon[i][0], polygon[i][1]
+		xj, yj := polygon[j][0], polygon[j][1]
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// distanceToPath returns the minimum distance from (x, y) to a polyline.
+// A single-point path is treated as a point.
+func distanceToPath(x, y float64, path [][2]float64) float64 {
+	if len(path) == 0 {
+		return math.Inf(1)
+	}
+	if len(path) == 1 {
+		return math.Hypot(x-path[0][0], y-path[0][1])
+	}
+	minDist := math.Inf(1)
+	for i := 0; i+1 < len(path); i++ {
+		ax, ay := path[i][0], path[i][1]
+		bx, by := path[i+1][0], path[i+1][1]
+		dx, dy := bx-ax, by-ay
+		t := 0.0
+		if lenSq := dx*dx + dy*dy; lenSq > 0 {
+			t = max(0, min(1, ((x-ax)*dx+(y-ay)*dy)/lenSq))
+		}
+		minDist = math.Min(minDist, math.Hypot(x-(ax+t*dx), y-(ay+t*dy)))
+	}
+	return minDist
+}
+
+// isRotationInRange reports whether rot lies on the clockwise arc from `from` to `to` (inclusive, degrees)
+func isRotationInRange(rot int, from, to float64) bool {
+	normalize := func(a float64) float64 {
+		a = math.Mod(a, 360)
+		if a < 0 {
+			a += 360
+		}
+		return a
+	}
+	// A span of a full turn or more covers every rotation (normalize would turn [0, 360] into 0)
+	if to-from >= 360 {
+		return true
+	}
+	span := normalize(to - from)
+	offset := normalize(float64(rot) - from)
+	return offset <= span
+}
+
 /* ******** Actions ******** */
 
-// ActionWrapper provides synchronized touch/key operations with built-in delays
+// ActionWrapper provides synchronized touch/key operations with built-in delays.
+// The delays are input timings (such as how long a key is held); to wait for the screen
+// to react to an operation, use WaitStableSync instead of a fixed delay.
 type ActionWrapper struct {
 	ctx  *maa.Context
 	ctrl *maa.Controller
@@ -102,6 +164,13 @@ func (aw *ActionWrapper) SwipeHoverSync(x, y, dx, dy int, durationMillis, delayM
 	time.Sleep(time.Duration(delayMillis) * time.Millisecond)
 }
 
+// ScrollSync hovers at (x, y) and scrolls the mouse wheel by (dx, dy)
+func (aw *ActionWrapper) ScrollSync(x, y, dx, dy int, delayMillis int) {
+	aw.ctrl.PostTouchMove(0, int32(x), int32(y), 0).Wait()
+	aw.ctrl.PostScroll(int32(dx), int32(dy)).Wait()
+	time.Sleep(time.Duration(delayMillis) * time.Millisecond)
+}
+
 // KeyDownSync sends a key press
 func (aw *ActionWrapper) KeyDownSync(keyCode int, delayMillis int) {
 	aw.ctrl.PostKeyDown(int32(keyCode)).Wait()
@@ -120,6 +189,14 @@ func (aw *ActionWrapper) KeyTypeSync(keyCode int, delayMillis int) {
 	time.Sleep(time.Duration(delayMillis) * time.Millisecond)
 }
 
+// WaitStableSync waits until the given screen area stops changing, for at most timeoutMillis
+func (aw *ActionWrapper) WaitStableSync(rect image.Rectangle, timeoutMillis int) {
+	framewait.Stable(aw.ctrl, minicv.FrameWaitOptions{
+		Rect:    rect,
+		Timeout: time.Duration(timeoutMillis) * time.Millisecond,
+	})
+}
+
 // RotateCamera performs a camera rotation via series of mouse-keyboard operations
 func (aw *ActionWrapper) RotateCamera(dx int, durationMillis, delayMillis int) {
 	cx, cy := WORK_W/2, WORK_H/2
@@ -0,0 +1,55 @@
+// Package framewait provides helpers to wait for the screen of a controller
+// to change or settle, instead of sleeping for a fixed delay.
+package framewait
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/minicv"
+	"github.com/MaaXYZ/maa-framework-go/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// Source returns a frame source that takes a new screenshot from the controller on each call
+func Source(ctrl *maa.Controller) minicv.FrameSource {
+	return func() (*image.RGBA, error) {
+		if ctrl == nil {
+			return nil, fmt.Errorf("controller is nil")
+		}
+		ctrl.PostScreencap().Wait()
+		img, err := ctrl.CacheImage()
+		if err != nil {
+			return nil, err
+		}
+		if img == nil {
+			return nil, fmt.Errorf("cached image is nil")
+		}
+		return minicv.ImageConvertRGBA(img), nil
+	}
+}
+
+// Stable polls the controller until the watched area stops changing or the timeout expires.
+// Returns the last frame (nil if no frame could be captured) and whether the area became stable.
+func Stable(ctrl *maa.Controller, opts minicv.FrameWaitOptions) (*image.RGBA, bool) {
+	img, ok, err := minicv.WaitFrameStable(Source(ctrl), opts)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to capture frame while waiting for stable screen")
+	} else if !ok {
+		log.Debug().Interface("rect", opts.Rect).Msg("Screen not stable before timeout")
+	}
+	return img, ok
+}
+
+// Changed polls the controller until the watched area differs from reference or the timeout expires.
+// If reference is nil, the first captured frame is used.
+// Returns the last frame (nil if no frame could be captured) and whether a change was seen.
+func Changed(ctrl *maa.Controller, reference *image.RGBA, opts minicv.FrameWaitOptions) (*image.RGBA, bool) {
+	img, ok, err := minicv.WaitFrameChanged(Source(ctrl), reference, opts)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to capture frame while waiting for screen change")
+	} else if !ok {
+		log.Debug().Interface("rect", opts.Rect).Msg("Screen not changed before timeout")
+	}
+	return img, ok
+}
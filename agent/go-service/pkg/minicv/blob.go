@@ -0,0 +1,271 @@
+// Copyright (c) 2026 Harry Huang
+package minicv
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+/* ******** Connected Components ******** */
+
+// Component describes a connected region of set pixels in a binary image
+type Component struct {
+	Area    int
+	Bounds  image.Rectangle
+	CenterX float64 // Centroid X
+	CenterY float64 // Centroid Y
+	Label   int     // Label in the label image, starting from 1
+}
+
+// ConnectedComponents labels connected regions of set pixels.
+// Returns the components and a label image (0 means background).
+// If eightConn is true, diagonal neighbours are considered connected.
+func ConnectedComponents(mask *BinaryImage, eightConn bool) ([]Component, []int) {
+	labels := make([]int, mask.W*mask.H)
+	components := make([]Component, 0)
+	stack := make([]int, 0, 64)
+
+	for start, set := range mask.Pix {
+		if !set || labels[start] != 0 {
+			continue
+		}
+
+		label := len(components) + 1
+		comp := Component{Label: label, Bounds: image.Rect(start%mask.W, start/mask.W, start%mask.W+1, start/mask.W+1)}
+		labels[start] = label
+		stack = append(stack[:0], start)
+		sumX, sumY := 0, 0
+
+		for len(stack) > 0 {
+			idx := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := idx%mask.W, idx/mask.W
+
+			comp.Area++
+			sumX += x
+			sumY += y
+			comp.Bounds = comp.Bounds.Union(image.Rect(x, y, x+1, y+1))
+
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if (dx == 0 && dy == 0) || (!eightConn && dx != 0 && dy != 0) {
+						continue
+					}
+					nx, ny := x+dx, y+dy
+					if !mask.At(nx, ny) {
+						continue
+					}
+					nIdx := ny*mask.W + nx
+					if labels[nIdx] == 0 {
+						labels[nIdx] = label
+						stack = append(stack, nIdx)
+					}
+				}
+			}
+		}
+
+		comp.CenterX = float64(sumX) / float64(comp.Area)
+		comp.CenterY = float64(sumY) / float64(comp.Area)
+		components = append(components, comp)
+	}
+
+	return components, labels
+}
+
+// LargestComponentArea returns the area of the largest connected region of set pixels (8-connectivity)
+func LargestComponentArea(mask *BinaryImage) int {
+	components, _ := ConnectedComponents(mask, true)
+	largest := 0
+	for _, c := range components {
+		largest = max(largest, c.Area)
+	}
+	return largest
+}
+
+/* ******** Blobs ******** */
+
+// BlobOptions controls which connected components are reported as blobs.
+// Zero values disable the corresponding limit.
+type BlobOptions struct {
+	MinArea      int
+	MaxArea      int
+	MinWidth     int
+	MinHeight    int
+	MaxWidth     int
+	MaxHeight    int
+	MinFillRatio float64 // Minimum Area / (Width * Height)
+	EightConn    bool    // Whether diagonal neighbours are connected
+}
+
+// accepts reports whether a component satisfies the options
+func (o *BlobOptions) accepts(c *Component) bool {
+	w, h := c.Bounds.Dx(), c.Bounds.Dy()
+	if c.Area < o.MinArea || (o.MaxArea > 0 && c.Area > o.MaxArea) {
+		return false
+	}
+	if w < o.MinWidth || h < o.MinHeight {
+		return false
+	}
+	if (o.MaxWidth > 0 && w > o.MaxWidth) || (o.MaxHeight > 0 && h > o.MaxHeight) {
+		return false
+	}
+	return float64(c.Area) >= o.MinFillRatio*float64(w*h)
+}
+
+// FindBlobs extracts connected components of a binary image that satisfy the options.
+// Bounds and centroids are offset by origin, which is usually the Min of the rect the mask was built from.
+// Blobs are sorted top-to-bottom, then left-to-right by their bounds.
+func FindBlobs(mask *BinaryImage, origin image.Point, opts BlobOptions) []Component {
+	components, _ := ConnectedComponents(mask, opts.EightConn)
+	blobs := make([]Component, 0, len(components))
+	for i := range components {
+		c := components[i]
+		if !opts.accepts(&c) {
+			continue
+		}
+		c.Bounds = c.Bounds.Add(origin)
+		c.CenterX += float64(origin.X)
+		c.CenterY += float64(origin.Y)
+		blobs = append(blobs, c)
+	}
+	sort.SliceStable(blobs, func(i, j int) bool {
+		if blobs[i].Bounds.Min.Y != blobs[j].Bounds.Min.Y {
+			return blobs[i].Bounds.Min.Y < blobs[j].Bounds.Min.Y
+		}
+		return blobs[i].Bounds.Min.X < blobs[j].Bounds.Min.X
+	})
+	return blobs
+}
+
+// FindBlobsHSV extracts blobs of pixels within an HSV range in an area of the image
+func FindBlobsHSV(img *image.RGBA, rect image.Rectangle, r HSVRange, opts BlobOptions) []Component {
+	rect = rect.Intersect(img.Bounds())
+	return FindBlobs(InRangeHSV(img, rect, r), rect.Min, opts)
+}
+
+/* ******** Grid Inference ******** */
+
+// Grid is a regular grid inferred from a set of points.
+// Column i is centered at OriginX + i * PitchX, row j at OriginY + j * PitchY.
+type Grid struct {
+	OriginX, OriginY float64
+	PitchX, PitchY   float64 // Zero if there is only one column/row
+	Cols, Rows       int
+}
+
+// CellCenter returns the center of the cell at (col, row)
+func (g *Grid) CellCenter(col, row int) (float64, float64) {
+	return g.OriginX + float64(col)*g.PitchX, g.OriginY + float64(row)*g.PitchY
+}
+
+// CellAt returns the (col, row) of the cell nearest to (x, y), and false if it is outside the grid
+func (g *Grid) CellAt(x, y float64) (int, int, bool) {
+	col, row := 0, 0
+	if g.PitchX > 0 {
+		col = int(math.Round((x - g.OriginX) / g.PitchX))
+	}
+	if g.PitchY > 0 {
+		row = int(math.Round((y - g.OriginY) / g.PitchY))
+	}
+	return col, row, col >= 0 && col < g.Cols && row >= 0 && row < g.Rows
+}
+
+// InferGrid infers a regular grid from points (usually blob centroids).
+// Points whose coordinates differ by at most tolerance are considered to be on the same column/row.
+// Missing columns/rows between detected ones are allowed and are counted in the grid size.
+// Returns nil if there are no points.
+func InferGrid(points [][2]float64, tolerance float64) *Grid {
+	if len(points) == 0 {
+		return nil
+	}
+	xs := make([]float64, len(points))
+	ys := make([]float64, len(points))
+	for i, p := range points {
+		xs[i], ys[i] = p[0], p[1]
+	}
+
+	originX, pitchX, cols := inferAxis(xs, tolerance)
+	originY, pitchY, rows := inferAxis(ys, tolerance)
+	return &Grid{
+		OriginX: originX,
+		OriginY: originY,
+		PitchX:  pitchX,
+		PitchY:  pitchY,
+		Cols:    cols,
+		Rows:    rows,
+	}
+}
+
+// InferGridFromBlobs infers a regular grid from the centroids of blobs
+func InferGridFromBlobs(blobs []Component, tolerance float64) *Grid {
+	points := make([][2]float64, 0, len(blobs))
+	for _, b := range blobs {
+		points = append(points, [2]float64{b.CenterX, b.CenterY})
+	}
+	return InferGrid(points, tolerance)
+}
+
+// inferAxis clusters 1D coordinates and fits origin + index * pitch to the cluster centers.
+// Returns (origin, pitch, count).
+func inferAxis(values []float64, tolerance float64) (float64, float64, int) {
+	centers := clusterAxis(values, tolerance)
+	if len(centers) == 1 {
+		return centers[0], 0, 1
+	}
+
+	// The smallest gap is a lower bound of the pitch; use the median of gaps close to it as the estimate,
+	// so that missing columns/rows (gaps of several pitches) do not bias the result
+	gaps := make([]float64, 0, len(centers)-1)
+	for i := 1; i < len(centers); i++ {
+		gaps = append(gaps, centers[i]-centers[i-1])
+	}
+	sort.Float64s(gaps)
+	unitGaps := make([]float64, 0, len(gaps))
+	for _, g := range gaps {
+		if g <= gaps[0]*1.5 {
+			unitGaps = append(unitGaps, g)
+		}
+	}
+	pitch := unitGaps[len(unitGaps)/2]
+
+	// Assign integer indices, then refine origin and pitch by least squares
+	indices := make([]float64, len(centers))
+	for i := 1; i < len(centers); i++ {
+		indices[i] = indices[i-1] + math.Max(1, math.Round((centers[i]-centers[i-1])/pitch))
+	}
+	var sumI, sumC, sumII, sumIC float64
+	for i, c := range centers {
+		sumI += indices[i]
+		sumC += c
+		sumII += indices[i] * indices[i]
+		sumIC += indices[i] * c
+	}
+	n := float64(len(centers))
+	denom := n*sumII - sumI*sumI
+	if math.Abs(denom) > 1e-12 {
+		pitch = (n*sumIC - sumI*sumC) / denom
+	}
+	origin := (sumC - pitch*sumI) / n
+
+	return origin, pitch, int(indices[len(indices)-1]) + 1
+}
+
+// clusterAxis groups sorted 1D coordinates whose neighbours are within tolerance, and returns the cluster means
+func clusterAxis(values []float64, tolerance float64) []float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	centers := make([]float64, 0)
+	sum, count := sorted[0], 1
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i]-sorted[i-1] <= tolerance {
+			sum += sorted[i]
+			count++
+			continue
+		}
+		centers = append(centers, sum/float64(count))
+		sum, count = sorted[i], 1
+	}
+	return append(centers, sum/float64(count))
+}
@@ -0,0 +1,379 @@
+// Copyright (c) 2026 Harry Huang
+package minicv
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+/* ******** Conversions ******** */
+
+// RGBToHSV converts normalized RGB [0, 1] to HSV: Hue[0, 360), Saturation[0, 1], Value[0, 1]
+func RGBToHSV(fr, fg, fb float64) (float64, float64, float64) {
+	maxC := math.Max(fr, math.Max(fg, fb))
+	minC := math.Min(fr, math.Min(fg, fb))
+	delta := maxC - minC
+
+	// Value
+	v := maxC
+
+	// Saturation
+	s := 0.0
+	if maxC != 0 {
+		s = delta / maxC
+	}
+
+	// Hue
+	h := 0.0
+	if delta != 0 {
+		switch maxC {
+		case fr:
+			h = (fg - fb) / delta
+			if fg < fb {
+				h += 6
+			}
+		case fg:
+			h = (fb-fr)/delta + 2
+		default:
+			h = (fr-fg)/delta + 4
+		}
+		h *= 60
+	}
+
+	return h, s, v
+}
+
+// HSVToRGB converts HSV (Hue[0, 360), Saturation[0, 1], Value[0, 1]) to normalized RGB [0, 1]
+func HSVToRGB(h, s, v float64) (float64, float64, float64) {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return r + m, g + m, b + m
+}
+
+// RGBToLab converts normalized sRGB [0, 1] to CIE L*a*b* (D65 white point).
+// L is [0, 100], a and b are roughly [-128, 127].
+func RGBToLab(fr, fg, fb float64) (float64, float64, float64) {
+	linear := func(c float64) float64 {
+		if c <= 0.04045 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	lr, lg, lb := linear(fr), linear(fg), linear(fb)
+
+	x := (0.4124564*lr + 0.3575761*lg + 0.1804375*lb) / 0.95047
+	y := 0.2126729*lr + 0.7151522*lg + 0.0721750*lb
+	z := (0.0193339*lr + 0.1191920*lg + 0.9503041*lb) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389.0 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27.0*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// LabDistance returns the CIE76 color difference (Delta E) between two L*a*b* colors
+func LabDistance(l1, a1, b1, l2, a2, b2 float64) float64 {
+	return math.Sqrt((l1-l2)*(l1-l2) + (a1-a2)*(a1-a2) + (b1-b2)*(b1-b2))
+}
+
+// GetPixelHSV returns the Hue[0, 360), Saturation[0, 1], Value[0, 1] of a pixel in absolute image coordinates
+func GetPixelHSV(img *image.RGBA, x, y int) (float64, float64, float64) {
+	off := img.PixOffset(x, y)
+	return RGBToHSV(float64(img.Pix[off])/255.0, float64(img.Pix[off+1])/255.0, float64(img.Pix[off+2])/255.0)
+}
+
+// GetPixelLab returns the CIE L*a*b* color of a pixel in absolute image coordinates
+func GetPixelLab(img *image.RGBA, x, y int) (float64, float64, float64) {
+	off := img.PixOffset(x, y)
+	return RGBToLab(float64(img.Pix[off])/255.0, float64(img.Pix[off+1])/255.0, float64(img.Pix[off+2])/255.0)
+}
+
+// ImageToSVGB returns a new image where the R, G, B channels are replaced by 0, Saturation, Value
+func ImageToSVGB(img *image.RGBA) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		off := img.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x++ {
+			_, s, v := RGBToHSV(float64(img.Pix[off])/255.0, float64(img.Pix[off+1])/255.0, float64(img.Pix[off+2])/255.0)
+			dOff := dst.PixOffset(x, y)
+			dst.Pix[dOff] = 0
+			dst.Pix[dOff+1] = uint8(s * 255)
+			dst.Pix[dOff+2] = uint8(v * 255)
+			dst.Pix[dOff+3] = 255
+			off += 4
+		}
+	}
+	return dst
+}
+
+/* ******** Area Statistics ******** */
+
+// GetAreaHSV calculates the Hue (Median), Saturation (Mean), and Value (Mean) of an area.
+// Hue is [0, 360), Saturation is [0, 1], Value is [0, 1].
+func GetAreaHSV(img *image.RGBA, rect image.Rectangle) (float64, float64, float64) {
+	rect = rect.Intersect(img.Bounds())
+	hues := make([]float64, 0, rect.Dx()*rect.Dy())
+	var sumSat, sumVal float64
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			h, s, v := GetPixelHSV(img, x, y)
+			hues = append(hues, h)
+			sumSat += s
+			sumVal += v
+		}
+	}
+
+	if len(hues) == 0 {
+		return 0, 0, 0
+	}
+
+	sort.Float64s(hues)
+	var midH float64
+	mid := len(hues) / 2
+	if len(hues)%2 == 0 {
+		midH = (hues[mid-1] + hues[mid]) / 2
+	} else {
+		midH = hues[mid]
+	}
+
+	return midH, sumSat / float64(len(hues)), sumVal / float64(len(hues))
+}
+
+// GetAreaChannelStd calculates the average standard deviation across RGB channels of an area
+func GetAreaChannelStd(img *image.RGBA, rect image.Rectangle) float64 {
+	rect = rect.Intersect(img.Bounds())
+	var sum, sumSq [3]float64
+	count := float64(rect.Dx() * rect.Dy())
+	if count == 0 {
+		return 0
+	}
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		off := img.PixOffset(rect.Min.X, y)
+		for range rect.Dx() {
+			for c := range 3 {
+				v := float64(img.Pix[off+c])
+				sum[c] += v
+				sumSq[c] += v * v
+			}
+			off += 4
+		}
+	}
+
+	total := 0.0
+	for c := range 3 {
+		mean := sum[c] / count
+		total += math.Sqrt(max(0, sumSq[c]/count-mean*mean))
+	}
+	return total / 3.0
+}
+
+/* ******** Hue Statistics ******** */
+
+// HueDiff returns the smallest difference between two hues in degrees, in [0, 180]
+func HueDiff(h1, h2 float64) float64 {
+	diff := math.Abs(math.Mod(h1-h2, 360))
+	if diff > 180 {
+		diff = 360 - diff
+	}
+	return diff
+}
+
+// HueCircularStats calculates the circular mean of hues in degrees [0, 360),
+// and the mean resultant length R in [0, 1], where R close to 1 means the hues are concentrated.
+// Weights are optional; if provided, they must have the same length as hues.
+func HueCircularStats(hues []float64, weights []float64) (float64, float64) {
+	var sumSin, sumCos, sumW float64
+	for i, h := range hues {
+		w := 1.0
+		if weights != nil {
+			w = weights[i]
+		}
+		rad := h * math.Pi / 180.0
+		sumSin += w * math.Sin(rad)
+		sumCos += w * math.Cos(rad)
+		sumW += w
+	}
+	if sumW <= 0 {
+		return 0, 0
+	}
+	mean := math.Mod(math.Atan2(sumSin, sumCos)*180.0/math.Pi+360, 360)
+	return mean, math.Hypot(sumSin, sumCos) / sumW
+}
+
+// HueCircularStd returns the circular standard deviation in degrees for a mean resultant length
+func HueCircularStd(resultantLength float64) float64 {
+	if resultantLength <= 0 {
+		return math.Inf(1)
+	}
+	return math.Sqrt(-2*math.Log(min(1, resultantLength))) * 180.0 / math.Pi
+}
+
+// HueHistogram builds a normalized histogram of hues with the given number of bins over [0, 360).
+// Pixels with saturation below minSat or value below minVal are ignored, as their hue is unstable.
+func HueHistogram(img *image.RGBA, rect image.Rectangle, bins int, minSat, minVal float64) []float64 {
+	if bins <= 0 {
+		return nil
+	}
+	rect = rect.Intersect(img.Bounds())
+	hist := make([]float64, bins)
+	total := 0.0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			h, s, v := GetPixelHSV(img, x, y)
+			if s < minSat || v < minVal {
+				continue
+			}
+			hist[min(bins-1, int(h*float64(bins)/360.0))]++
+			total++
+		}
+	}
+	if total > 0 {
+		for i := range hist {
+			hist[i] /= total
+		}
+	}
+	return hist
+}
+
+// ClusterHues groups hues that are within maxDiff degrees of a cluster's first member.
+// Identical hues are only counted once, so that a common hue does not outweigh the others in the cluster mean.
+// Clusters are returned in order of first appearance.
+func ClusterHues(hues []float64, maxDiff float64) [][]float64 {
+	clusters := make([][]float64, 0)
+	seen := make(map[float64]bool, len(hues))
+	for _, h := range hues {
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+
+		found := false
+		for i := range clusters {
+			if HueDiff(h, clusters[i][0]) <= maxDiff {
+				clusters[i] = append(clusters[i], h)
+				found = true
+				break
+			}
+		}
+		if !found {
+			clusters = append(clusters, []float64{h})
+		}
+	}
+	return clusters
+}
+
+/* ******** Range Masks ******** */
+
+// BinaryImage is a w*h boolean image, stored row by row
+type BinaryImage struct {
+	W, H int
+	Pix  []bool
+}
+
+// NewBinaryImage creates an empty w*h binary image
+func NewBinaryImage(w, h int) *BinaryImage {
+	return &BinaryImage{W: w, H: h, Pix: make([]bool, w*h)}
+}
+
+// At reports whether pixel (x, y) is set, and false outside the image
+func (b *BinaryImage) At(x, y int) bool {
+	if x < 0 || y < 0 || x >= b.W || y >= b.H {
+		return false
+	}
+	return b.Pix[y*b.W+x]
+}
+
+// Count returns the number of set pixels
+func (b *BinaryImage) Count() int {
+	n := 0
+	for _, v := range b.Pix {
+		if v {
+			n++
+		}
+	}
+	return n
+}
+
+// HSVRange is an inclusive HSV range in OpenCV 8-bit units:
+// Hue [0, 180), Saturation [0, 255], Value [0, 255].
+// This is the same convention as the lower/upper of pipeline ColorMatch with method 40 (COLOR_BGR2HSV).
+type HSVRange struct {
+	Lower [3]int
+	Upper [3]int
+}
+
+// Contains reports whether a pixel (Hue[0, 360), Saturation[0, 1], Value[0, 1]) is within the range.
+// If Lower hue is greater than Upper hue, the range wraps around 180.
+func (r HSVRange) Contains(h, s, v float64) bool {
+	ch := int(math.Round(h/2)) % 180
+	cs, cv := int(math.Round(s*255)), int(math.Round(v*255))
+	if cs < r.Lower[1] || cs > r.Upper[1] || cv < r.Lower[2] || cv > r.Upper[2] {
+		return false
+	}
+	if r.Lower[0] <= r.Upper[0] {
+		return ch >= r.Lower[0] && ch <= r.Upper[0]
+	}
+	return ch >= r.Lower[0] || ch <= r.Upper[0]
+}
+
+// InRangeHSV returns a binary image of the area, where pixels within the HSV range are set.
+// Coordinates of the result are relative to rect.Min.
+func InRangeHSV(img *image.RGBA, rect image.Rectangle, r HSVRange) *BinaryImage {
+	rect = rect.Intersect(img.Bounds())
+	mask := NewBinaryImage(rect.Dx(), rect.Dy())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			h, s, v := GetPixelHSV(img, x, y)
+			mask.Pix[(y-rect.Min.Y)*mask.W+(x-rect.Min.X)] = r.Contains(h, s, v)
+		}
+	}
+	return mask
+}
+
+// InRangeRGB returns a binary image of the area, where pixels with every RGB channel
+// within [lower, upper] are set. Coordinates of the result are relative to rect.Min.
+func InRangeRGB(img *image.RGBA, rect image.Rectangle, lower, upper [3]uint8) *BinaryImage {
+	rect = rect.Intersect(img.Bounds())
+	mask := NewBinaryImage(rect.Dx(), rect.Dy())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		off := img.PixOffset(rect.Min.X, y)
+		for x := range rect.Dx() {
+			p := img.Pix[off : off+3]
+			mask.Pix[(y-rect.Min.Y)*mask.W+x] = p[0] >= lower[0] && p[0] <= upper[0] &&
+				p[1] >= lower[1] && p[1] <= upper[1] &&
+				p[2] >= lower[2] && p[2] <= upper[2]
+			off += 4
+		}
+	}
+	return mask
+}
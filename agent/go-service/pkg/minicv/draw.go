@@ -0,0 +1,229 @@
+// Copyright (c) 2026 Harry Huang
+package minicv
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+/* ******** Shapes ******** */
+
+// ImageDrawRect draws a rectangle outline on an RGBA image
+func ImageDrawRect(img *image.RGBA, rect image.Rectangle, c color.RGBA, thickness int) {
+	x1, y1, x2, y2 := rect.Min.X, rect.Min.Y, rect.Max.X-1, rect.Max.Y-1
+	ImageDrawPolygon(img, []image.Point{{x1, y1}, {x2, y1}, {x2, y2}, {x1, y2}}, c, thickness)
+}
+
+// ImageFillRect fills a rectangle on an RGBA image, blending by the alpha of the color
+func ImageFillRect(img *image.RGBA, rect image.Rectangle, c color.RGBA) {
+	if img == nil {
+		return
+	}
+	draw.Draw(img, rect.Intersect(img.Bounds()), &image.Uniform{C: c}, image.Point{}, draw.Over)
+}
+
+// ImageDrawPolygon draws a closed polygon outline on an RGBA image
+func ImageDrawPolygon(img *image.RGBA, points []image.Point, c color.RGBA, thickness int) {
+	ImageDrawPolyline(img, points, c, thickness)
+	if len(points) > 2 {
+		p, q := points[len(points)-1], points[0]
+		ImageDrawLine(img, p.X, p.Y, q.X, q.Y, c, thickness)
+	}
+}
+
+// ImageDrawPolyline draws connected line segments on an RGBA image
+func ImageDrawPolyline(img *image.RGBA, points []image.Point, c color.RGBA, thickness int) {
+	for i := 0; i+1 < len(points); i++ {
+		ImageDrawLine(img, points[i].X, points[i].Y, points[i+1].X, points[i+1].Y, c, thickness)
+	}
+}
+
+// ImageDrawArrow draws a line from (x1, y1) to (x2, y2) with an arrow head of the given length at (x2, y2)
+func ImageDrawArrow(img *image.RGBA, x1, y1, x2, y2 int, c color.RGBA, thickness, headLen int) {
+	ImageDrawLine(img, x1, y1, x2, y2, c, thickness)
+	angle := math.Atan2(float64(y2-y1), float64(x2-x1))
+	if x1 == x2 && y1 == y2 {
+		return
+	}
+	for _, side := range []float64{-1, 1} {
+		a := angle + math.Pi + side*math.Pi/6
+		hx := x2 + int(math.Round(float64(headLen)*math.Cos(a)))
+		hy := y2 + int(math.Round(float64(headLen)*math.Sin(a)))
+		ImageDrawLine(img, x2, y2, hx, hy, c, thickness)
+	}
+}
+
+/* ******** Text ******** */
+
+// Metrics of the embedded bitmap font (7x13, ASCII only)
+const (
+	DRAW_FONT_W       = 7
+	DRAW_FONT_H       = 13
+	DRAW_FONT_ASCENT  = 11
+	DRAW_LABEL_MARGIN = 2
+)
+
+// ImageMeasureText returns the size of text drawn by ImageDrawText at the given scale
+func ImageMeasureText(text string, scale int) (int, int) {
+	scale = max(1, scale)
+	return len([]rune(text)) * DRAW_FONT_W * scale, DRAW_FONT_H * scale
+}
+
+// ImageDrawText draws text with the embedded bitmap font, with (x, y) as the top-left corner.
+// Each font pixel is drawn as a scale*scale block. Non-ASCII characters are drawn as placeholders.
+func ImageDrawText(img *image.RGBA, x, y int, text string, c color.RGBA, scale int) {
+	if img == nil || text == "" {
+		return
+	}
+	scale = max(1, scale)
+	w, h := ImageMeasureText(text, 1)
+
+	glyphs := image.NewAlpha(image.Rect(0, 0, w, h))
+	drawer := &font.Drawer{
+		Dst:  glyphs,
+		Src:  image.Opaque,
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(0, DRAW_FONT_ASCENT),
+	}
+	drawer.DrawString(text)
+
+	b := img.Bounds()
+	for gy := range h {
+		for gx := range w {
+			if glyphs.AlphaAt(gx, gy).A < 128 {
+				continue
+			}
+			for sy := range scale {
+				for sx := range scale {
+					px, py := x+gx*scale+sx, y+gy*scale+sy
+					if image.Pt(px, py).In(b) {
+						img.SetRGBA(px, py, c)
+					}
+				}
+			}
+		}
+	}
+}
+
+// ImageDrawLabel draws text on a filled background box, with (x, y) as the top-left corner of the box
+func ImageDrawLabel(img *image.RGBA, x, y int, text string, fg, bg color.RGBA, scale int) {
+	w, h := ImageMeasureText(text, scale)
+	ImageFillRect(img, image.Rect(x, y, x+w+DRAW_LABEL_MARGIN*2, y+h+DRAW_LABEL_MARGIN*2), bg)
+	ImageDrawText(img, x+DRAW_LABEL_MARGIN, y+DRAW_LABEL_MARGIN, text, fg, scale)
+}
+
+/* ******** Heatmaps ******** */
+
+// HeatColor maps a value in [0, 1] to a blue-cyan-green-yellow-red color
+func HeatColor(v float64) color.RGBA {
+	v = min(1, max(0, v))
+	r := min(1, max(0, 1.5-math.Abs(4*v-3)))
+	g := min(1, max(0, 1.5-math.Abs(4*v-2)))
+	b := min(1, max(0, 1.5-math.Abs(4*v-1)))
+	return color.RGBA{uint8(r * 255), uint8(g * 255), uint8(b * 255), 255}
+}
+
+// ImageHeatmap renders a row-major w*h value map (e.g. an NCC score map) as a heatmap image.
+// Values are normalized to [lo, hi]; if lo >= hi, the min and max of the map are used.
+func ImageHeatmap(values []float64, w, h int, lo, hi float64) *image.RGBA {
+	if lo >= hi {
+		lo, hi = math.Inf(1), math.Inf(-1)
+		for _, v := range values {
+			lo, hi = min(lo, v), max(hi, v)
+		}
+	}
+	span := hi - lo
+	if span <= 0 {
+		span = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i, v := range values[:w*h] {
+		c := HeatColor((v - lo) / span)
+		off := (i/w)*dst.Stride + (i%w)*4
+		dst.Pix[off], dst.Pix[off+1], dst.Pix[off+2], dst.Pix[off+3] = c.R, c.G, c.B, 255
+	}
+	return dst
+}
+
+// ImageOverlay blends src onto a copy of base with its top-left corner at offset, using alpha in [0, 1]
+func ImageOverlay(base, src *image.RGBA, offset image.Point, alpha float64) *image.RGBA {
+	dst := image.NewRGBA(base.Bounds())
+	copy(dst.Pix, base.Pix)
+	mask := image.NewUniform(color.Alpha{uint8(min(1, max(0, alpha)) * 255)})
+	draw.DrawMask(dst, src.Bounds().Add(offset), src, src.Bounds().Min, mask, image.Point{}, draw.Over)
+	return dst
+}
+
+/* ******** Composites ******** */
+
+// ImageComposeGrid arranges images in a grid with the given number of columns,
+// separated by gap pixels and filled with bg. Each cell has the size of the largest image.
+func ImageComposeGrid(images []*image.RGBA, cols, gap int, bg color.RGBA) *image.RGBA {
+	if len(images) == 0 {
+		return image.NewRGBA(image.Rect(0, 0, 1, 1))
+	}
+	cols = max(1, min(cols, len(images)))
+	rows := (len(images) + cols - 1) / cols
+
+	cellW, cellH := 0, 0
+	for _, img := range images {
+		if img != nil {
+			cellW, cellH = max(cellW, img.Rect.Dx()), max(cellH, img.Rect.Dy())
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, cols*cellW+(cols-1)*gap, rows*cellH+(rows-1)*gap))
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{C: bg}, image.Point{}, draw.Src)
+	for i, img := range images {
+		if img == nil {
+			continue
+		}
+		x, y := (i%cols)*(cellW+gap), (i/cols)*(cellH+gap)
+		draw.Draw(dst, image.Rect(x, y, x+img.Rect.Dx(), y+img.Rect.Dy()), img, img.Rect.Min, draw.Src)
+	}
+	return dst
+}
+
+// ImageComposeHorizontal arranges images side by side, separated by gap pixels and filled with bg
+func ImageComposeHorizontal(images []*image.RGBA, gap int, bg color.RGBA) *image.RGBA {
+	return ImageComposeGrid(images, len(images), gap, bg)
+}
+
+/* ******** Dumping ******** */
+
+// ImageSavePNG encodes an image to a PNG file, creating parent directories as needed
+func ImageSavePNG(img image.Image, path string) error {
+	if img == nil {
+		return fmt.Errorf("nil image")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ImageDumpPNG saves an image as "<dir>/<name>_<timestamp>.png" and returns the path
+func ImageDumpPNG(img image.Image, dir, name string) (string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("%s_%s.png", name, time.Now().Format("20060102_150405.000")))
+	return path, ImageSavePNG(img, path)
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2026 Harry Huang
+package minicv
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+	"sync"
+)
+
+var fftTwiddleCache sync.Map // map[int][]complex128
+
+var (
+	fftSlots    = make(chan struct{}, FFT_MAX_CONCURRENCY)
+	fftBufPools sync.Map // map[int]*sync.Pool of *[]complex128
+)
+
+// getFFTBuffer returns a zeroed complex buffer of length n from the pool
+func getFFTBuffer(n int) *[]complex128 {
+	pool, _ := fftBufPools.LoadOrStore(n, &sync.Pool{
+		New: func() any {
+			buf := make([]complex128, n)
+			return &buf
+		},
+	})
+	buf := pool.(*sync.Pool).Get().(*[]complex128)
+	clear(*buf)
+	return buf
+}
+
+// putFFTBuffer returns a buffer obtained from getFFTBuffer to the pool
+func putFFTBuffer(buf *[]complex128) {
+	if pool, ok := fftBufPools.Load(len(*buf)); ok {
+		pool.(*sync.Pool).Put(buf)
+	}
+}
+
+// nextPow2 returns the smallest power of two that is >= n
+func nextPow2(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
+
+func getTwiddles(n int) []complex128 {
+	if v, ok := fftTwiddleCache.Load(n); ok {
+		return v.([]complex128)
+	}
+	tw := make([]complex128, n/2)
+	for k := range tw {
+		angle := -2 * math.Pi * float64(k) / float64(n)
+		tw[k] = complex(math.Cos(angle), math.Sin(angle))
+	}
+	fftTwiddleCache.Store(n, tw)
+	return tw
+}
+
+// fft1D performs an in-place iterative radix-2 FFT. len(a) must be a power of two.
+// The inverse transform is not normalized.
+func fft1D(a []complex128, inverse bool) {
+	n := len(a)
+	if n <= 1 {
+		return
+	}
+
+	// Bit-reversal permutation
+	shift := bits.UintSize - bits.Len(uint(n-1))
+	for i := range n {
+		j := int(bits.Reverse(uint(i)) >> shift)
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+
+	tw := getTwiddles(n)
+	for size := 2; size <= n; size <<= 1 {
+		half := size >> 1
+		step := n / size
+		for start := 0; start < n; start += size {
+			for k := range half {
+				w := tw[k*step]
+				if inverse {
+					w = cmplx.Conj(w)
+				}
+				u := a[start+k]
+				v := a[start+k+half] * w
+				a[start+k] = u + v
+				a[start+k+half] = u - v
+			}
+		}
+	}
+}
+
+// fft2D performs an in-place 2D FFT on a row-major w*h buffer. w and h must be powers of two.
+func fft2D(a []complex128, w, h int, inverse bool) {
+	parallelFor(h, func(y int) {
+		fft1D(a[y*w:(y+1)*w], inverse)
+	})
+	parallelFor(w, func(x int) {
+		col := make([]complex128, h)
+		for y := range h {
+			col[y] = a[y*w+x]
+		}
+		fft1D(col, inverse)
+		for y := range h {
+			a[y*w+x] = col[y]
+		}
+	})
+}
+
+// crossCorrelateFFT computes, for every offset (u, v) with 0 <= u <= img.W-tpl.W and 0 <= v <= img.H-tpl.H,
+// the sum over all channels of img(u+i, v+j) * tpl(i, j).
+// The result is a row-major (img.W-tpl.W+1) * (img.H-tpl.H+1) buffer.
+func crossCorrelateFFT(img, tpl *FloatImage) ([]float64, int, int) {
+	outW, outH := img.W-tpl.W+1, img.H-tpl.H+1
+	if outW <= 0 || outH <= 0 || len(img.Channels) != len(tpl.Channels) {
+		return nil, 0, 0
+	}
+
+	fftSlots <- struct{}{}
+	defer func() { <-fftSlots }()
+
+	pw, ph := nextPow2(img.W), nextPow2(img.H)
+	accBuf, bufBuf := getFFTBuffer(pw*ph), getFFTBuffer(pw*ph)
+	defer putFFTBuffer(accBuf)
+	defer putFFTBuffer(bufBuf)
+	acc, buf := *accBuf, *bufBuf
+
+	for c := range img.Channels {
+		// Pack the real image channel and the real template channel into one complex signal
+		clear(buf)
+		for y := range img.H {
+			row := img.Channels[c][y*img.W : (y+1)*img.W]
+			for x, v := range row {
+				buf[y*pw+x] = complex(v, 0)
+			}
+		}
+		for y := range tpl.H {
+			row := tpl.Channels[c][y*tpl.W : (y+1)*tpl.W]
+			for x, v := range row {
+				buf[y*pw+x] += complex(0, v)
+			}
+		}
+		fft2D(buf, pw, ph, false)
+
+		// Separate both spectra using conjugate symmetry, then accumulate I * conj(T)
+		parallelFor(ph, func(ky int) {
+			nky := (ph - ky) % ph
+			for kx := range pw {
+				nkx := (pw - kx) % pw
+				z := buf[ky*pw+kx]
+				zn := cmplx.Conj(buf[nky*pw+nkx])
+				spI := (z + zn) * 0.5
+				spT := (z - zn) * complex(0, -0.5)
+				acc[ky*pw+kx] += spI * cmplx.Conj(spT)
+			}
+		})
+	}
+
+	fft2D(acc, pw, ph, true)
+
+	norm := 1.0 / float64(pw*ph)
+	out := make([]float64, outW*outH)
+	for v := range outH {
+		for u := range outW {
+			out[v*outW+u] = real(acc[v*pw+u]) * norm
+		}
+	}
+	return out, outW, outH
+}
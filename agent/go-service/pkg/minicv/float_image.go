@@ -0,0 +1,50 @@
+// Copyright (c) 2026 Harry Huang
+package minicv
+
+import "image"
+
+// FloatImage is a packed planar float buffer of an image.
+// Each channel is stored as a separate W*H plane, row by row.
+type FloatImage struct {
+	W, H     int
+	Channels [][]float64
+}
+
+// NewFloatImageRGB packs the RGB channels of an image into planar float buffers
+func NewFloatImageRGB(img *image.RGBA) *FloatImage {
+	return newFloatImageRGBInRect(img, image.Rect(0, 0, img.Rect.Dx(), img.Rect.Dy()))
+}
+
+// NewFloatImageGray packs the luma of an image into a single float buffer (ITU-R BT.601 weights)
+func NewFloatImageGray(img *image.RGBA) *FloatImage {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	gray := make([]float64, w*h)
+	ipx, is := img.Pix, img.Stride
+	for y := range h {
+		off := y * is
+		row := gray[y*w : (y+1)*w]
+		for x := range w {
+			row[x] = 0.299*float64(ipx[off]) + 0.587*float64(ipx[off+1]) + 0.114*float64(ipx[off+2])
+			off += 4
+		}
+	}
+	return &FloatImage{W: w, H: h, Channels: [][]float64{gray}}
+}
+
+// newFloatImageRGBInRect packs the RGB channels of a sub-rectangle (relative to img.Rect.Min)
+func newFloatImageRGBInRect(img *image.RGBA, r image.Rectangle) *FloatImage {
+	w, h := r.Dx(), r.Dy()
+	ch := [][]float64{make([]float64, w*h), make([]float64, w*h), make([]float64, w*h)}
+	ipx, is := img.Pix, img.Stride
+	for y := range h {
+		off := (r.Min.Y+y)*is + r.Min.X*4
+		base := y * w
+		for x := range w {
+			ch[0][base+x] = float64(ipx[off])
+			ch[1][base+x] = float64(ipx[off+1])
+			ch[2][base+x] = float64(ipx[off+2])
+			off += 4
+		}
+	}
+	return &FloatImage{W: w, H: h, Channels: ch}
+}
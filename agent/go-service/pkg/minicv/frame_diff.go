@@ -0,0 +1,266 @@
+// Copyright (c) 2026 Harry Huang
+package minicv
+
+import (
+	"image"
+	"math/bits"
+	"time"
+)
+
+/* ******** Perceptual Hashes ******** */
+
+// ImageHash is a 64-bit perceptual hash of an image area
+type ImageHash uint64
+
+// Distance returns the Hamming distance between two hashes, in [0, 64]
+func (h ImageHash) Distance(other ImageHash) int {
+	return bits.OnesCount64(uint64(h ^ other))
+}
+
+// sampleGray downsamples an area to w*h luma values by box averaging
+func sampleGray(img *image.RGBA, rect image.Rectangle, w, h int) []float64 {
+	rect = rect.Intersect(img.Bounds())
+	out := make([]float64, w*h)
+	if rect.Empty() {
+		return out
+	}
+	for j := range h {
+		y0 := rect.Min.Y + j*rect.Dy()/h
+		y1 := max(y0+1, rect.Min.Y+(j+1)*rect.Dy()/h)
+		for i := range w {
+			x0 := rect.Min.X + i*rect.Dx()/w
+			x1 := max(x0+1, rect.Min.X+(i+1)*rect.Dx()/w)
+			sum, n := 0.0, 0
+			for y := y0; y < y1; y++ {
+				off := img.PixOffset(x0, y)
+				for range x1 - x0 {
+					sum += 0.299*float64(img.Pix[off]) + 0.587*float64(img.Pix[off+1]) + 0.114*float64(img.Pix[off+2])
+					n++
+					off += 4
+				}
+			}
+			out[j*w+i] = sum / float64(n)
+		}
+	}
+	return out
+}
+
+// AverageHash computes the average hash (aHash) of an area:
+// each bit tells whether a cell of the 8x8 downsampled luma is brighter than the mean
+func AverageHash(img *image.RGBA, rect image.Rectangle) ImageHash {
+	gray := sampleGray(img, rect, 8, 8)
+	mean := 0.0
+	for _, v := range gray {
+		mean += v
+	}
+	mean /= float64(len(gray))
+
+	var hash ImageHash
+	for i, v := range gray {
+		if v > mean {
+			hash |= 1 << i
+		}
+	}
+	return hash
+}
+
+// DifferenceHash computes the difference hash (dHash) of an area:
+// each bit tells whether a cell of the 9x8 downsampled luma is brighter than its right neighbour
+func DifferenceHash(img *image.RGBA, rect image.Rectangle) ImageHash {
+	gray := sampleGray(img, rect, 9, 8)
+	var hash ImageHash
+	for y := range 8 {
+		for x := range 8 {
+			if gray[y*9+x] > gray[y*9+x+1] {
+				hash |= 1 << (y*8 + x)
+			}
+		}
+	}
+	return hash
+}
+
+/* ******** Frame Differences ******** */
+
+// RegionDiff returns the mean absolute difference of RGB values between two images in an area, in [0, 1].
+// Every step-th pixel in each direction is sampled; step <= 1 samples all pixels.
+// Images of different sizes are compared in their common area.
+func RegionDiff(a, b *image.RGBA, rect image.Rectangle, step int) float64 {
+	rect = rect.Intersect(a.Bounds()).Intersect(b.Bounds())
+	if rect.Empty() {
+		return 0
+	}
+	step = max(1, step)
+
+	var sum uint64
+	n := 0
+	for y := rect.Min.Y; y < rect.Max.Y; y += step {
+		aOff, bOff := a.PixOffset(rect.Min.X, y), b.PixOffset(rect.Min.X, y)
+		for x := rect.Min.X; x < rect.Max.X; x += step {
+			for c := range 3 {
+				d := int(a.Pix[aOff+c]) - int(b.Pix[bOff+c])
+				if d < 0 {
+					d = -d
+				}
+				sum += uint64(d)
+			}
+			n++
+			aOff += step * 4
+			bOff += step * 4
+		}
+	}
+	return float64(sum) / float64(n*3*255)
+}
+
+// RegionChangedRatio returns the ratio of sampled pixels in an area whose max channel difference exceeds tolerance
+func RegionChangedRatio(a, b *image.RGBA, rect image.Rectangle, tolerance uint8, step int) float64 {
+	rect = rect.Intersect(a.Bounds()).Intersect(b.Bounds())
+	if rect.Empty() {
+		return 0
+	}
+	step = max(1, step)
+
+	changed, n := 0, 0
+	for y := rect.Min.Y; y < rect.Max.Y; y += step {
+		aOff, bOff := a.PixOffset(rect.Min.X, y), b.PixOffset(rect.Min.X, y)
+		for x := rect.Min.X; x < rect.Max.X; x += step {
+			maxD := 0
+			for c := range 3 {
+				d := int(a.Pix[aOff+c]) - int(b.Pix[bOff+c])
+				maxD = max(maxD, d, -d)
+			}
+			if maxD > int(tolerance) {
+				changed++
+			}
+			n++
+			aOff += step * 4
+			bOff += step * 4
+		}
+	}
+	return float64(changed) / float64(n)
+}
+
+/* ******** Stable Frame Detection ******** */
+
+// Default options of frame waiting
+const (
+	FRAME_WAIT_DEFAULT_INTERVAL      = 50 * time.Millisecond
+	FRAME_WAIT_DEFAULT_TIMEOUT       = 2000 * time.Millisecond
+	FRAME_WAIT_DEFAULT_THRESHOLD     = 0.01
+	FRAME_WAIT_DEFAULT_STABLE_FRAMES = 2
+	FRAME_WAIT_SAMPLE_STEP           = 2
+)
+
+// FrameWaitOptions controls frame polling. Zero values use the defaults.
+type FrameWaitOptions struct {
+	Rect         image.Rectangle // Area to watch; empty means the whole frame
+	Interval     time.Duration   // Delay between captures
+	Timeout      time.Duration   // Maximum total waiting time
+	Threshold    float64         // RegionDiff above which two frames are considered different
+	StableFrames int             // Consecutive unchanged frame pairs required for stability
+}
+
+func (o FrameWaitOptions) withDefaults() FrameWaitOptions {
+	if o.Interval <= 0 {
+		o.Interval = FRAME_WAIT_DEFAULT_INTERVAL
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = FRAME_WAIT_DEFAULT_TIMEOUT
+	}
+	if o.Threshold <= 0 {
+		o.Threshold = FRAME_WAIT_DEFAULT_THRESHOLD
+	}
+	if o.StableFrames <= 0 {
+		o.StableFrames = FRAME_WAIT_DEFAULT_STABLE_FRAMES
+	}
+	return o
+}
+
+// StableFrameDetector tracks consecutive frames and reports when an area stops changing
+type StableFrameDetector struct {
+	Rect         image.Rectangle
+	Threshold    float64
+	StableFrames int
+
+	last   *image.RGBA
+	stable int
+}
+
+// NewStableFrameDetector creates a detector from wait options
+func NewStableFrameDetector(opts FrameWaitOptions) *StableFrameDetector {
+	opts = opts.withDefaults()
+	return &StableFrameDetector{Rect: opts.Rect, Threshold: opts.Threshold, StableFrames: opts.StableFrames}
+}
+
+// Push feeds a new frame and reports whether the area has been stable for enough consecutive frames
+func (d *StableFrameDetector) Push(img *image.RGBA) bool {
+	if d.last != nil {
+		rect := d.Rect
+		if rect.Empty() {
+			rect = img.Bounds()
+		}
+		if RegionDiff(d.last, img, rect, FRAME_WAIT_SAMPLE_STEP) <= d.Threshold {
+			d.stable++
+		} else {
+			d.stable = 0
+		}
+	}
+	d.last = img
+	return d.stable >= d.StableFrames
+}
+
+// Last returns the most recent frame
+func (d *StableFrameDetector) Last() *image.RGBA {
+	return d.last
+}
+
+// FrameSource captures the current frame
+type FrameSource func() (*image.RGBA, error)
+
+// WaitFrameStable polls frames until the area is stable or the timeout expires.
+// Returns the last frame and whether stability was reached.
+func WaitFrameStable(capture FrameSource, opts FrameWaitOptions) (*image.RGBA, bool, error) {
+	opts = opts.withDefaults()
+	detector := NewStableFrameDetector(opts)
+	deadline := time.Now().Add(opts.Timeout)
+	for {
+		img, err := capture()
+		if err != nil {
+			return detector.Last(), false, err
+		}
+		if detector.Push(img) {
+			return img, true, nil
+		}
+		if time.Now().Add(opts.Interval).After(deadline) {
+			return img, false, nil
+		}
+		time.Sleep(opts.Interval)
+	}
+}
+
+// WaitFrameChanged polls frames until the area differs from the reference frame or the timeout expires.
+// If reference is nil, the first captured frame is used. Returns the last frame and whether a change was seen.
+func WaitFrameChanged(capture FrameSource, reference *image.RGBA, opts FrameWaitOptions) (*image.RGBA, bool, error) {
+	opts = opts.withDefaults()
+	deadline := time.Now().Add(opts.Timeout)
+	for {
+		img, err := capture()
+		if err != nil {
+			return reference, false, err
+		}
+		if reference == nil {
+			reference = img
+		} else {
+			rect := opts.Rect
+			if rect.Empty() {
+				rect = img.Bounds()
+			}
+			if RegionDiff(reference, img, rect, FRAME_WAIT_SAMPLE_STEP) > opts.Threshold {
+				return img, true, nil
+			}
+		}
+		if time.Now().Add(opts.Interval).After(deadline) {
+			return img, false, nil
+		}
+		time.Sleep(opts.Interval)
+	}
+}
@@ -2,6 +2,23 @@ package minicv
 
 import (
 	"image"
+	"math"
+)
+
+// Search areas are split into tiles of this many sampled rows for parallel evaluation
+const matchTileRows = 8
+
+// Sampling step of the sparse direct search
+const matchSparseStep = 3
+
+// FFT path selection: the FFT path is used when the estimated direct cost exceeds
+// FFT_COST_FACTOR times the estimated FFT cost, and the padded buffer is not too large.
+// Each cross-correlation holds two padded complex buffers (2 x 32MB at FFT_MAX_BUF_SIZE),
+// so at most FFT_MAX_CONCURRENCY of them run at once however many goroutines call in.
+const (
+	FFT_COST_FACTOR     = 4.0
+	FFT_MAX_BUF_SIZE    = 1 << 21
+	FFT_MAX_CONCURRENCY = 2
 )
 
 func subpixelOffset(neg, pos float64) float64 {
@@ -77,53 +94,45 @@ func MatchTemplateInArea(
 	tplStats StatsResult,
 	ax, ay, aw, ah int,
 ) (float64, float64, float64) {
-	iw, ih := img.Rect.Dx(), img.Rect.Dy()
 	tw, th := tpl.Rect.Dx(), tpl.Rect.Dy()
-
-	// Calculate search bounds for the top-left corner (x, y)
-	minX, minY := max(0, ax-tw/2), max(0, ay-th/2)
-	maxX, maxY := min(iw-tw, ax+aw-tw/2), min(ih-th, ay+ah-th/2)
-
-	if minX > maxX || minY > maxY {
+	minX, minY, maxX, maxY, ok := templateSearchBounds(img, tw, th, ax, ay, aw, ah)
+	if !ok {
 		return 0, 0, 0.0
 	}
 
-	type result struct {
-		x, y int
-		s    float64
+	// Large search areas are evaluated exhaustively in the frequency domain
+	if shouldUseFFT(maxX-minX+1, maxY-minY+1, tw, th, matchSparseStep, 1) {
+		if x, y, score, ok := matchTemplateFFT(img, imgIntArr, tpl, tplStats, minX, minY, maxX, maxY); ok {
+			return x, y, score
+		}
 	}
 
-	numWorkers, step := 4, 3
-	resChan := make(chan result, numWorkers)
-
-	for i := range numWorkers {
-		go func(id int) {
-			lx, ly, lm := 0, 0, -1.0
-			for y := minY + id*step; y <= maxY; y += numWorkers * step {
-				for x := minX; x <= maxX; x += step {
-					s := ComputeNCC(img, imgIntArr, tpl, tplStats, x, y)
-					if s > lm {
-						lm, lx, ly = s, x, y
-					}
-				}
-			}
-			resChan <- result{lx, ly, lm}
-		}(i)
+	score := func(x, y int) float64 {
+		return ComputeNCC(img, imgIntArr, tpl, tplStats, x, y)
 	}
+	return matchScoreInBounds(score, minX, minY, maxX, maxY)
+}
 
-	bc := result{minX, minY, -1.0}
-	for range numWorkers {
-		r := <-resChan
-		if r.s > bc.s {
-			bc = r
-		}
-	}
+// templateSearchBounds calculates the search bounds for the top-left corner (x, y)
+// such that the template center remains within (ax, ay, aw, ah)
+func templateSearchBounds(img *image.RGBA, tw, th, ax, ay, aw, ah int) (int, int, int, int, bool) {
+	iw, ih := img.Rect.Dx(), img.Rect.Dy()
+	minX, minY := max(0, ax-tw/2), max(0, ay-th/2)
+	maxX, maxY := min(iw-tw, ax+aw-tw/2), min(ih-th, ay+ah-th/2)
+	return minX, minY, maxX, maxY, minX <= maxX && minY <= maxY
+}
+
+// matchScoreInBounds runs a sparse tiled search, a dense refinement around the best position
+// and a subpixel estimation. Returns (x, y, score).
+func matchScoreInBounds(score func(x, y int) float64, minX, minY, maxX, maxY int) (float64, float64, float64) {
+	const step = matchSparseStep
+	fx, fy, fm := matchScoreTiled(score, minX, minY, maxX, maxY, step)
 
-	fm, fx, fy := bc.s, bc.x, bc.y
 	// Fine-tuning pass around the best result
-	for y := max(minY, bc.y-step+1); y <= min(maxY, bc.y+step-1); y++ {
-		for x := max(minX, bc.x-step+1); x <= min(maxX, bc.x+step-1); x++ {
-			s := ComputeNCC(img, imgIntArr, tpl, tplStats, x, y)
+	bx, by := fx, fy
+	for y := max(minY, by-step+1); y <= min(maxY, by+step-1); y++ {
+		for x := max(minX, bx-step+1); x <= min(maxX, bx+step-1); x++ {
+			s := score(x, y)
 			if s > fm {
 				fm, fx, fy = s, x, y
 			}
@@ -134,16 +143,16 @@ func MatchTemplateInArea(
 	leftNCC, rightNCC := fm, fm
 
 	if fy-1 >= minY {
-		upNCC = ComputeNCC(img, imgIntArr, tpl, tplStats, fx, fy-1)
+		upNCC = score(fx, fy-1)
 	}
 	if fy+1 <= maxY {
-		downNCC = ComputeNCC(img, imgIntArr, tpl, tplStats, fx, fy+1)
+		downNCC = score(fx, fy+1)
 	}
 	if fx-1 >= minX {
-		leftNCC = ComputeNCC(img, imgIntArr, tpl, tplStats, fx-1, fy)
+		leftNCC = score(fx-1, fy)
 	}
 	if fx+1 <= maxX {
-		rightNCC = ComputeNCC(img, imgIntArr, tpl, tplStats, fx+1, fy)
+		rightNCC = score(fx+1, fy)
 	}
 
 	subX := float64(fx) + subpixelOffset(leftNCC, rightNCC)
@@ -154,7 +163,8 @@ func MatchTemplateInArea(
 
 // MatchTemplateAnyScaleInArea performs iterative template matching over a scale range.
 // The number of iterations is defined by len(steps), and each element controls the
-// sampling count for that iteration.
+// sampling count for that iteration. Scaled templates are resampled from the nearest finer
+// level of the template's Gaussian pyramid, which is built once per call.
 // Returns (x, y, score, scale) for the best match found across all iterations.
 func MatchTemplateAnyScaleInArea(
 	img *image.RGBA,
@@ -162,6 +172,25 @@ func MatchTemplateAnyScaleInArea(
 	tpl *image.RGBA,
 	minScale, maxScale float64,
 	steps []int,
+) (float64, float64, float64, float64) {
+	octaves := newImageOctaves(tpl)
+	return matchAnyScale(func(scale float64) (float64, float64, float64, bool) {
+		scaledTpl := octaves.at(scale)
+		scaledStats := GetImageStats(scaledTpl)
+		if scaledStats.Std < 1e-12 {
+			return 0, 0, 0, false
+		}
+		x, y, score := MatchTemplate(img, imgIntArr, scaledTpl, scaledStats)
+		return x, y, score, true
+	}, minScale, maxScale, steps)
+}
+
+// matchAnyScale iteratively narrows a scale range around the best scale.
+// matchAt matches the template at one scale and returns (x, y, score, ok).
+func matchAnyScale(
+	matchAt func(scale float64) (float64, float64, float64, bool),
+	minScale, maxScale float64,
+	steps []int,
 ) (float64, float64, float64, float64) {
 	if minScale > maxScale {
 		minScale, maxScale = maxScale, minScale
@@ -207,13 +236,10 @@ func MatchTemplateAnyScaleInArea(
 				continue
 			}
 
-			scaledTpl := ImageScale(tpl, scale)
-			scaledStats := GetImageStats(scaledTpl)
-			if scaledStats.Std < 1e-12 {
+			x, y, score, ok := matchAt(scale)
+			if !ok {
 				continue
 			}
-
-			x, y, score := MatchTemplate(img, imgIntArr, scaledTpl, scaledStats)
 			if score > iterBestScore {
 				iterBestScore = score
 				iterBestX = x
@@ -260,3 +286,150 @@ func MatchTemplateAnyScaleInArea(
 
 	return bestX, bestY, bestScore, bestScale
 }
+
+// matchScoreTiled evaluates a score function on a sparse grid of top-left positions within [minX, maxX] x [minY, maxY].
+// Rows are grouped into tiles evaluated by the shared worker pool.
+func matchScoreTiled(score func(x, y int) float64, minX, minY, maxX, maxY, step int) (int, int, float64) {
+	type result struct {
+		x, y int
+		s    float64
+	}
+
+	rows := (maxY-minY)/step + 1
+	tiles := (rows + matchTileRows - 1) / matchTileRows
+	results := make([]result, tiles)
+
+	parallelFor(tiles, func(t int) {
+		lx, ly, lm := minX, minY, -1.0
+		yStart := minY + t*matchTileRows*step
+		yEnd := min(maxY, yStart+(matchTileRows-1)*step)
+		for y := yStart; y <= yEnd; y += step {
+			for x := minX; x <= maxX; x += step {
+				s := score(x, y)
+				if s > lm {
+					lm, lx, ly = s, x, y
+				}
+			}
+		}
+		results[t] = result{lx, ly, lm}
+	})
+
+	best := result{minX, minY, -1.0}
+	for _, r := range results {
+		if r.s > best.s {
+			best = r
+		}
+	}
+	return best.x, best.y, best.s
+}
+
+// shouldUseFFT estimates whether FFT cross-correlation is cheaper than the direct search
+// sampling every step-th position. correlations is the number of cross-correlations the FFT path needs.
+func shouldUseFFT(areaW, areaH, tw, th, step, correlations int) bool {
+	pw, ph := nextPow2(areaW+tw-1), nextPow2(areaH+th-1)
+	bufSize := pw * ph
+	if bufSize > FFT_MAX_BUF_SIZE {
+		return false
+	}
+	// Direct: sampled positions times RGB template pixels
+	directCost := float64((areaW/step+1)*(areaH/step+1)) * float64(tw*th*3)
+	// FFT: 3 forward (image and template channels packed in pairs) and 1 inverse 2D transforms
+	fftCost := 4.0 * float64(correlations) * float64(bufSize) * math.Log2(float64(bufSize))
+	return directCost > FFT_COST_FACTOR*fftCost
+}
+
+// matchTemplateFFT evaluates NCC at every top-left position within [minX, maxX] x [minY, maxY]
+// using FFT cross-correlation and the integral array for normalization.
+// Returns (x, y, score) with subpixel accuracy, and false if the path is not applicable.
+func matchTemplateFFT(
+	img *image.RGBA,
+	imgIntArr IntegralArray,
+	tpl *image.RGBA,
+	tplStats StatsResult,
+	minX, minY, maxX, maxY int,
+) (float64, float64, float64, bool) {
+	scores, outW, outH, ok := nccScoreMapFFT(img, imgIntArr, tpl, tplStats, minX, minY, maxX, maxY)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	x, y, score := bestInScoreMap(scores, outW, outH)
+	return float64(minX) + x, float64(minY) + y, score, true
+}
+
+// nccScoreMapFFT returns the row-major NCC score map of all top-left positions within [minX, maxX] x [minY, maxY],
+// and false if the FFT path is not applicable
+func nccScoreMapFFT(
+	img *image.RGBA,
+	imgIntArr IntegralArray,
+	tpl *image.RGBA,
+	tplStats StatsResult,
+	minX, minY, maxX, maxY int,
+) ([]float64, int, int, bool) {
+	tw, th := tpl.Rect.Dx(), tpl.Rect.Dy()
+	region := image.Rect(minX, minY, maxX+tw, maxY+th)
+
+	corr, outW, outH := crossCorrelateFFT(newFloatImageRGBInRect(img, region), NewFloatImageRGB(tpl))
+	if corr == nil {
+		return nil, 0, 0, false
+	}
+
+	count := float64(tw * th * 3)
+	scores := make([]float64, outW*outH)
+	parallelFor(outH, func(v int) {
+		for u := range outW {
+			imgStats := imgIntArr.GetAreaStats(minX+u, minY+v, tw, th)
+			stdProd := imgStats.Std * tplStats.Std
+			if stdProd < 1e-12 {
+				continue
+			}
+			scores[v*outW+u] = (corr[v*outW+u] - count*imgStats.Mean*tplStats.Mean) / stdProd
+		}
+	})
+	return scores, outW, outH, true
+}
+
+// nccScoreMap returns the row-major NCC score map of all top-left positions within [minX, maxX] x [minY, maxY],
+// using the FFT path when it is cheaper
+func nccScoreMap(
+	img *image.RGBA,
+	imgIntArr IntegralArray,
+	tpl *image.RGBA,
+	tplStats StatsResult,
+	minX, minY, maxX, maxY int,
+) ([]float64, int, int) {
+	tw, th := tpl.Rect.Dx(), tpl.Rect.Dy()
+	outW, outH := maxX-minX+1, maxY-minY+1
+	if shouldUseFFT(outW, outH, tw, th, 1, 1) {
+		if scores, w, h, ok := nccScoreMapFFT(img, imgIntArr, tpl, tplStats, minX, minY, maxX, maxY); ok {
+			return scores, w, h
+		}
+	}
+
+	scores := make([]float64, outW*outH)
+	parallelFor(outH, func(v int) {
+		for u := range outW {
+			scores[v*outW+u] = ComputeNCC(img, imgIntArr, tpl, tplStats, minX+u, minY+v)
+		}
+	})
+	return scores, outW, outH
+}
+
+// bestInScoreMap returns the subpixel position (relative to the map) and the score of the maximum
+func bestInScoreMap(scores []float64, w, h int) (float64, float64, float64) {
+	bu, bv, bm := 0, 0, -1.0
+	for i, s := range scores {
+		if s > bm {
+			bm, bu, bv = s, i%w, i/w
+		}
+	}
+
+	at := func(u, v int) float64 {
+		if u < 0 || v < 0 || u >= w || v >= h {
+			return bm
+		}
+		return scores[v*w+u]
+	}
+	subX := float64(bu) + subpixelOffset(at(bu-1, bv), at(bu+1, bv))
+	subY := float64(bv) + subpixelOffset(at(bu, bv-1), at(bu, bv+1))
+	return subX, subY, bm
+}
@@ -0,0 +1,339 @@
+// Copyright (c) 2026 Harry Huang
+package minicv
+
+import (
+	"image"
+	"math"
+)
+
+// TemplateMask marks which template pixels take part in masked matching.
+// Valid pixels are stored as horizontal runs so that masked area statistics
+// can be computed from the integral array with a few lookups per row.
+type TemplateMask struct {
+	W, H  int
+	Count int // Number of valid pixels
+	valid []bool
+	spans []maskSpan
+}
+
+// maskSpan is a run of valid pixels [x0, x1) in row y
+type maskSpan struct {
+	y, x0, x1 int
+}
+
+// NewTemplateMaskFunc builds a w*h mask where pixel (x, y) is valid if fn returns true
+func NewTemplateMaskFunc(w, h int, fn func(x, y int) bool) *TemplateMask {
+	valid := make([]bool, w*h)
+	for y := range h {
+		for x := range w {
+			valid[y*w+x] = fn(x, y)
+		}
+	}
+	return newTemplateMask(w, h, valid)
+}
+
+// NewTemplateMaskFromAlpha builds a mask from the alpha channel of a template,
+// where pixels with alpha >= threshold are valid
+func NewTemplateMaskFromAlpha(tpl image.Image, threshold uint8) *TemplateMask {
+	b := tpl.Bounds()
+	return NewTemplateMaskFunc(b.Dx(), b.Dy(), func(x, y int) bool {
+		_, _, _, a := tpl.At(b.Min.X+x, b.Min.Y+y).RGBA()
+		return a>>8 >= uint32(threshold)
+	})
+}
+
+// NewTemplateMaskFromImage builds a mask from a mask image, where non-black opaque pixels are valid
+func NewTemplateMaskFromImage(mask image.Image) *TemplateMask {
+	b := mask.Bounds()
+	return NewTemplateMaskFunc(b.Dx(), b.Dy(), func(x, y int) bool {
+		r, g, bl, a := mask.At(b.Min.X+x, b.Min.Y+y).RGBA()
+		return a != 0 && (r|g|bl) != 0
+	})
+}
+
+func newTemplateMask(w, h int, valid []bool) *TemplateMask {
+	m := &TemplateMask{W: w, H: h, valid: valid}
+	for y := range h {
+		row := valid[y*w : (y+1)*w]
+		for x := 0; x < w; {
+			if !row[x] {
+				x++
+				continue
+			}
+			x0 := x
+			for x < w && row[x] {
+				x++
+			}
+			m.spans = append(m.spans, maskSpan{y, x0, x})
+			m.Count += x - x0
+		}
+	}
+	return m
+}
+
+// Scale resizes the mask by the given factor using nearest-neighbour sampling.
+// The resulting size matches ImageScale for a template of the same size.
+func (m *TemplateMask) Scale(scale float64) *TemplateMask {
+	if scale <= 0 || scale == 1.0 {
+		return m
+	}
+	return m.Resize(max(1, int(float64(m.W)*scale)), max(1, int(float64(m.H)*scale)))
+}
+
+// Resize resizes the mask to w*h using nearest-neighbour sampling
+func (m *TemplateMask) Resize(w, h int) *TemplateMask {
+	if w == m.W && h == m.H {
+		return m
+	}
+	sx, sy := float64(m.W)/float64(w), float64(m.H)/float64(h)
+	return NewTemplateMaskFunc(w, h, func(x, y int) bool {
+		srcX := min(m.W-1, int((float64(x)+0.5)*sx))
+		srcY := min(m.H-1, int((float64(y)+0.5)*sy))
+		return m.valid[srcY*m.W+srcX]
+	})
+}
+
+// GetMaskedImageStats computes the mean and standard deviation (unnormalized) of the valid pixels in an image
+func GetMaskedImageStats(img *image.RGBA, mask *TemplateMask) StatsResult {
+	ipx, is := img.Pix, img.Stride
+
+	sum := 0.0
+	sumSq := 0.0
+
+	for _, sp := range mask.spans {
+		off := sp.y*is + sp.x0*4
+		for range sp.x1 - sp.x0 {
+			r, g, b := float64(ipx[off]), float64(ipx[off+1]), float64(ipx[off+2])
+			sum += r + g + b
+			sumSq += r*r + g*g + b*b
+			off += 4
+		}
+	}
+
+	return statsFromSums(sum, sumSq, float64(mask.Count*3))
+}
+
+// GetMaskedAreaStats returns the mean and standard deviation (unnormalized) of the pixels
+// covered by the valid pixels of a mask placed at (x, y), using the integral array
+func (ia *IntegralArray) GetMaskedAreaStats(x, y int, mask *TemplateMask) StatsResult {
+	stride := ia.W + 1
+	sum := 0.0
+	sumSq := 0.0
+	for _, sp := range mask.spans {
+		row1, row2 := (y+sp.y)*stride, (y+sp.y+1)*stride
+		idx11, idx12 := row1+x+sp.x0, row1+x+sp.x1
+		idx21, idx22 := row2+x+sp.x0, row2+x+sp.x1
+		sum += ia.Sum[idx22] - ia.Sum[idx12] - ia.Sum[idx21] + ia.Sum[idx11]
+		sumSq += ia.SumSq[idx22] - ia.SumSq[idx12] - ia.SumSq[idx21] + ia.SumSq[idx11]
+	}
+	return statsFromSums(sum, sumSq, float64(mask.Count*3))
+}
+
+func statsFromSums(sum, sumSq, count float64) StatsResult {
+	if count <= 0 {
+		return StatsResult{}
+	}
+	mean := sum / count
+	variance := sumSq - count*(mean*mean)
+	if variance < 1e-12 {
+		return StatsResult{Mean: mean, Std: 0}
+	}
+	return StatsResult{mean, math.Sqrt(variance)}
+}
+
+// ComputeMaskedNCC computes the normalized cross-correlation between a rectangle region in the haystack image
+// and a template image, considering only the valid pixels of the mask.
+// tplStats must be computed by GetMaskedImageStats with the same mask.
+func ComputeMaskedNCC(
+	img *image.RGBA,
+	imgIntArr IntegralArray,
+	tpl *image.RGBA,
+	mask *TemplateMask,
+	tplStats StatsResult,
+	ox, oy int,
+) float64 {
+	iw, ih := img.Rect.Dx(), img.Rect.Dy()
+	tw, th := tpl.Rect.Dx(), tpl.Rect.Dy()
+	if ox < 0 || oy < 0 || ox+tw > iw || oy+th > ih || mask.W != tw || mask.H != th {
+		return 0.0
+	}
+
+	ipx, is := img.Pix, img.Stride
+	tpx, ts := tpl.Pix, tpl.Stride
+
+	var dot uint64
+	for _, sp := range mask.spans {
+		iOff := (oy+sp.y)*is + (ox+sp.x0)*4
+		tOff := sp.y*ts + sp.x0*4
+		for range sp.x1 - sp.x0 {
+			dot += uint64(ipx[iOff]) * uint64(tpx[tOff])
+			dot += uint64(ipx[iOff+1]) * uint64(tpx[tOff+1])
+			dot += uint64(ipx[iOff+2]) * uint64(tpx[tOff+2])
+			iOff += 4
+			tOff += 4
+		}
+	}
+
+	count := float64(mask.Count * 3)
+	imgStats := imgIntArr.GetMaskedAreaStats(ox, oy, mask)
+	stdProd := imgStats.Std * tplStats.Std
+	if stdProd < 1e-12 {
+		return 0.0
+	}
+	return (float64(dot) - count*imgStats.Mean*tplStats.Mean) / stdProd
+}
+
+// MatchTemplateMasked performs masked template matching on the whole image,
+// returns (x, y, score) of the best match, where x and y are subpixel-accurate coordinates.
+func MatchTemplateMasked(
+	img *image.RGBA,
+	imgIntArr IntegralArray,
+	tpl *image.RGBA,
+	mask *TemplateMask,
+	tplStats StatsResult,
+) (float64, float64, float64) {
+	iw, ih := img.Rect.Dx(), img.Rect.Dy()
+	return MatchTemplateMaskedInArea(img, imgIntArr, tpl, mask, tplStats, 0, 0, iw, ih)
+}
+
+// MatchTemplateMaskedInArea performs masked template matching such that the center of the template
+// remains within the specified rectangle (ax, ay, aw, ah).
+// Returns (x, y, score) of the best match, where (x, y) is the top-left corner with subpixel accuracy.
+func MatchTemplateMaskedInArea(
+	img *image.RGBA,
+	imgIntArr IntegralArray,
+	tpl *image.RGBA,
+	mask *TemplateMask,
+	tplStats StatsResult,
+	ax, ay, aw, ah int,
+) (float64, float64, float64) {
+	tw, th := tpl.Rect.Dx(), tpl.Rect.Dy()
+	if mask.W != tw || mask.H != th || mask.Count == 0 {
+		return 0, 0, 0.0
+	}
+	minX, minY, maxX, maxY, ok := templateSearchBounds(img, tw, th, ax, ay, aw, ah)
+	if !ok {
+		return 0, 0, 0.0
+	}
+
+	if shouldUseFFT(maxX-minX+1, maxY-minY+1, tw, th, matchSparseStep, 1) {
+		if x, y, score, ok := matchTemplateMaskedFFT(img, imgIntArr, tpl, mask, tplStats, minX, minY, maxX, maxY); ok {
+			return x, y, score
+		}
+	}
+
+	score := func(x, y int) float64 {
+		return ComputeMaskedNCC(img, imgIntArr, tpl, mask, tplStats, x, y)
+	}
+	return matchScoreInBounds(score, minX, minY, maxX, maxY)
+}
+
+// MatchTemplateMaskedAnyScaleInArea performs iterative masked template matching over a scale range.
+// The mask is resized together with the template. See MatchTemplateAnyScaleInArea for the meaning of steps.
+// Returns (x, y, score, scale) for the best match found across all iterations.
+func MatchTemplateMaskedAnyScaleInArea(
+	img *image.RGBA,
+	imgIntArr IntegralArray,
+	tpl *image.RGBA,
+	mask *TemplateMask,
+	minScale, maxScale float64,
+	steps []int,
+) (float64, float64, float64, float64) {
+	octaves := newImageOctaves(tpl)
+	return matchAnyScale(func(scale float64) (float64, float64, float64, bool) {
+		scaledTpl := octaves.at(scale)
+		scaledMask := mask.Resize(scaledTpl.Rect.Dx(), scaledTpl.Rect.Dy())
+		scaledStats := GetMaskedImageStats(scaledTpl, scaledMask)
+		if scaledStats.Std < 1e-12 {
+			return 0, 0, 0, false
+		}
+		x, y, score := MatchTemplateMasked(img, imgIntArr, scaledTpl, scaledMask, scaledStats)
+		return x, y, score, true
+	}, minScale, maxScale, steps)
+}
+
+// matchTemplateMaskedFFT is the masked counterpart of matchTemplateFFT
+func matchTemplateMaskedFFT(
+	img *image.RGBA,
+	imgIntArr IntegralArray,
+	tpl *image.RGBA,
+	mask *TemplateMask,
+	tplStats StatsResult,
+	minX, minY, maxX, maxY int,
+) (float64, float64, float64, bool) {
+	scores, outW, outH, ok := maskedNCCScoreMapFFT(img, imgIntArr, tpl, mask, tplStats, minX, minY, maxX, maxY)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	x, y, score := bestInScoreMap(scores, outW, outH)
+	return float64(minX) + x, float64(minY) + y, score, true
+}
+
+// maskedNCCScoreMap returns the row-major masked NCC score map of all top-left positions
+// within [minX, maxX] x [minY, maxY], using the FFT path when it is cheaper
+func maskedNCCScoreMap(
+	img *image.RGBA,
+	imgIntArr IntegralArray,
+	tpl *image.RGBA,
+	mask *TemplateMask,
+	tplStats StatsResult,
+	minX, minY, maxX, maxY int,
+) ([]float64, int, int) {
+	tw, th := tpl.Rect.Dx(), tpl.Rect.Dy()
+	outW, outH := maxX-minX+1, maxY-minY+1
+	if shouldUseFFT(outW, outH, tw, th, 1, 1) {
+		if scores, w, h, ok := maskedNCCScoreMapFFT(img, imgIntArr, tpl, mask, tplStats, minX, minY, maxX, maxY); ok {
+			return scores, w, h
+		}
+	}
+
+	scores := make([]float64, outW*outH)
+	parallelFor(outH, func(v int) {
+		for u := range outW {
+			scores[v*outW+u] = ComputeMaskedNCC(img, imgIntArr, tpl, mask, tplStats, minX+u, minY+v)
+		}
+	})
+	return scores, outW, outH
+}
+
+// maskedNCCScoreMapFFT is the masked counterpart of nccScoreMapFFT.
+// Invalid template pixels are zeroed so that a single cross-correlation yields the masked dot product.
+func maskedNCCScoreMapFFT(
+	img *image.RGBA,
+	imgIntArr IntegralArray,
+	tpl *image.RGBA,
+	mask *TemplateMask,
+	tplStats StatsResult,
+	minX, minY, maxX, maxY int,
+) ([]float64, int, int, bool) {
+	tw, th := tpl.Rect.Dx(), tpl.Rect.Dy()
+	region := image.Rect(minX, minY, maxX+tw, maxY+th)
+
+	maskedTpl := NewFloatImageRGB(tpl)
+	for c := range maskedTpl.Channels {
+		for i, v := range mask.valid {
+			if !v {
+				maskedTpl.Channels[c][i] = 0
+			}
+		}
+	}
+
+	corr, outW, outH := crossCorrelateFFT(newFloatImageRGBInRect(img, region), maskedTpl)
+	if corr == nil {
+		return nil, 0, 0, false
+	}
+
+	count := float64(mask.Count * 3)
+	scores := make([]float64, outW*outH)
+	parallelFor(outH, func(v int) {
+		for u := range outW {
+			imgStats := imgIntArr.GetMaskedAreaStats(minX+u, minY+v, mask)
+			stdProd := imgStats.Std * tplStats.Std
+			if stdProd < 1e-12 {
+				continue
+			}
+			scores[v*outW+u] = (corr[v*outW+u] - count*imgStats.Mean*tplStats.Mean) / stdProd
+		}
+	})
+	return scores, outW, outH, true
+}
@@ -0,0 +1,421 @@
+// Copyright (c) 2026 Harry Huang
+package minicv
+
+import (
+	"image"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Maximum number of resampled (non-pyramid) scales kept by a pyramid; older ones are evicted
+const PYRAMID_SCALED_CACHE_SIZE = 2
+
+// Default options of coarse-to-fine pyramid matching
+const (
+	PYRAMID_DEFAULT_TOP_K         = 4
+	PYRAMID_DEFAULT_REFINE_RADIUS = 2
+	PYRAMID_MIN_TEMPLATE_SIZE     = 8
+)
+
+// PyramidLevel is one resolution of an image with its precomputed integral array
+type PyramidLevel struct {
+	Img      *image.RGBA
+	Integral IntegralArray
+	Scale    float64 // Scale relative to the base image
+}
+
+// Pyramid is a Gaussian image pyramid. Level 0 is the base image, and each following level
+// is blurred and downsampled by 2. Levels are built lazily, only as deep as requested scales need.
+// Arbitrary scales are resampled from the nearest finer level, and the most recent ones are cached.
+type Pyramid struct {
+	maxLevels int
+	minSize   int
+
+	mu     sync.Mutex
+	levels []*PyramidLevel
+	scaled []*PyramidLevel // Most recently used last, at most PYRAMID_SCALED_CACHE_SIZE entries
+}
+
+// NewPyramid creates a pyramid with at most maxLevels levels (including the base),
+// stopping before either side of a level becomes smaller than minSize
+func NewPyramid(img *image.RGBA, maxLevels, minSize int) *Pyramid {
+	return &Pyramid{
+		maxLevels: max(1, maxLevels),
+		minSize:   minSize,
+		levels:    []*PyramidLevel{{img, GetIntegralArray(img), 1.0}},
+	}
+}
+
+// NewPyramidFromLevel creates a pyramid whose base is an existing level (e.g. one returned by AtScale),
+// reusing its integral array. Scales of the new pyramid are relative to that level.
+func NewPyramidFromLevel(lv *PyramidLevel, maxLevels, minSize int) *Pyramid {
+	return &Pyramid{
+		maxLevels: max(1, maxLevels),
+		minSize:   minSize,
+		levels:    []*PyramidLevel{{lv.Img, lv.Integral, 1.0}},
+	}
+}
+
+// Base returns the base level of the pyramid
+func (p *Pyramid) Base() *PyramidLevel {
+	return p.levels[0]
+}
+
+// Level returns the l-th pyramid level (0 is the base), building it if needed.
+// Returns nil if the pyramid cannot go that deep.
+func (p *Pyramid) Level(l int) *PyramidLevel {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.levels) <= l {
+		if !p.grow() {
+			return nil
+		}
+	}
+	return p.levels[l]
+}
+
+// grow builds the next pyramid level, returning false if the pyramid cannot go deeper.
+// The caller must hold p.mu.
+func (p *Pyramid) grow() bool {
+	last := p.levels[len(p.levels)-1]
+	w, h := last.Img.Rect.Dx(), last.Img.Rect.Dy()
+	if len(p.levels) >= p.maxLevels || w/2 < p.minSize || h/2 < p.minSize {
+		return false
+	}
+	img := ImagePyrDown(last.Img)
+	p.levels = append(p.levels, &PyramidLevel{img, GetIntegralArray(img), last.Scale / 2})
+	return true
+}
+
+// AtScale returns the image at the given scale relative to the base image.
+// Exact pyramid levels are returned directly; other scales are resampled from the nearest finer level.
+func (p *Pyramid) AtScale(scale float64) *PyramidLevel {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Descend while the next level is still not coarser than the requested scale
+	src := p.levels[0]
+	for i := 1; src.Scale/2 >= scale-1e-9; i++ {
+		if i == len(p.levels) && !p.grow() {
+			break
+		}
+		src = p.levels[i]
+	}
+	if math.Abs(src.Scale-scale) < 1e-9 {
+		return src
+	}
+
+	for i, lv := range p.scaled {
+		if lv.Scale == scale {
+			p.scaled = append(append(p.scaled[:i:i], p.scaled[i+1:]...), lv)
+			return lv
+		}
+	}
+	img := ImageScale(src.Img, scale/src.Scale)
+	lv := &PyramidLevel{img, GetIntegralArray(img), scale}
+	if len(p.scaled) >= PYRAMID_SCALED_CACHE_SIZE {
+		p.scaled = p.scaled[len(p.scaled)-PYRAMID_SCALED_CACHE_SIZE+1:]
+	}
+	p.scaled = append(p.scaled, lv)
+	return lv
+}
+
+// ImagePyrScale scales an image the same way as Pyramid.AtScale does: it is halved with ImagePyrDown
+// while the result is not coarser than scale, and the remainder is resampled with ImageScale.
+// Unlike a Pyramid, no integral arrays are computed, so it is cheap for per-frame templates.
+func ImagePyrScale(img *image.RGBA, scale float64) *image.RGBA {
+	return newImageOctaves(img).at(scale)
+}
+
+// imageOctaves is the Gaussian pyramid of a template without integral arrays, built lazily.
+// It is not safe for concurrent use.
+type imageOctaves struct {
+	levels []*image.RGBA
+}
+
+func newImageOctaves(img *image.RGBA) *imageOctaves {
+	return &imageOctaves{levels: []*image.RGBA{img}}
+}
+
+// at returns the image at the given scale, resampled from the nearest finer octave
+func (o *imageOctaves) at(scale float64) *image.RGBA {
+	src, srcScale := o.levels[0], 1.0
+	for i := 1; srcScale/2 >= scale-1e-9; i++ {
+		if i == len(o.levels) {
+			if src.Rect.Dx() < 2 || src.Rect.Dy() < 2 {
+				break
+			}
+			o.levels = append(o.levels, ImagePyrDown(src))
+		}
+		src, srcScale = o.levels[i], srcScale/2
+	}
+	if math.Abs(srcScale-scale) < 1e-9 {
+		return src
+	}
+	return ImageScale(src, scale/srcScale)
+}
+
+// ImagePyrDown blurs an image with a 5x5 binomial kernel and downsamples it by 2
+func ImagePyrDown(img *image.RGBA) *image.RGBA {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	nw, nh := max(1, (w+1)/2), max(1, (h+1)/2)
+	kernel := [5]uint32{1, 4, 6, 4, 1}
+
+	// Horizontal pass with downsampling, then vertical pass with downsampling
+	tmp := make([]uint32, nw*h*3)
+	ipx, is := img.Pix, img.Stride
+	parallelFor(h, func(y int) {
+		row := y * is
+		for nx := range nw {
+			var acc [3]uint32
+			for k := range 5 {
+				x := min(w-1, max(0, nx*2+k-2))
+				off := row + x*4
+				acc[0] += kernel[k] * uint32(ipx[off])
+				acc[1] += kernel[k] * uint32(ipx[off+1])
+				acc[2] += kernel[k] * uint32(ipx[off+2])
+			}
+			base := (y*nw + nx) * 3
+			tmp[base], tmp[base+1], tmp[base+2] = acc[0], acc[1], acc[2]
+		}
+	})
+
+	dst := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	parallelFor(nh, func(ny int) {
+		for nx := range nw {
+			var acc [3]uint32
+			for k := range 5 {
+				y := min(h-1, max(0, ny*2+k-2))
+				base := (y*nw + nx) * 3
+				acc[0] += kernel[k] * tmp[base]
+				acc[1] += kernel[k] * tmp[base+1]
+				acc[2] += kernel[k] * tmp[base+2]
+			}
+			off := ny*dst.Stride + nx*4
+			dst.Pix[off] = uint8((acc[0] + 128) / 256)
+			dst.Pix[off+1] = uint8((acc[1] + 128) / 256)
+			dst.Pix[off+2] = uint8((acc[2] + 128) / 256)
+			dst.Pix[off+3] = 255
+		}
+	})
+	return dst
+}
+
+// PyramidMatchOptions controls coarse-to-fine pyramid matching.
+// Zero values use the defaults.
+type PyramidMatchOptions struct {
+	TopK         int // Number of candidates refined at each level
+	RefineRadius int // Search radius around each candidate at finer levels, in pixels of that level
+	MaxLevel     int // Coarsest level to start from; 0 means the coarsest level where the template is still usable
+}
+
+// pyramidCandidate is a top-left position at some pyramid level
+type pyramidCandidate struct {
+	x, y  int
+	score float64
+}
+
+// pyramidTemplate is the template at one pyramid level, with an optional mask
+type pyramidTemplate struct {
+	img   *image.RGBA
+	mask  *TemplateMask
+	stats StatsResult
+}
+
+func newPyramidTemplate(img *image.RGBA, mask *TemplateMask) (pyramidTemplate, bool) {
+	t := pyramidTemplate{img: img, mask: mask}
+	if mask == nil {
+		t.stats = GetImageStats(img)
+	} else {
+		if mask.Count == 0 {
+			return t, false
+		}
+		t.stats = GetMaskedImageStats(img, mask)
+	}
+	return t, t.stats.Std >= 1e-12
+}
+
+func (t *pyramidTemplate) score(lv *PyramidLevel, x, y int) float64 {
+	if t.mask == nil {
+		return ComputeNCC(lv.Img, lv.Integral, t.img, t.stats, x, y)
+	}
+	return ComputeMaskedNCC(lv.Img, lv.Integral, t.img, t.mask, t.stats, x, y)
+}
+
+func (t *pyramidTemplate) scoreMap(lv *PyramidLevel, maxX, maxY int) ([]float64, int, int) {
+	if t.mask == nil {
+		return nccScoreMap(lv.Img, lv.Integral, t.img, t.stats, 0, 0, maxX, maxY)
+	}
+	return maskedNCCScoreMap(lv.Img, lv.Integral, t.img, t.mask, t.stats, 0, 0, maxX, maxY)
+}
+
+// MatchTemplatePyramid performs coarse-to-fine template matching.
+// The template is downsampled with ImagePyrDown alongside the image pyramid. The best candidates found
+// exhaustively at the coarsest usable level are refined locally at each finer level.
+// Returns (x, y, score) of the best match at the base level, where (x, y) is the top-left corner with subpixel accuracy.
+func MatchTemplatePyramid(imgPyr *Pyramid, tpl *image.RGBA, opts PyramidMatchOptions) (float64, float64, float64) {
+	octaves := newImageOctaves(tpl)
+	return matchPyramid(imgPyr, func(l int) *image.RGBA {
+		return octaves.at(math.Ldexp(1, -l))
+	}, nil, opts)
+}
+
+// MatchTemplateMaskedPyramid is the masked counterpart of MatchTemplatePyramid.
+// The mask is resized to the template at each level.
+func MatchTemplateMaskedPyramid(imgPyr *Pyramid, tpl *image.RGBA, mask *TemplateMask, opts PyramidMatchOptions) (float64, float64, float64) {
+	octaves := newImageOctaves(tpl)
+	return matchPyramid(imgPyr, func(l int) *image.RGBA {
+		return octaves.at(math.Ldexp(1, -l))
+	}, mask, opts)
+}
+
+// MatchTemplateAnyScalePyramid performs iterative coarse-to-fine template matching over a scale range.
+// See MatchTemplateAnyScaleInArea for the meaning of steps. At each scale, the template for every level
+// is taken from the template's Gaussian pyramid, so that it is sampled the same way as the image pyramid.
+// Returns (x, y, score, scale) for the best match found across all iterations.
+func MatchTemplateAnyScalePyramid(
+	imgPyr *Pyramid,
+	tpl *image.RGBA,
+	minScale, maxScale float64,
+	steps []int,
+	opts PyramidMatchOptions,
+) (float64, float64, float64, float64) {
+	octaves := newImageOctaves(tpl)
+	return matchAnyScale(func(scale float64) (float64, float64, float64, bool) {
+		x, y, score := matchPyramid(imgPyr, func(l int) *image.RGBA {
+			return octaves.at(math.Ldexp(scale, -l))
+		}, nil, opts)
+		return x, y, score, score > 0
+	}, minScale, maxScale, steps)
+}
+
+// matchPyramid is the shared implementation of pyramid matching.
+// tplAt returns the template at level l; mask (optional) is the mask of the level-0 template.
+func matchPyramid(imgPyr *Pyramid, tplAt func(l int) *image.RGBA, mask *TemplateMask, opts PyramidMatchOptions) (float64, float64, float64) {
+	topK := opts.TopK
+	if topK <= 0 {
+		topK = PYRAMID_DEFAULT_TOP_K
+	}
+	radius := opts.RefineRadius
+	if radius <= 0 {
+		radius = PYRAMID_DEFAULT_REFINE_RADIUS
+	}
+
+	base, ok := newPyramidTemplate(tplAt(0), mask)
+	if !ok {
+		return 0, 0, 0.0
+	}
+
+	// Collect the templates down to the coarsest usable level
+	tpls := []pyramidTemplate{base}
+	for l := 1; opts.MaxLevel <= 0 || l <= opts.MaxLevel; l++ {
+		lv := imgPyr.Level(l)
+		if lv == nil {
+			break
+		}
+		img := tplAt(l)
+		tw, th := img.Rect.Dx(), img.Rect.Dy()
+		if tw < PYRAMID_MIN_TEMPLATE_SIZE || th < PYRAMID_MIN_TEMPLATE_SIZE ||
+			tw > lv.Img.Rect.Dx() || th > lv.Img.Rect.Dy() {
+			break
+		}
+		var m *TemplateMask
+		if mask != nil {
+			m = mask.Resize(tw, th)
+		}
+		t, ok := newPyramidTemplate(img, m)
+		if !ok {
+			break
+		}
+		tpls = append(tpls, t)
+	}
+
+	// Exhaustive search at the coarsest level
+	start := len(tpls) - 1
+	lv, t := imgPyr.Level(start), &tpls[start]
+	tw, th := t.img.Rect.Dx(), t.img.Rect.Dy()
+	maxX, maxY := lv.Img.Rect.Dx()-tw, lv.Img.Rect.Dy()-th
+	if maxX < 0 || maxY < 0 {
+		return 0, 0, 0.0
+	}
+	scores, outW, _ := t.scoreMap(lv, maxX, maxY)
+	candidates := topKPeaks(scores, outW, topK, max(1, min(tw, th)/2))
+
+	// Local refinement at finer levels
+	for l := start - 1; l >= 0; l-- {
+		lv, t = imgPyr.Level(l), &tpls[l]
+		maxX, maxY = lv.Img.Rect.Dx()-t.img.Rect.Dx(), lv.Img.Rect.Dy()-t.img.Rect.Dy()
+
+		refined := make([]pyramidCandidate, len(candidates))
+		parallelFor(len(candidates), func(i int) {
+			cx, cy := candidates[i].x*2, candidates[i].y*2
+			best := pyramidCandidate{cx, cy, -1.0}
+			for y := max(0, cy-radius); y <= min(maxY, cy+radius); y++ {
+				for x := max(0, cx-radius); x <= min(maxX, cx+radius); x++ {
+					if s := t.score(lv, x, y); s > best.score {
+						best = pyramidCandidate{x, y, s}
+					}
+				}
+			}
+			refined[i] = best
+		})
+		sort.Slice(refined, func(i, j int) bool { return refined[i].score > refined[j].score })
+		candidates = refined
+	}
+
+	if len(candidates) == 0 {
+		return 0, 0, 0.0
+	}
+
+	// Subpixel estimation at the base level
+	best := candidates[0]
+	lv, t = imgPyr.Base(), &tpls[0]
+	maxX, maxY = lv.Img.Rect.Dx()-t.img.Rect.Dx(), lv.Img.Rect.Dy()-t.img.Rect.Dy()
+	at := func(x, y int) float64 {
+		if x < 0 || y < 0 || x > maxX || y > maxY {
+			return best.score
+		}
+		return t.score(lv, x, y)
+	}
+	subX := float64(best.x) + subpixelOffset(at(best.x-1, best.y), at(best.x+1, best.y))
+	subY := float64(best.y) + subpixelOffset(at(best.x, best.y-1), at(best.x, best.y+1))
+	return subX, subY, best.score
+}
+
+// topKPeaks returns up to k positions with the highest scores in a score map,
+// such that any two positions are at least minDist apart (Chebyshev distance)
+func topKPeaks(scores []float64, w, k, minDist int) []pyramidCandidate {
+	peaks := make([]pyramidCandidate, 0, k)
+	for len(peaks) < k {
+		best := -1
+		for idx, s := range scores {
+			if best >= 0 && s <= scores[best] {
+				continue
+			}
+			x, y := idx%w, idx/w
+			suppressed := false
+			for _, p := range peaks {
+				if max(absInt(p.x-x), absInt(p.y-y)) < minDist {
+					suppressed = true
+					break
+				}
+			}
+			if !suppressed {
+				best = idx
+			}
+		}
+		if best < 0 {
+			break
+		}
+		peaks = append(peaks, pyramidCandidate{best % w, best / w, scores[best]})
+	}
+	return peaks
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
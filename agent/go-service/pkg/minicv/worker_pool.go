@@ -0,0 +1,55 @@
+// Copyright (c) 2026 Harry Huang
+package minicv
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// workerTokens bounds the number of helper goroutines used by all minicv parallel loops
+var workerTokens = make(chan struct{}, runtime.GOMAXPROCS(0))
+
+// parallelFor calls fn(i) for every i in [0, n).
+// The calling goroutine always takes part, and helpers are only started while pool tokens are free,
+// so nested or concurrent calls never block on each other and the total CPU usage stays bounded.
+func parallelFor(n int, fn func(i int)) {
+	if n <= 0 {
+		return
+	}
+	if n == 1 {
+		fn(0)
+		return
+	}
+
+	tokens := workerTokens
+
+	var next atomic.Int64
+	work := func() {
+		for {
+			i := int(next.Add(1) - 1)
+			if i >= n {
+				return
+			}
+			fn(i)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for range n - 1 {
+		select {
+		case tokens <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-tokens }()
+				work()
+			}()
+			continue
+		default:
+		}
+		break
+	}
+	work()
+	wg.Wait()
+}
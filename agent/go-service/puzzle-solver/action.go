@@ -2,7 +2,6 @@ package puzzle
 
 import (
 	"encoding/json"
-	"time"
 
 	"github.com/MaaXYZ/maa-framework-go/v4"
 	"github.com/rs/zerolog/log"
@@ -46,25 +45,29 @@ func doPlace(ctx *maa.Context, bd *BoardDesc, p Placement, isDryRun bool) {
 
 	// 3. Execution sequence
 	aw := NewActionWrapper(ctx.GetTasker().GetController())
+	board := getBoardRect()
 	aw.TouchUpSync(100)
 	aw.TouchDownSync(0, startX, startY, 100)
-	aw.TouchMoveSync(0, endX, endY, 250)
+	aw.TouchMoveSync(0, endX, endY, 100)
+	aw.WaitStableSync(board, SCREEN_STABLE_TIMEOUT)
 
 	// 4. Rotation
 	// Mapping: 0->0, 1->3, 2->2, 3->1
 	rotTimes := (4 - p.Rotation) % 4
 	for range rotTimes {
-		aw.TypeKeySync(82, 250) // R key
+		aw.TypeKeySync(82, 50) // R key
+		aw.WaitStableSync(board, SCREEN_STABLE_TIMEOUT)
 	}
 
 	// 5. Complete
 	if isDryRun {
 		// In dry run mode, just return the piece to the thumbnail area
-		time.Sleep(1000 * time.Millisecond)
-		aw.TouchMoveSync(0, startX, startY, 250)
+		aw.TouchMoveSync(0, startX, startY, 100)
 	}
 
 	aw.TouchUpSync(100)
+	// Wait for the placement (or the return of the piece) to settle before the next one
+	aw.WaitStableSync(board, SCREEN_STABLE_TIMEOUT)
 }
 
 func doResetCursor(ctx *maa.Context) {
@@ -134,7 +137,6 @@ func (a *Action) Run(ctx *maa.Context, arg *maa.CustomActionArg) bool {
 	// Execute the solution steps (placements)
 	for _, p := range placements {
 		doPlace(ctx, &boardDesc, p, isDryRun)
-		time.Sleep(250 * time.Millisecond)
 	}
 	doResetCursor(ctx)
 	log.Info().Msg("Finished PuzzleSolver action")
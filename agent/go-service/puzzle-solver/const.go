@@ -1,6 +1,8 @@
 // Copyright (c) 2026 Harry Huang
 package puzzle
 
+import "time"
+
 const (
 	WORK_W = 1280
 	WORK_H = 720
@@ -63,3 +65,9 @@ var (
 	TAB_W   = 0.029 * float64(WORK_W)
 	TAB_H   = 0.029 * float64(WORK_H)
 )
+
+// Screen waiting parameters
+const (
+	TAB_SWITCH_TIMEOUT    = 500 * time.Millisecond
+	SCREEN_STABLE_TIMEOUT = 1000 * time.Millisecond
+)
@@ -7,6 +7,8 @@ import (
 	"math"
 	"time"
 
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/framewait"
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/minicv"
 	"github.com/MaaXYZ/maa-framework-go/v4"
 	"github.com/rs/zerolog/log"
 )
@@ -51,16 +53,7 @@ func getPossibleHues(puzzles []*PuzzleDesc) []int {
 	for _, p := range puzzles {
 		hues = append(hues, p.Hue)
 	}
-	clusters := clusterHues(hues, PUZZLE_HUE_DIFF_GRT)
-
-	results := make([]int, 0, len(clusters))
-	for _, members := range clusters {
-		if len(members) == 0 {
-			continue
-		}
-		results = append(results, meanHue(members))
-	}
-	return results
+	return getPossibleHuesFrom(hues, PUZZLE_HUE_DIFF_GRT)
 }
 
 func getPossibleBoardSize(ctx *maa.Context, img image.Image) [2]int {
@@ -300,22 +293,22 @@ func doEnsureTab(ctx *maa.Context, img image.Image) image.Image {
 
 	var ctrl = ctx.GetTasker().GetController()
 
-	// If tab 1 brightness is not greater than tab 2, it's likely on tab 2
-	if val1 <= val2 {
-		log.Info().Msg("Tab 2 detected as active, switching back to Tab 1")
-		ctrl.PostClickKey(9) // Tab
-		time.Sleep(500 * time.Millisecond)
+	// If tab 1 brightness is greater than tab 2, it's already on tab 1 and the screenshot is still current
+	if val1 > val2 {
+		return img
 	}
 
-	// Then refresh screenshot
-	ctrl.PostScreencap().Wait()
-	newImg, err := ctrl.CacheImage()
-	if err != nil {
-		log.Error().
-			Err(err).
-			Msg("Failed to capture image")
-		return nil
-	}
+	log.Info().Msg("Tab 2 detected as active, switching back to Tab 1")
+	ctrl.PostClickKey(9).Wait() // Tab
+
+	// Wait for the tabs to switch, then for the page to settle
+	framewait.Changed(ctrl, minicv.ImageConvertRGBA(img), minicv.FrameWaitOptions{
+		Rect:    rect1.Union(rect2),
+		Timeout: TAB_SWITCH_TIMEOUT,
+	})
+
+	// Then refresh screenshot once the screen is stable
+	newImg, _ := framewait.Stable(ctrl, minicv.FrameWaitOptions{Timeout: SCREEN_STABLE_TIMEOUT})
 	if newImg == nil {
 		log.Error().Msg("Failed to capture image")
 		return nil
@@ -420,18 +413,21 @@ func doPreviewPuzzle(ctx *maa.Context, thumbX, thumbY int) *PuzzleDesc {
 	aw := NewActionWrapper(ctrl)
 	aw.TouchUpSync(100)
 	aw.TouchDownSync(0, startX, startY, 100)
-	aw.TouchMoveSync(0, endX, endY, 500)
-
-	// 2. Screenshot
-	ctrl.PostScreencap().Wait()
-	previewImg, err := ctrl.CacheImage()
-	if err != nil {
-		log.Error().
-			Err(err).
-			Msg("Failed to capture preview image")
-		aw.TouchUpSync(100)
-		return nil
-	}
+	aw.TouchMoveSync(0, endX, endY, 100)
+
+	// 2. Screenshot once the preview area is stable
+	extentW := (float64(PUZZLE_MAX_EXTENT_ONE_SIDE) + 0.5) * PUZZLE_W
+	extentH := (float64(PUZZLE_MAX_EXTENT_ONE_SIDE) + 0.5) * PUZZLE_H
+	previewRect := image.Rect(
+		int(PUZZLE_PREVIEW_MV_CENTER_X-extentW),
+		int(PUZZLE_PREVIEW_MV_CENTER_Y-extentH),
+		int(PUZZLE_PREVIEW_MV_CENTER_X+extentW),
+		int(PUZZLE_PREVIEW_MV_CENTER_Y+extentH),
+	)
+	previewImg, _ := framewait.Stable(ctrl, minicv.FrameWaitOptions{
+		Rect:    previewRect,
+		Timeout: SCREEN_STABLE_TIMEOUT,
+	})
 	if previewImg == nil {
 		log.Error().Msg("Failed to capture preview image")
 		aw.TouchUpSync(100)
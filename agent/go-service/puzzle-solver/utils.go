@@ -4,11 +4,11 @@ package puzzle
 import (
 	"encoding/json"
 	"image"
-	"image/color"
 	"math"
-	"sort"
 	"time"
 
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/framewait"
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/minicv"
 	"github.com/MaaXYZ/maa-framework-go/v4"
 	"github.com/rs/zerolog/log"
 )
@@ -82,114 +82,20 @@ func matchTemplateAll(ctx *maa.Context, img image.Image, template string, roi []
 
 // getAreaVariance calculates the average standard deviation across RGB channels
 func getAreaVariance(img image.Image, rect image.Rectangle) float64 {
-	var sumR, sumG, sumB float64
-	var count float64
-	for y := rect.Min.Y; y < rect.Max.Y; y++ {
-		for x := rect.Min.X; x < rect.Max.X; x++ {
-			r, g, b, _ := img.At(x, y).RGBA()
-			fr, fg, fb := float64(r>>8), float64(g>>8), float64(b>>8)
-			sumR += fr
-			sumG += fg
-			sumB += fb
-			count++
-		}
-	}
-	if count == 0 {
-		return 0
-	}
-	avgR := sumR / count
-	avgG := sumG / count
-	avgB := sumB / count
-
-	var varR, varG, varB float64
-	for y := rect.Min.Y; y < rect.Max.Y; y++ {
-		for x := rect.Min.X; x < rect.Max.X; x++ {
-			r, g, b, _ := img.At(x, y).RGBA()
-			fr, fg, fb := float64(r>>8), float64(g>>8), float64(b>>8)
-			varR += (fr - avgR) * (fr - avgR)
-			varG += (fg - avgG) * (fg - avgG)
-			varB += (fb - avgB) * (fb - avgB)
-		}
-	}
-	return (math.Sqrt(varR/count) + math.Sqrt(varG/count) + math.Sqrt(varB/count)) / 3.0
-}
-
-// rgbToHSV converts normalized RGB [0, 1] to HSV: Hue[0, 360), Saturation[0, 1], Value[0, 1]
-func rgbToHSV(fr, fg, fb float64) (float64, float64, float64) {
-	maxC := math.Max(fr, math.Max(fg, fb))
-	minC := math.Min(fr, math.Min(fg, fb))
-	delta := maxC - minC
-
-	// Value
-	v := maxC
-
-	// Saturation
-	s := 0.0
-	if maxC != 0 {
-		s = delta / maxC
-	}
-
-	// Hue
-	h := 0.0
-	if delta != 0 {
-		switch maxC {
-		case fr:
-			h = (fg - fb) / delta
-			if fg < fb {
-				h += 6
-			}
-		case fg:
-			h = (fb-fr)/delta + 2
-		default:
-			h = (fr-fg)/delta + 4
-		}
-		h *= 60
-	}
-
-	return h, s, v
+	return minicv.GetAreaChannelStd(minicv.ImageConvertRGBA(img), rect)
 }
 
 // getAreaHSV calculates the Hue (Median), Saturation (Mean), and Value (Mean) of an area.
 // Hue is [0, 360), Saturation is [0, 1], Value is [0, 1].
 func getAreaHSV(img image.Image, rect image.Rectangle) (float64, float64, float64) {
-	area := (rect.Max.X - rect.Min.X) * (rect.Max.Y - rect.Min.Y)
-	hues := make([]float64, 0, area)
-	var sumSat, sumVal float64
-
-	for y := rect.Min.Y; y < rect.Max.Y; y++ {
-		for x := rect.Min.X; x < rect.Max.X; x++ {
-			r, g, b, _ := img.At(x, y).RGBA()
-			fr, fg, fb := float64(r>>8)/255.0, float64(g>>8)/255.0, float64(b>>8)/255.0
-
-			h, s, v := rgbToHSV(fr, fg, fb)
-			hues = append(hues, h)
-			sumSat += s
-			sumVal += v
-		}
-	}
-
-	if len(hues) == 0 {
-		return 0, 0, 0
-	}
-
-	sort.Float64s(hues)
-	var midH float64
-	mid := len(hues) / 2
-	if len(hues)%2 == 0 {
-		midH = (hues[mid-1] + hues[mid]) / 2
-	} else {
-		midH = hues[mid]
-	}
-
-	return midH, sumSat / float64(len(hues)), sumVal / float64(len(hues))
+	return minicv.GetAreaHSV(minicv.ImageConvertRGBA(img), rect)
 }
 
-// getPixelHSV returns the Hue[0, 360), Saturation[0, 1], Value[0, 1] of a pixel
+// getPixelHSV returns the Hue[0, 360), Saturation[0, 1], Value[0, 1] of a pixel,
+// or zeros if targetHue >= 0 and the pixel hue differs from it by more than targetHueAllowance
 func getPixelHSV(img image.Image, x, y int, targetHue int, targetHueAllowance int) (float64, float64, float64) {
 	r, g, b, _ := img.At(x, y).RGBA()
-	fr, fg, fb := float64(r>>8)/255.0, float64(g>>8)/255.0, float64(b>>8)/255.0
-
-	h, s, v := rgbToHSV(fr, fg, fb)
+	h, s, v := minicv.RGBToHSV(float64(r>>8)/255.0, float64(g>>8)/255.0, float64(b>>8)/255.0)
 
 	if targetHue >= 0 {
 		if diffHue(int(h), targetHue) > targetHueAllowance {
@@ -201,78 +107,29 @@ func getPixelHSV(img image.Image, x, y int, targetHue int, targetHueAllowance in
 
 // getSVGBImage returns a new image where the R, G, B channels are replaced by 0, Saturation, Value.
 func getSVGBImage(img image.Image) image.Image {
-	bounds := img.Bounds()
-	newImg := image.NewRGBA(bounds)
-
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, _ := img.At(x, y).RGBA()
-			fr, fg, fb := float64(r>>8)/255.0, float64(g>>8)/255.0, float64(b>>8)/255.0
-
-			_, s, v := rgbToHSV(fr, fg, fb)
-
-			newImg.SetRGBA(x, y, color.RGBA{0, uint8(s * 255), uint8(v * 255), 255})
-		}
-	}
-	return newImg
+	return minicv.ImageToSVGB(minicv.ImageConvertRGBA(img))
 }
 
 // diffHue returns the smallest difference between two hues [0, 360)
 func diffHue(h1, h2 int) int {
-	diff := int(math.Abs(float64(h1 - h2)))
-	if diff > 180 {
-		diff = 360 - diff
-	}
-	return diff
+	return int(minicv.HueDiff(float64(h1), float64(h2)))
 }
 
-// meanHue calculates the circular mean of a slice of hues [0, 360)
-func meanHue(hues []int) int {
-	if len(hues) == 0 {
-		return 0
-	}
-	var sumSin, sumCos float64
+// getPossibleHuesFrom clusters hues that are close to each other within maxDiff,
+// and returns the circular mean of each cluster
+func getPossibleHuesFrom(hues []int, maxDiff int) []int {
+	values := make([]float64, 0, len(hues))
 	for _, h := range hues {
-		rad := float64(h) * math.Pi / 180.0
-		sumSin += math.Sin(rad)
-		sumCos += math.Cos(rad)
+		values = append(values, float64(h))
 	}
-	avgRad := math.Atan2(sumSin, sumCos)
-	avgDeg := avgRad * 180.0 / math.Pi
-	if avgDeg < 0 {
-		avgDeg += 360
-	}
-	return int(math.Round(avgDeg))
-}
 
-// clusterHues groups hues that are close to each other within maxDiff
-func clusterHues(hues []int, maxDiff int) map[int][]int {
-	clusters := make(map[int][]int)
-	processed := make(map[int]bool)
-
-	for _, h1 := range hues {
-		if processed[h1] {
-			continue
-		}
-
-		clusterID := h1
-		// Check if h1 belongs to an existing cluster
-		foundCluster := false
-		for center := range clusters {
-			if diffHue(h1, center) <= maxDiff {
-				clusterID = center
-				foundCluster = true
-				break
-			}
-		}
-
-		if !foundCluster {
-			clusters[clusterID] = []int{}
-		}
-		clusters[clusterID] = append(clusters[clusterID], h1)
-		processed[h1] = true
+	clusters := minicv.ClusterHues(values, float64(maxDiff))
+	results := make([]int, 0, len(clusters))
+	for _, members := range clusters {
+		mean, _ := minicv.HueCircularStats(members, nil)
+		results = append(results, int(math.Round(mean))%360)
 	}
-	return clusters
+	return results
 }
 
 /* ******** Coordinate Conversions ******** */
@@ -298,9 +155,21 @@ func convertBoardCoordToLTCoord(bx, by int, totalW, totalH int) (int, int) {
 	return int(ltX), int(ltY)
 }
 
+// getBoardRect returns the screen area of the board
+func getBoardRect() image.Rectangle {
+	return image.Rect(
+		int(BOARD_X_LOWER_BOUND),
+		int(BOARD_Y_LOWER_BOUND),
+		int(BOARD_X_UPPER_BOUND),
+		int(BOARD_Y_UPPER_BOUND),
+	)
+}
+
 /* ******** Actions ******** */
 
-// ActionWrapper provides synchronized touch/key operations with built-in delays
+// ActionWrapper provides synchronized touch/key operations with built-in delays.
+// The delays are input timings (the gap the game needs between a move, a press and a release
+// to register a drag); to wait for the screen to react to an operation, use WaitStableSync.
 type ActionWrapper struct {
 	ctrl *maa.Controller
 }
@@ -336,3 +205,11 @@ func (aw *ActionWrapper) TypeKeySync(keyCode int, delayMillis int) {
 	aw.ctrl.PostClickKey(int32(keyCode)).Wait()
 	time.Sleep(time.Duration(delayMillis) * time.Millisecond)
 }
+
+// WaitStableSync waits until the given screen area stops changing, for at most timeout
+func (aw *ActionWrapper) WaitStableSync(rect image.Rectangle, timeout time.Duration) {
+	framewait.Stable(aw.ctrl, minicv.FrameWaitOptions{
+		Rect:    rect,
+		Timeout: timeout,
+	})
+}
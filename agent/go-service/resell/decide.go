@@ -2,6 +2,7 @@ package resell
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/maafocus"
 	"github.com/MaaXYZ/maa-framework-go/v4"
@@ -35,36 +36,68 @@ func (a *ResellDecideAction) Run(ctx *maa.Context, arg *maa.CustomActionArg) boo
 	}
 
 	maxRecord := records[maxProfitIdx]
-	log.Info().Msgf("[Resell]最高利润商品: 第%d行第%d列，利润%d", maxRecord.Row, maxRecord.Col, maxRecord.Profit)
+	log.Info().Str("item", maxRecord.Item).Msgf("[Resell]最高利润商品: 第%d行第%d列，利润%d", maxRecord.Row, maxRecord.Col, maxRecord.Profit)
 	showMaxRecord := processMaxRecord(maxRecord)
+	trendNote := recordPriceHistory(records, maxRecord)
 
 	if maxRecord.Profit >= MinimumProfit {
-		log.Info().Msgf("[Resell]利润达标，准备购买第%d行第%d列（利润：%d）", showMaxRecord.Row, showMaxRecord.Col, showMaxRecord.Profit)
+		log.Info().Msgf("[Resell]利润达标，准备购买%s（利润：%d）", productLabel(showMaxRecord), showMaxRecord.Profit)
 		taskName := fmt.Sprintf("ResellSelectProductRow%dCol%d", maxRecord.Row, maxRecord.Col)
 		ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{{Name: taskName}})
 		return true
 	}
 	if overflowAmount > 0 {
-		log.Info().Msgf("[Resell]配额溢出：建议购买%d件，推荐第%d行第%d列（利润：%d）",
-			overflowAmount, showMaxRecord.Row, showMaxRecord.Col, showMaxRecord.Profit)
-		message := fmt.Sprintf("⚠️ 配额溢出提醒\n剩余配额明天将超出上限，建议购买%d件商品\n推荐购买: 第%d行第%d列 (最高利润: %d)",
-			overflowAmount, showMaxRecord.Row, showMaxRecord.Col, showMaxRecord.Profit)
+		log.Info().Msgf("[Resell]配额溢出：建议购买%d件，推荐%s（利润：%d）",
+			overflowAmount, productLabel(showMaxRecord), showMaxRecord.Profit)
+		message := fmt.Sprintf("⚠️ 配额溢出提醒\n剩余配额明天将超出上限，建议购买%d件商品\n推荐购买: %s (最高利润: %d)%s",
+			overflowAmount, productLabel(showMaxRecord), showMaxRecord.Profit, trendNote)
 		maafocus.NodeActionStarting(ctx, message)
 		ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{{Name: "ChangeNextRegionPrepare"}})
 		return true
 	}
 
-	log.Info().Msgf("[Resell]没有达到最低利润%d的商品，推荐第%d行第%d列（利润：%d）",
-		MinimumProfit, showMaxRecord.Row, showMaxRecord.Col, showMaxRecord.Profit)
+	log.Info().Msgf("[Resell]没有达到最低利润%d的商品，推荐%s（利润：%d）",
+		MinimumProfit, productLabel(showMaxRecord), showMaxRecord.Profit)
 	var message string
 	if MinimumProfit >= 999999 {
-		message = fmt.Sprintf("💡 已禁用自动购买/出售\n推荐购买: 第%d行第%d列 (利润: %d)",
-			showMaxRecord.Row, showMaxRecord.Col, showMaxRecord.Profit)
+		message = fmt.Sprintf("💡 已禁用自动购买/出售\n推荐购买: %s (利润: %d)%s",
+			productLabel(showMaxRecord), showMaxRecord.Profit, trendNote)
 	} else {
-		message = fmt.Sprintf("💡 没有达到最低利润的商品，建议把配额留至明天\n推荐购买: 第%d行第%d列 (利润: %d)",
-			showMaxRecord.Row, showMaxRecord.Col, showMaxRecord.Profit)
+		message = fmt.Sprintf("💡 没有达到最低利润的商品，建议把配额留至明天\n推荐购买: %s (利润: %d)%s",
+			productLabel(showMaxRecord), showMaxRecord.Profit, trendNote)
 	}
 	maafocus.NodeActionStarting(ctx, message)
 	ctx.OverrideNext(arg.CurrentTaskName, []maa.NextItem{{Name: "ChangeNextRegionPrepare"}})
 	return true
 }
+
+// recordPriceHistory 保存本次扫描的全部价格，并返回推荐商品的近期趋势说明（未识别商品名或历史不足两天时为空）
+func recordPriceHistory(records []ProfitRecord, maxRecord ProfitRecord) string {
+	now := time.Now()
+	region := getRegion()
+	history, err := appendPriceHistory(priceHistoryPath, observationsFromRecords(records, region, now), historySince(now))
+	if err != nil {
+		log.Warn().Err(err).Msg("[Resell]保存价格历史失败")
+		return ""
+	}
+	trend := computePriceTrend(history, region, maxRecord.Item)
+	if trend == nil || trend.Days < 2 {
+		return ""
+	}
+	log.Info().Str("region", region).Str("item", trend.Item).Int("days", trend.Days).
+		Floats64("dailyProfits", trend.DailyProfits).
+		Float64("dailyMean", trend.DailyMean).Float64("volatility", trend.Volatility).
+		Float64("movingAverage", trend.MovingAverage).Float64("latestDelta", trend.LatestDelta).
+		Msg("[Resell]推荐商品近期利润趋势")
+	return fmt.Sprintf("\n近%d天日均利润: %.0f (波动 ±%.0f)，近%d天滑动平均: %.0f，今日较日均 %+.0f",
+		trend.Days, trend.DailyMean, trend.Volatility,
+		min(trend.Days, priceTrendMovingDays), trend.MovingAverage, trend.LatestDelta)
+}
+
+// productLabel 商品的展示文本：格位，识别到商品名时附上商品名
+func productLabel(r ProfitRecord) string {
+	if r.Item == "" {
+		return fmt.Sprintf("第%d行第%d列", r.Row, r.Col)
+	}
+	return fmt.Sprintf("第%d行第%d列「%s」", r.Row, r.Col, r.Item)
+}
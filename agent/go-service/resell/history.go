@@ -0,0 +1,303 @@
+package resell
+
+import (
+	"bufio"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// 价格历史文件：每次扫描的每个商品一行（JSON Lines）；追加时会删去趋势窗口之前的记录
+const priceHistoryPath = "config/resell_price_history.jsonl"
+
+// 趋势统计的时间窗口（天）
+const priceTrendWindowDays = 7
+
+// 利润滑动平均的天数（取窗口内最近几天）
+const priceTrendMovingDays = 3
+
+var historyMu sync.Mutex
+
+// 旧版本在未识别商品名时以格位 "r<行>c<列>" 作为商品标识，读取时视为未识别
+var legacyPositionalItem = regexp.MustCompile(`^r\d+c\d+$`)
+
+// PriceObservation 一次扫描中观测到的一个商品的成本价与好友出售价
+type PriceObservation struct {
+	Time      time.Time `json:"time"`
+	Region    string    `json:"region"`
+	Item      string    `json:"item"` // 商品名；未识别时为空，只计入地区统计，不参与商品趋势
+	Row       int       `json:"row"`
+	Col       int       `json:"col"`
+	CostPrice int       `json:"cost_price"`
+	SalePrice int       `json:"sale_price"`
+	Profit    int       `json:"profit"`
+}
+
+// PriceTrend 某地区某商品在窗口内的利润趋势，按天聚合
+type PriceTrend struct {
+	Region        string
+	Item          string
+	Samples       int
+	Days          int
+	Latest        PriceObservation
+	DailyProfits  []float64 // 有观测的各天平均利润，按日期升序
+	DailyMean     float64   // 窗口内各天平均利润的均值
+	Volatility    float64   // 窗口内各天平均利润的标准差
+	MovingAverage float64   // 最近 priceTrendMovingDays 个有观测的天的平均利润均值
+	LatestDelta   float64   // 最近一天的平均利润相对窗口均值的变化
+}
+
+// RegionSummary 某地区在窗口内每天最高利润的统计，用于比较哪个地区的差价更稳定
+type RegionSummary struct {
+	Region         string
+	Days           int
+	MeanBestProfit float64 // 每天最高利润的均值
+	Volatility     float64 // 每天最高利润的标准差
+	HitRate        float64 // 最高利润达到最低利润要求的天数占比
+	BestItem       string  // 成为当天最高利润商品次数最多的已识别商品，没有时为空
+}
+
+// observationsFromRecords 将本次扫描的利润记录转为带地区与时间的观测
+func observationsFromRecords(records []ProfitRecord, region string, now time.Time) []PriceObservation {
+	obs := make([]PriceObservation, 0, len(records))
+	for _, r := range records {
+		obs = append(obs, PriceObservation{
+			Time:      now,
+			Region:    region,
+			Item:      r.Item,
+			Row:       r.Row,
+			Col:       r.Col,
+			CostPrice: r.CostPrice,
+			SalePrice: r.SalePrice,
+			Profit:    r.Profit,
+		})
+	}
+	return obs
+}
+
+// appendPriceHistory 追加观测到历史文件，并返回 since 之后的全部观测（含本次）。
+// 文件中有 since 之前的记录或损坏的行时整体重写，只保留窗口内的观测，使文件大小保持在窗口范围内。
+func appendPriceHistory(path string, obs []PriceObservation, since time.Time) ([]PriceObservation, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	history, dropped, err := readPriceHistory(path, since)
+	if err != nil {
+		return nil, err
+	}
+	history = append(history, obs...)
+	if dropped > 0 {
+		return history, writePriceHistory(path, history)
+	}
+	if len(obs) == 0 {
+		return history, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for _, o := range obs {
+		if err := enc.Encode(o); err != nil {
+			return nil, err
+		}
+	}
+	return history, nil
+}
+
+// loadPriceHistory 读取 since 之后的观测；文件不存在时返回空，损坏的行跳过
+func loadPriceHistory(path string, since time.Time) ([]PriceObservation, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	obs, _, err := readPriceHistory(path, since)
+	return obs, err
+}
+
+// readPriceHistory 读取 since 之后的观测，并返回被舍弃（过期或损坏）的行数；调用方需持有 historyMu
+func readPriceHistory(path string, since time.Time) ([]PriceObservation, int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var obs []PriceObservation
+	dropped := 0
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var o PriceObservation
+		if err := json.Unmarshal(scanner.Bytes(), &o); err != nil {
+			log.Warn().Err(err).Int("line", line).Msg("[Resell]价格历史记录损坏，已跳过")
+			dropped++
+			continue
+		}
+		if o.Time.Before(since) {
+			dropped++
+			continue
+		}
+		if legacyPositionalItem.MatchString(o.Item) {
+			o.Item = ""
+		}
+		obs = append(obs, o)
+	}
+	return obs, dropped, scanner.Err()
+}
+
+// writePriceHistory 用给定观测重写历史文件（先写临时文件再替换）；调用方需持有 historyMu
+func writePriceHistory(path string, obs []PriceObservation) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, o := range obs {
+		if err := enc.Encode(o); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// historySince 趋势窗口的起始时间（窗口内含今天共 priceTrendWindowDays 天）
+func historySince(now time.Time) time.Time {
+	y, m, d := now.Date()
+	return time.Date(y, m, d-priceTrendWindowDays+1, 0, 0, 0, 0, now.Location())
+}
+
+// dayKey 观测所在的本地日期
+func dayKey(t time.Time) string {
+	return t.Local().Format("2006-01-02")
+}
+
+// meanStd 均值与总体标准差
+func meanStd(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)))
+}
+
+// computePriceTrend 计算某地区某商品的利润趋势；商品名为空或没有观测时返回 nil
+func computePriceTrend(obs []PriceObservation, region, item string) *PriceTrend {
+	if item == "" {
+		return nil
+	}
+	trend := &PriceTrend{Region: region, Item: item}
+	daySum := make(map[string]float64)
+	dayCount := make(map[string]int)
+	for _, o := range obs {
+		if o.Region != region || o.Item != item {
+			continue
+		}
+		trend.Samples++
+		if !o.Time.Before(trend.Latest.Time) {
+			trend.Latest = o
+		}
+		day := dayKey(o.Time)
+		daySum[day] += float64(o.Profit)
+		dayCount[day]++
+	}
+	if trend.Samples == 0 {
+		return nil
+	}
+	days := make([]string, 0, len(daySum))
+	for day := range daySum {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	daily := make([]float64, 0, len(days))
+	for _, day := range days {
+		daily = append(daily, daySum[day]/float64(dayCount[day]))
+	}
+	trend.Days = len(daily)
+	trend.DailyProfits = daily
+	trend.DailyMean, trend.Volatility = meanStd(daily)
+	trend.MovingAverage, _ = meanStd(daily[max(0, len(daily)-priceTrendMovingDays):])
+	trend.LatestDelta = daily[len(daily)-1] - trend.DailyMean
+	return trend
+}
+
+// summarizeRegions 按地区统计每天最高利润，按均值从高到低排序
+func summarizeRegions(obs []PriceObservation, minProfit int) []RegionSummary {
+	best := make(map[string]map[string]PriceObservation) // 地区 -> 日期 -> 当天最高利润的观测
+	for _, o := range obs {
+		days, ok := best[o.Region]
+		if !ok {
+			days = make(map[string]PriceObservation)
+			best[o.Region] = days
+		}
+		day := dayKey(o.Time)
+		if p, ok := days[day]; !ok || o.Profit > p.Profit {
+			days[day] = o
+		}
+	}
+
+	summaries := make([]RegionSummary, 0, len(best))
+	for region, days := range best {
+		values := make([]float64, 0, len(days))
+		itemDays := make(map[string]int)
+		hits := 0
+		for _, o := range days {
+			values = append(values, float64(o.Profit))
+			if o.Item != "" {
+				itemDays[o.Item]++
+			}
+			if o.Profit >= minProfit {
+				hits++
+			}
+		}
+		s := RegionSummary{Region: region, Days: len(days), HitRate: float64(hits) / float64(len(days))}
+		s.MeanBestProfit, s.Volatility = meanStd(values)
+		for item, n := range itemDays {
+			if n > itemDays[s.BestItem] || (n == itemDays[s.BestItem] && item < s.BestItem) {
+				s.BestItem = item
+			}
+		}
+		summaries = append(summaries, s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].MeanBestProfit != summaries[j].MeanBestProfit {
+			return summaries[i].MeanBestProfit > summaries[j].MeanBestProfit
+		}
+		return summaries[i].Region < summaries[j].Region
+	})
+	return summaries
+}
@@ -5,6 +5,7 @@ import "github.com/MaaXYZ/maa-framework-go/v4"
 var (
 	_ maa.CustomRecognitionRunner = &ResellCheckQuotaRecognition{}
 	_ maa.CustomActionRunner      = &ResellInitAction{}
+	_ maa.CustomActionRunner      = &ResellSetRegionAction{}
 	_ maa.CustomActionRunner      = &ResellCheckQuotaAction{}
 	_ maa.CustomActionRunner      = &ResellScanAction{}
 	_ maa.CustomActionRunner      = &ResellScanSkipEmptyAction{}
@@ -19,6 +20,7 @@ var (
 func Register() {
 	maa.AgentServerRegisterCustomRecognition("ResellCheckQuotaRecognition", &ResellCheckQuotaRecognition{})
 	maa.AgentServerRegisterCustomAction("ResellInitAction", &ResellInitAction{})
+	maa.AgentServerRegisterCustomAction("ResellSetRegionAction", &ResellSetRegionAction{})
 	maa.AgentServerRegisterCustomAction("ResellCheckQuotaAction", &ResellCheckQuotaAction{})
 	maa.AgentServerRegisterCustomAction("ResellScanAction", &ResellScanAction{})
 	maa.AgentServerRegisterCustomAction("ResellScanSkipEmptyAction", &ResellScanSkipEmptyAction{})
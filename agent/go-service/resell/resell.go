@@ -12,6 +12,7 @@ import (
 type ProfitRecord struct {
 	Row       int
 	Col       int
+	Item      string // 详情页识别到的商品名，未识别时为空
 	CostPrice int
 	SalePrice int
 	Profit    int
@@ -51,3 +52,19 @@ func (a *ResellInitAction) Run(ctx *maa.Context, arg *maa.CustomActionArg) bool
 	log.Info().Int("MinimumProfit", MinimumProfit).Msg("[Resell]参数已解析")
 	return true
 }
+
+// ResellSetRegionAction 记录当前所在地区（由各地区识别节点传入），用于按地区保存价格历史
+type ResellSetRegionAction struct{}
+
+func (a *ResellSetRegionAction) Run(ctx *maa.Context, arg *maa.CustomActionArg) bool {
+	var params struct {
+		Region string `json:"region"`
+	}
+	if err := json.Unmarshal([]byte(arg.CustomActionParam), &params); err != nil || params.Region == "" {
+		log.Error().Err(err).Str("param", arg.CustomActionParam).Msg("[Resell]无法解析地区参数")
+		return false
+	}
+	setRegion(params.Region)
+	log.Info().Str("region", params.Region).Msg("[Resell]当前地区已记录")
+	return true
+}
@@ -27,6 +27,7 @@ func (a *ResellScanAction) Run(ctx *maa.Context, arg *maa.CustomActionArg) bool
 		}
 	}
 	setScanPos(rowIdx, col)
+	setScanItemName("") // 新的一格，清除上一格的商品名
 	pricePipelineName := fmt.Sprintf("ResellROIProductRow%dCol%dPrice", rowIdx, col)
 	_ = ctx.OverridePipeline(map[string]any{
 		"ResellScanStart": map[string]any{
@@ -40,7 +41,7 @@ func (a *ResellScanAction) Run(ctx *maa.Context, arg *maa.CustomActionArg) bool
 	return true
 }
 
-// ResellScanCostAction Step2 确认成本价：从 RecognitionDetail 提取并存储详情页成本
+// ResellScanCostAction Step2 确认成本价：从 RecognitionDetail 提取并存储详情页成本，同时识别商品名
 type ResellScanCostAction struct{}
 
 func (a *ResellScanCostAction) Run(ctx *maa.Context, arg *maa.CustomActionArg) bool {
@@ -52,6 +53,13 @@ func (a *ResellScanCostAction) Run(ctx *maa.Context, arg *maa.CustomActionArg) b
 		}
 	}
 	if controller := ctx.GetTasker().GetController(); controller != nil {
+		// 识别成本价时的截图仍是详情页，直接用于识别商品名
+		if img, err := controller.CacheImage(); err != nil {
+			log.Warn().Err(err).Msg("[Resell]获取截图失败，跳过商品名识别")
+		} else if name := recognizeProductName(ctx, img); name != "" {
+			setScanItemName(name)
+			log.Info().Str("item", name).Msg("[Resell]详情页商品名已识别")
+		}
 		MoveMouseSafe(controller) // 为下一步 ViewFriendPrice 的 OCR 挪开鼠标
 	}
 	return true
@@ -80,7 +88,7 @@ func (a *ResellScanFriendPriceAction) Run(ctx *maa.Context, arg *maa.CustomActio
 		MoveMouseSafe(controller) // 为下一步返回按钮的识别挪开鼠标
 	}
 	profit := salePrice - costPrice
-	record := ProfitRecord{Row: rowIdx, Col: col, CostPrice: costPrice, SalePrice: salePrice, Profit: profit}
+	record := ProfitRecord{Row: rowIdx, Col: col, Item: getScanItemName(), CostPrice: costPrice, SalePrice: salePrice, Profit: profit}
 	appendRecord(record)
 	return true
 }
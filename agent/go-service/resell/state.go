@@ -8,8 +8,10 @@ var (
 	resellOverflow  int
 	resellMinProfit int
 	scanCostPrice   int
+	scanItemName    string
 	scanRow         int
 	scanCol         int
+	resellRegion    string
 )
 
 func getState() ([]ProfitRecord, int, int) {
@@ -56,6 +58,18 @@ func getScanCostPrice() int {
 	return scanCostPrice
 }
 
+func setScanItemName(v string) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	scanItemName = v
+}
+
+func getScanItemName() string {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return scanItemName
+}
+
 func setScanPos(row, col int) {
 	stateMu.Lock()
 	defer stateMu.Unlock()
@@ -67,3 +81,15 @@ func getScanPos() (int, int) {
 	defer stateMu.Unlock()
 	return scanRow, scanCol
 }
+
+func setRegion(v string) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	resellRegion = v
+}
+
+func getRegion() string {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return resellRegion
+}
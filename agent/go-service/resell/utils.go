@@ -4,14 +4,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"image"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/framewait"
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/maafocus"
+	"github.com/MaaXYZ/MaaEnd/agent/go-service/pkg/minicv"
 	"github.com/MaaXYZ/maa-framework-go/v4"
 	"github.com/rs/zerolog/log"
 )
 
+// 挪开鼠标后等待画面稳定的最长时间
+const mouseMoveSettleTimeout = 500 * time.Millisecond
+
 func extractNumbersFromText(text string) (int, bool) {
 	var digitsOnly []byte
 	for i := 0; i < len(text); i++ {
@@ -29,11 +38,11 @@ func extractNumbersFromText(text string) (int, bool) {
 
 // MoveMouseSafe moves the mouse to a safe location (10, 10) to avoid blocking OCR
 func MoveMouseSafe(controller *maa.Controller) {
-	// Use PostClick to move mouse to a safe corner
+	// Use PostTouchMove to move mouse to a safe corner
 	// We use (10, 10) to avoid title bar buttons or window borders
-	controller.PostTouchMove(0, 10, 10, 0)
-	// Small delay to ensure mouse move completes
-	time.Sleep(50 * time.Millisecond)
+	controller.PostTouchMove(0, 10, 10, 0).Wait()
+	// Wait for hover effects under the previous mouse position to fade out
+	framewait.Stable(controller, minicv.FrameWaitOptions{Timeout: mouseMoveSettleTimeout})
 }
 
 // ResellFinishAction - Finish Resell task custom action
@@ -41,9 +50,42 @@ type ResellFinishAction struct{}
 
 func (a *ResellFinishAction) Run(ctx *maa.Context, arg *maa.CustomActionArg) bool {
 	log.Info().Msg("[Resell]运行结束")
+	logRegionSummary(ctx)
 	return true
 }
 
+// logRegionSummary 按近期每天最高利润对各地区排序并展示，最低利润未启用时不统计达标率
+func logRegionSummary(ctx *maa.Context) {
+	history, err := loadPriceHistory(priceHistoryPath, historySince(time.Now()))
+	if err != nil {
+		log.Warn().Err(err).Msg("[Resell]读取价格历史失败")
+		return
+	}
+	_, _, minProfit := getState()
+	summaries := summarizeRegions(history, minProfit)
+	if len(summaries) == 0 {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "📈 近%d天各地区最高利润", priceTrendWindowDays)
+	for _, s := range summaries {
+		region := s.Region
+		if region == "" {
+			region = "未知地区"
+		}
+		log.Info().Str("region", region).Int("days", s.Days).Float64("meanBestProfit", s.MeanBestProfit).
+			Float64("volatility", s.Volatility).Float64("hitRate", s.HitRate).Str("bestItem", s.BestItem).Msg("[Resell]地区利润统计")
+		fmt.Fprintf(&b, "\n%s: 日均 %.0f (波动 ±%.0f, %d天)", region, s.MeanBestProfit, s.Volatility, s.Days)
+		if minProfit < 999999 {
+			fmt.Fprintf(&b, "，达标 %.0f%%", s.HitRate*100)
+		}
+		if s.BestItem != "" {
+			fmt.Fprintf(&b, "，常见最佳: %s", s.BestItem)
+		}
+	}
+	maafocus.NodeActionStarting(ctx, b.String())
+}
+
 // ExecuteResellTask - Execute Resell main task
 func ExecuteResellTask(tasker *maa.Tasker) error {
 	if tasker == nil {
@@ -92,6 +134,157 @@ func extractOCRText(detail *maa.RecognitionDetail) string {
 	return ""
 }
 
+// 商品名的合理长度（字符数），超出范围的 OCR 文本视为误识别
+const (
+	productNameMinRunes = 2
+	productNameMaxRunes = 16
+)
+
+// 详情页上的按钮与提示文字，OCR 区域覆盖到它们时不能当作商品名
+var productNameUITexts = []string{
+	"购买", "出售", "确认", "取消", "返回", "好友", "朋友", "加载中", "库存", "售罄", "成本", "价格",
+}
+
+// productNameConfig ResellROIDetailProductName 节点 attach 中的商品名识别配置。
+// ocr_enabled 为 false 时不用 OCR（roi 尚未对照详情页截图核实，宁可留空也不记录猜测的商品名）；
+// OCR 关闭或未得到可信商品名时，依次用 icons 中的模板在 icon_roi 内匹配，取得分最高且达到 icon_threshold 的商品名
+type productNameConfig struct {
+	OCREnabled bool              `json:"ocr_enabled"`
+	ROI        []int             `json:"icon_roi"`
+	Threshold  float64           `json:"icon_threshold"`
+	Icons      map[string]string `json:"icons"` // 商品名 -> 模板路径（相对 image 目录）
+}
+
+// loadProductNameConfig 读取 ResellROIDetailProductName 节点 attach 中的商品名识别配置
+func loadProductNameConfig(ctx *maa.Context) (productNameConfig, bool) {
+	raw, err := ctx.GetNodeJSON("ResellROIDetailProductName")
+	if err != nil {
+		log.Warn().Err(err).Msg("[Resell]读取商品名识别配置失败")
+		return productNameConfig{}, false
+	}
+	var wrapper struct {
+		Attach productNameConfig `json:"attach"`
+	}
+	if err := json.Unmarshal([]byte(raw), &wrapper); err != nil {
+		log.Warn().Err(err).Msg("[Resell]解析商品名识别配置失败")
+		return productNameConfig{}, false
+	}
+	return wrapper.Attach, true
+}
+
+// recognizeProductName 在详情页截图上识别商品名：开启 OCR 时优先取 OCR 命中结果中最长的可信文本，否则按图标模板匹配。
+// 都未得到商品名时返回空，记录中的商品名留空
+func recognizeProductName(ctx *maa.Context, img image.Image) string {
+	cfg, ok := loadProductNameConfig(ctx)
+	if !ok {
+		return ""
+	}
+	if cfg.OCREnabled {
+		if name := recognizeProductNameOCR(ctx, img); name != "" {
+			return name
+		}
+	}
+	return recognizeProductIcon(ctx, img, cfg)
+}
+
+// recognizeProductNameOCR 用 ResellROIDetailProductName 识别商品名，不可信的文本（见 isPlausibleProductName）会被忽略
+func recognizeProductNameOCR(ctx *maa.Context, img image.Image) string {
+	detail, err := ctx.RunRecognition("ResellROIDetailProductName", img, nil)
+	if err != nil {
+		log.Warn().Err(err).Msg("[Resell]商品名识别失败")
+		return ""
+	}
+	if detail == nil || !detail.Hit || detail.Results == nil {
+		return ""
+	}
+	name := ""
+	for _, r := range detail.Results.Filtered {
+		ocr, ok := r.AsOCR()
+		if !ok {
+			continue
+		}
+		text := normalizeProductName(ocr.Text)
+		if !isPlausibleProductName(text) {
+			log.Debug().Str("text", ocr.Text).Msg("[Resell]忽略不像商品名的 OCR 文本")
+			continue
+		}
+		if utf8.RuneCountInString(text) > utf8.RuneCountInString(name) {
+			name = text
+		}
+	}
+	return name
+}
+
+// recognizeProductIcon 按节点 attach 中登记的图标模板识别商品，未登记图标或均未达到阈值时返回空
+func recognizeProductIcon(ctx *maa.Context, img image.Image, cfg productNameConfig) string {
+	if len(cfg.Icons) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(cfg.Icons))
+	for name := range cfg.Icons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	best, bestScore := "", 0.0
+	for _, name := range names {
+		override := map[string]any{
+			"ResellDetailProductIcon": map[string]any{
+				"recognition": "TemplateMatch",
+				"template":    cfg.Icons[name],
+				"roi":         cfg.ROI,
+				"threshold":   cfg.Threshold,
+			},
+		}
+		detail, err := ctx.RunRecognition("ResellDetailProductIcon", img, override)
+		if err != nil {
+			log.Warn().Err(err).Str("item", name).Msg("[Resell]商品图标匹配失败")
+			continue
+		}
+		if detail == nil || !detail.Hit || detail.Results == nil || detail.Results.Best == nil {
+			continue
+		}
+		if tm, ok := detail.Results.Best.AsTemplateMatch(); ok && tm.Score > bestScore {
+			best, bestScore = name, tm.Score
+		}
+	}
+	if best != "" {
+		log.Info().Str("item", best).Float64("score", bestScore).Msg("[Resell]商品名由图标匹配得到")
+	}
+	return best
+}
+
+// normalizeProductName 合并 OCR 文本中的空白，使同一商品在不同天的记录一致
+func normalizeProductName(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// isPlausibleProductName 商品名只由文字、空格和 "·" "-" 组成，不含数字，长度在合理范围内，且不含详情页的按钮与提示文字；
+// 价格、数量、按钮文字等误识别的文本据此排除
+func isPlausibleProductName(text string) bool {
+	for _, ui := range productNameUITexts {
+		if strings.Contains(text, ui) {
+			return false
+		}
+	}
+	n := utf8.RuneCountInString(text)
+	if n < productNameMinRunes || n > productNameMaxRunes {
+		return false
+	}
+	letters := 0
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r):
+			letters++
+		case r == ' ' || r == '·' || r == '-':
+		default:
+			return false
+		}
+	}
+	return letters >= productNameMinRunes
+}
+
 // extractOCRFromDetailJson 从 Or/OCR 的 DetailJson 提取 text 和 box（用于 Or 识别时 Results 无直接 OCR 的兜底）
 func extractOCRFromDetailJson(detailJson string) (text string, boxX, boxY, boxW, boxH int) {
 	// 尝试 Or 结构：detail 为数组，首个子项含 detail.best